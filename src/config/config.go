@@ -13,15 +13,129 @@ import (
 
 // Config is the structure for vortex
 type Config struct {
-	Mongo      *mongo.MongoConfig                   `json:"mongo"`
-	Prometheus *prometheusprovider.PrometheusConfig `json:"prometheus"`
-	Registry   *registry.Config                     `json:"registry"`
-	Logger     logger.LoggerConfig                  `json:"logger"`
+	Mongo             *mongo.MongoConfig                   `json:"mongo"`
+	Prometheus        *prometheusprovider.PrometheusConfig `json:"prometheus"`
+	Registry          *registry.Config                     `json:"registry"`
+	Logger            logger.LoggerConfig                  `json:"logger"`
+	JWT               *JWTConfig                           `json:"jwt"`
+	LDAP              *LDAPConfig                          `json:"ldap"`
+	OIDC              *OIDCConfig                          `json:"oidc"`
+	Lockout           *LockoutConfig                       `json:"lockout"`
+	Signup            *SignupConfig                        `json:"signup"`
+	ServiceAccount    *ServiceAccountConfig                `json:"serviceAccount"`
+	NetworkReconciler *NetworkReconcilerConfig             `json:"networkReconciler"`
+	Team              *TeamConfig                          `json:"team"`
 
 	// the version settings of the current application
 	Version string `json:"version"`
 }
 
+// JWTConfig is the structure for the keys to sign and verify the JWT
+type JWTConfig struct {
+	// the ID of the key to sign new tokens, the other keys are only used to verify tokens
+	SigningKeyID string `json:"signingKeyID"`
+	// the lifetime of an access token, e.g. "1h"
+	Expiration string `json:"expiration"`
+	// the lifetime of a refresh token, e.g. "168h"
+	RefreshExpiration string         `json:"refreshExpiration"`
+	Keys              []JWTKeyConfig `json:"keys"`
+}
+
+// JWTKeyConfig is the structure for a JWT key
+// HS256 uses the secret (or secretFile), RS256 and ES256 use the PEM encoded key files.
+// A key without the private key can only verify tokens.
+type JWTKeyConfig struct {
+	ID             string `json:"id"`
+	Algorithm      string `json:"algorithm"`
+	Secret         string `json:"secret"`
+	SecretFile     string `json:"secretFile"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	PublicKeyFile  string `json:"publicKeyFile"`
+}
+
+// LDAPConfig is the structure for the LDAP authenticator
+type LDAPConfig struct {
+	// the address of the LDAP server, e.g. "ldap.example.com:389"
+	Address            string `json:"address"`
+	TLS                bool   `json:"tls"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	// the account to search the users and groups, search anonymously without it
+	BindDN       string `json:"bindDN"`
+	BindPassword string `json:"bindPassword"`
+	// the base DN and filter to find the user by the username, the filter is "(mail=%s)" by default
+	BaseDN     string `json:"baseDN"`
+	UserFilter string `json:"userFilter"`
+	// the base DN and filter to find the groups by the user DN, the filter is "(member=%s)" by default
+	// the memberOf attribute of the user is used without the group base DN
+	GroupBaseDN string `json:"groupBaseDN"`
+	GroupFilter string `json:"groupFilter"`
+	// the roles of the group DNs, the highest role of the user's groups is used
+	GroupRoles map[string]string `json:"groupRoles"`
+	// the role of the users without any mapped groups, they can not sign in when it's empty
+	DefaultRole string `json:"defaultRole"`
+}
+
+// OIDCConfig is the structure for the OpenID Connect login
+type OIDCConfig struct {
+	// the issuer URL, the provider is discovered from "<issuer>/.well-known/openid-configuration"
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"clientID"`
+	ClientSecret string `json:"clientSecret"`
+	// the callback URL registered in the provider, e.g. "https://vortex.example.com/v1/users/oidc/callback"
+	RedirectURL string `json:"redirectURL"`
+	// the scopes to request, "openid", "email" and "profile" by default
+	Scopes []string `json:"scopes"`
+	// the role of the users created on the first login, "user" by default
+	DefaultRole string `json:"defaultRole"`
+}
+
+// LockoutConfig is the structure for the brute-force protection of signing in
+// The zero values use the defaults
+type LockoutConfig struct {
+	// the failed attempts of a username or a client IP before the backoff starts
+	FreeAttempts   int `json:"freeAttempts"`
+	IPFreeAttempts int `json:"ipFreeAttempts"`
+	// the first backoff delay, it's doubled for every further failure up to the max delay
+	BaseDelay string `json:"baseDelay"`
+	MaxDelay  string `json:"maxDelay"`
+	// the failed attempts of a username to lock the account, and how long the account is locked
+	Threshold    int    `json:"threshold"`
+	LockDuration string `json:"lockDuration"`
+	// the failures are forgotten after the time since the last failure
+	ResetAfter string `json:"resetAfter"`
+	// use the first address of the X-Forwarded-For header as the client IP behind a proxy
+	UseForwardedFor bool `json:"useForwardedFor"`
+}
+
+// SignupConfig is the structure for the registration of the users
+type SignupConfig struct {
+	// disable the open signup, the users can only sign up with an invitation
+	InviteOnly bool `json:"inviteOnly"`
+	// the lifetime of an invitation, e.g. "72h"
+	InviteExpiration string `json:"inviteExpiration"`
+}
+
+// ServiceAccountConfig is the structure for the authentication of the Kubernetes ServiceAccount tokens
+type ServiceAccountConfig struct {
+	// the roles of the ServiceAccounts, the key is "<namespace>/<name>", the other ServiceAccounts are rejected
+	Roles map[string]string `json:"roles"`
+}
+
+// NetworkReconcilerConfig is the structure for checking the networks on the nodes periodically
+type NetworkReconcilerConfig struct {
+	// the interval between the checks, e.g. "5m"
+	Interval string `json:"interval"`
+	// recreate the missing bridges, ports and VLAN trunks on the nodes
+	AutoRepair bool `json:"autoRepair"`
+}
+
+// TeamConfig is the structure for the namespaces of the teams
+type TeamConfig struct {
+	// the namespaces not created by vortex which all users can use, e.g. "default"
+	// the other namespaces not created by vortex can only be used by the root role
+	SharedNamespaces []string `json:"sharedNamespaces"`
+}
+
 // Read will read config file
 func Read(path string) (c Config, err error) {
 	file, err := os.Open(path)
@@ -4,15 +4,19 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/linkernetworks/logger"
 	"github.com/linkernetworks/mongo"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/hwchiu/vortex/src/utils"
 	appsv1 "k8s.io/api/apps/v1"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -22,6 +26,9 @@ var allCapabilities = []corev1.Capability{"NET_ADMIN", "SYS_ADMIN", "NET_RAW"}
 const VolumeNamePrefix = "volume-"
 const DefaultLabel = "vortex"
 
+// ReplicaLabel is the label of the replica when each replica is a kubernetes deployment
+const ReplicaLabel = "vortex-replica"
+
 // CheckDeploymentParameter will Check Deployment's Parameter
 func CheckDeploymentParameter(sp *serviceprovider.Container, deploy *entity.Deployment) error {
 	session := sp.Mongo.NewSession()
@@ -37,13 +44,24 @@ func CheckDeploymentParameter(sp *serviceprovider.Container, deploy *entity.Depl
 		}
 	}
 
-	//Check the network
+	//Check the network, the pending networks are not created on the nodes yet
 	for _, v := range deploy.Networks {
-		count, err := session.Count(entity.NetworkCollectionName, bson.M{"name": v.Name})
-		if err != nil {
+		network := entity.Network{}
+		if err := session.FindOne(entity.NetworkCollectionName, bson.M{"name": v.Name, "pending": bson.M{"$ne": true}}, &network); err != nil {
+			if err == mgo.ErrNotFound {
+				return fmt.Errorf("the network named %s doesn't exist", v.Name)
+			}
 			return fmt.Errorf("check the network name error:%v", err)
-		} else if count == 0 {
-			return fmt.Errorf("the network named %s doesn't exist", v.Name)
+		}
+		// the IP addresses are allocated from the subnets of the network when it's empty
+		if v.IPAddress == "" && len(network.Subnets) == 0 {
+			return fmt.Errorf("the IP address of the network %s is required since the network has no subnets", v.Name)
+		}
+		if v.IPAddress != "" && len(network.Subnets) == 0 && v.Netmask == "" {
+			return fmt.Errorf("the netmask of the network %s is required", v.Name)
+		}
+		if v.IPAddress != "" && deploy.Replicas > 1 {
+			return fmt.Errorf("the IP address %s of the network %s can not be used by %d replicas", v.IPAddress, v.Name, deploy.Replicas)
 		}
 	}
 
@@ -121,6 +139,18 @@ func generateClientCommand(network entity.DeploymentNetwork) (command []string)
 	return
 }
 
+// replicaNetworks will return the networks with the allocated IP addresses of the replica
+func replicaNetworks(networks []entity.DeploymentNetwork, replica int) []entity.DeploymentNetwork {
+	ret := []entity.DeploymentNetwork{}
+	for _, network := range networks {
+		if replica < len(network.IPAddresses) {
+			network.IPAddress = network.IPAddresses[replica]
+		}
+		ret = append(ret, network)
+	}
+	return ret
+}
+
 func generateInitContainer(networks []entity.DeploymentNetwork) ([]corev1.Container, error) {
 	containers := []corev1.Container{}
 
@@ -175,18 +205,81 @@ func generateNetwork(session *mongo.Session, deploy *entity.Deployment) ([]strin
 	networks := []entity.Network{}
 	for i, v := range deploy.Networks {
 		network := entity.Network{}
-		if err := session.FindOne(entity.NetworkCollectionName, bson.M{"name": v.Name}, &network); err != nil {
+		if err := session.FindOne(entity.NetworkCollectionName, bson.M{"name": v.Name, "pending": bson.M{"$ne": true}}, &network); err != nil {
 			return nil, nil, err
 		}
 		networks = append(networks, network)
 		deploy.Networks[i].BridgeName = network.BridgeName
+		if err := allocateAddresses(session, network, deploy, i); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	nodes := generateNodeLabels(networks)
-	containers, err := generateInitContainer(deploy.Networks)
+	containers, err := generateInitContainer(replicaNetworks(deploy.Networks, 0))
 	return nodes, containers, err
 }
 
+// allocateAddresses will allocate an IP address of the deployment network for each replica from the subnets of the network
+// The static IP address is reserved, so it can not be used by the other pods
+func allocateAddresses(session *mongo.Session, network entity.Network, deploy *entity.Deployment, i int) error {
+	deployNetwork := &deploy.Networks[i]
+	// the static IP address is required without the subnets, it's reserved so the other pods and deployments can not use it
+	if len(network.Subnets) == 0 {
+		if deployNetwork.IPAddress == "" {
+			return nil
+		}
+		_, err := ipam.ReserveStatic(session, network, deployNetwork.IPAddress, deployNetwork.Netmask, deploy.ID, 0)
+		return err
+	}
+
+	if deployNetwork.IPAddress != "" {
+		allocation, err := ipam.Reserve(session, network, deployNetwork.IPAddress, deploy.ID, 0)
+		if err != nil {
+			return err
+		}
+		deployNetwork.Netmask = allocation.Netmask
+		return nil
+	}
+
+	deployNetwork.IPAddresses = []string{}
+	for replica := 0; replica < int(deploy.Replicas); replica++ {
+		allocation, err := ipam.Allocate(session, network, deploy.ID, replica)
+		if err != nil {
+			return err
+		}
+		deployNetwork.IPAddresses = append(deployNetwork.IPAddresses, allocation.Address)
+		deployNetwork.Netmask = allocation.Netmask
+	}
+	return nil
+}
+
+// splitReplicas checks whether the replicas have their own IP addresses
+// Such replicas can not share a pod template, so each of them is created as a kubernetes deployment with one replica
+func splitReplicas(deploy *entity.Deployment) bool {
+	if deploy.Replicas <= 1 {
+		return false
+	}
+	for _, network := range deploy.Networks {
+		if len(network.IPAddresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// kubeDeploymentNames will return the names of the kubernetes deployments of the deployment
+func kubeDeploymentNames(deploy *entity.Deployment) []string {
+	if !splitReplicas(deploy) {
+		return []string{deploy.Name}
+	}
+	names := []string{}
+	for replica := 0; replica < int(deploy.Replicas); replica++ {
+		names = append(names, fmt.Sprintf("%s-%d", deploy.Name, replica))
+	}
+	return names
+}
+
 func generateContainerSecurity(deploy *entity.Deployment) *corev1.SecurityContext {
 	if !deploy.Capability {
 		return &corev1.SecurityContext{}
@@ -265,6 +358,9 @@ func CreateDeployment(sp *serviceprovider.Container, deploy *entity.Deployment)
 	}
 
 	if err != nil {
+		if releaseErr := ipam.Release(session, deploy.ID); releaseErr != nil {
+			logger.Warnf("fail to release the IP addresses of the deployment %s: %v", deploy.Name, releaseErr)
+		}
 		return err
 	}
 
@@ -291,50 +387,99 @@ func CreateDeployment(sp *serviceprovider.Container, deploy *entity.Deployment)
 		})
 	}
 
-	p := appsv1.Deployment{
+	podSpec := corev1.PodSpec{
+		InitContainers: initContainers,
+		Containers:     containers,
+		Volumes:        volumes,
+		Affinity:       generateAffinity(nodeAffinity),
+		RestartPolicy:  corev1.RestartPolicyAlways,
+		HostNetwork:    hostNetwork,
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: "dockerhub-token"},
+		},
+	}
+
+	if deploy.Namespace == "" {
+		deploy.Namespace = "default"
+	}
+
+	if !splitReplicas(deploy) {
+		p := generateDeployment(deploy, deploy.Name, deploy.Replicas, map[string]string{DefaultLabel: deploy.Name}, podSpec)
+		if _, err := sp.KubeCtl.CreateDeployment(p, deploy.Namespace); err != nil {
+			if releaseErr := ipam.Release(session, deploy.ID); releaseErr != nil {
+				logger.Warnf("fail to release the IP addresses of the deployment %s: %v", deploy.Name, releaseErr)
+			}
+			return err
+		}
+		return nil
+	}
+
+	// every replica has its own init containers with its IP addresses
+	names := kubeDeploymentNames(deploy)
+	for replica, name := range names {
+		replicaSpec := podSpec
+		replicaSpec.InitContainers, err = generateInitContainer(replicaNetworks(deploy.Networks, replica))
+		if err == nil {
+			labels := map[string]string{
+				DefaultLabel: deploy.Name,
+				ReplicaLabel: strconv.Itoa(replica),
+			}
+			_, err = sp.KubeCtl.CreateDeployment(generateDeployment(deploy, name, 1, labels, replicaSpec), deploy.Namespace)
+		}
+		if err != nil {
+			for _, created := range names[:replica] {
+				sp.KubeCtl.DeleteDeployment(created, deploy.Namespace)
+			}
+			if releaseErr := ipam.Release(session, deploy.ID); releaseErr != nil {
+				logger.Warnf("fail to release the IP addresses of the deployment %s: %v", deploy.Name, releaseErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// generateDeployment will generate the kubernetes deployment, the pods are selected by the labels
+func generateDeployment(deploy *entity.Deployment, name string, replicas int32, labels map[string]string, podSpec corev1.PodSpec) *appsv1.Deployment {
+	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   deploy.Name,
+			Name:   name,
 			Labels: deploy.Labels,
 		},
 		Spec: appsv1.DeploymentSpec{
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					DefaultLabel: deploy.Name,
-				},
+				MatchLabels: labels,
 			},
-			Replicas: &deploy.Replicas,
+			Replicas: &replicas,
 			Strategy: appsv1.DeploymentStrategy{
 				Type: appsv1.RecreateDeploymentStrategyType,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						DefaultLabel: deploy.Name,
-					},
-				},
-				Spec: corev1.PodSpec{
-					InitContainers: initContainers,
-					Containers:     containers,
-					Volumes:        volumes,
-					Affinity:       generateAffinity(nodeAffinity),
-					RestartPolicy:  corev1.RestartPolicyAlways,
-					HostNetwork:    hostNetwork,
-					ImagePullSecrets: []corev1.LocalObjectReference{
-						{Name: "dockerhub-token"},
-					},
+					Labels: labels,
 				},
+				Spec: podSpec,
 			},
 		},
 	}
-
-	if deploy.Namespace == "" {
-		deploy.Namespace = "default"
-	}
-	_, err = sp.KubeCtl.CreateDeployment(&p, deploy.Namespace)
-	return err
 }
 
-// DeleteDeployment will delete deploy
+// DeleteDeployment will delete deploy and release its IP addresses
+// The kubernetes deployments which are already gone are skipped, and the IP addresses are always released
+// It returns the first error of the kubernetes deployments
 func DeleteDeployment(sp *serviceprovider.Container, deploy *entity.Deployment) error {
-	return sp.KubeCtl.DeleteDeployment(deploy.Name, deploy.Namespace)
+	var deleteErr error
+	for _, name := range kubeDeploymentNames(deploy) {
+		if err := sp.KubeCtl.DeleteDeployment(name, deploy.Namespace); err != nil && !errors.IsNotFound(err) && deleteErr == nil {
+			deleteErr = err
+		}
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+	releaseErr := ipam.Release(session, deploy.ID)
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return releaseErr
 }
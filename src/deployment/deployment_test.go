@@ -1,12 +1,14 @@
 package deployment
 
 import (
+	"fmt"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/stretchr/testify/suite"
@@ -454,3 +456,97 @@ func (suite *DeploymentTestSuite) TestCreateDeploymentWithNetworkTypes() {
 		})
 	}
 }
+
+func (suite *DeploymentTestSuite) TestCheckDeploymentParameterWithReplicas() {
+	network := entity.Network{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+	}
+	session := suite.sp.Mongo.NewSession()
+	defer session.Close()
+
+	session.Insert(entity.NetworkCollectionName, network)
+	defer session.Remove(entity.NetworkCollectionName, "name", network.Name)
+
+	deploy := &entity.Deployment{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Networks: []entity.DeploymentNetwork{
+			{
+				Name:      network.Name,
+				IPAddress: "1.2.3.4",
+				Netmask:   "255.255.255.0",
+			},
+		},
+		Replicas: 1,
+	}
+	err := CheckDeploymentParameter(suite.sp, deploy)
+	suite.NoError(err)
+
+	// the replicas can not share the static IP address
+	deploy.Replicas = 2
+	err = CheckDeploymentParameter(suite.sp, deploy)
+	suite.Error(err)
+
+	// the network has no subnets to allocate the IP addresses
+	deploy.Networks[0].IPAddress = ""
+	err = CheckDeploymentParameter(suite.sp, deploy)
+	suite.Error(err)
+}
+
+func (suite *DeploymentTestSuite) TestCreateDeploymentWithReplicas() {
+	network := entity.Network{
+		ID:         bson.NewObjectId(),
+		Name:       namesgenerator.GetRandomName(0),
+		BridgeName: namesgenerator.GetRandomName(0),
+		Subnets: []entity.Subnet{
+			{CIDR: "10.0.0.0/24", AllocationPools: []entity.AllocationPool{{Start: "10.0.0.10", End: "10.0.0.20"}}},
+		},
+	}
+	session := suite.sp.Mongo.NewSession()
+	defer session.Close()
+
+	session.Insert(entity.NetworkCollectionName, network)
+	defer session.Remove(entity.NetworkCollectionName, "name", network.Name)
+	defer ipam.ReleaseNetwork(session, network.ID)
+
+	deploy := &entity.Deployment{
+		ID:          bson.NewObjectId(),
+		Name:        namesgenerator.GetRandomName(0),
+		Containers:  []entity.Container{},
+		NetworkType: entity.DeploymentCustomNetwork,
+		Networks: []entity.DeploymentNetwork{
+			{
+				Name:   network.Name,
+				IfName: "eth1",
+			},
+		},
+		Replicas: 3,
+	}
+
+	err := CreateDeployment(suite.sp, deploy)
+	suite.NoError(err)
+	suite.Equal([]string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}, deploy.Networks[0].IPAddresses)
+
+	// each replica is a kubernetes deployment with its own IP address
+	for i, address := range deploy.Networks[0].IPAddresses {
+		d, err := suite.sp.KubeCtl.GetDeployment(fmt.Sprintf("%s-%d", deploy.Name, i), deploy.Namespace)
+		suite.NoError(err)
+		suite.Equal(int32(1), *d.Spec.Replicas)
+		suite.Equal(deploy.Name, d.Spec.Template.Labels[DefaultLabel])
+		suite.Contains(d.Spec.Template.Spec.InitContainers[0].Args, "--ip="+address+"/24")
+	}
+
+	// the kubernetes deployment which is already gone is skipped
+	err = suite.sp.KubeCtl.DeleteDeployment(deploy.Name+"-1", deploy.Namespace)
+	suite.NoError(err)
+	err = DeleteDeployment(suite.sp, deploy)
+	suite.NoError(err)
+	for i := range deploy.Networks[0].IPAddresses {
+		_, err := suite.sp.KubeCtl.GetDeployment(fmt.Sprintf("%s-%d", deploy.Name, i), deploy.Namespace)
+		suite.Error(err)
+	}
+	count, err := session.Count(entity.IPAllocationCollectionName, bson.M{"resourceID": deploy.ID})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
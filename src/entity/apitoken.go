@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for APITokenCollectionName
+const (
+	APITokenCollectionName string = "apiTokens"
+)
+
+// APIToken is the structure for a personal API token of the user
+// It's a long-lived credential for the scripts and CI pipelines
+type APIToken struct {
+	ID     bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
+	UserID bson.ObjectId `bson:"userID" json:"userID" validate:"-"`
+	Name   string        `bson:"name" json:"name" validate:"required"`
+	// the role scope of the token, it can not be higher than the role of the user
+	Role string `bson:"role" json:"role" validate:"required,eq=root|eq=user|eq=guest"`
+	// the SHA256 hash of the secret of the token, the plain token is only returned when creating
+	HashedToken string     `bson:"hashedToken" json:"-" validate:"-"`
+	Token       string     `bson:"-" json:"token,omitempty" validate:"-"`
+	ExpiresAt   *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty" validate:"-"`
+	LastUsedAt  *time.Time `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty" validate:"-"`
+	CreatedAt   *time.Time `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (t APIToken) GetCollection() string {
+	return APITokenCollectionName
+}
@@ -0,0 +1,43 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for AuditCollectionName
+const (
+	AuditCollectionName string = "audit"
+)
+
+// the const for the actions of the audit logs
+const (
+	CreateAction string = "create"
+	UpdateAction string = "update"
+	DeleteAction string = "delete"
+)
+
+// AuditLog is the structure for the record of a mutating API call
+type AuditLog struct {
+	ID bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
+	// the actor of the call, it's empty for the anonymous calls like signing in
+	UserID   string `bson:"userID,omitempty" json:"userID,omitempty" validate:"-"`
+	Role     string `bson:"role,omitempty" json:"role,omitempty" validate:"-"`
+	ClientIP string `bson:"clientIP" json:"clientIP" validate:"-"`
+	Action   string `bson:"action" json:"action" validate:"-"`
+	Method   string `bson:"method" json:"method" validate:"-"`
+	Path     string `bson:"path" json:"path" validate:"-"`
+	// the resource type is the first path segment after /v1, e.g. networks
+	ResourceType string `bson:"resourceType" json:"resourceType" validate:"-"`
+	ResourceID   string `bson:"resourceID,omitempty" json:"resourceID,omitempty" validate:"-"`
+	// the SHA256 of the request body, the body itself may contain the secrets like passwords
+	BodyDigest string     `bson:"bodyDigest,omitempty" json:"bodyDigest,omitempty" validate:"-"`
+	Status     int        `bson:"status" json:"status" validate:"-"`
+	CreatedAt  *time.Time `bson:"createdAt" json:"createdAt" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (a AuditLog) GetCollection() string {
+	return AuditCollectionName
+}
@@ -35,13 +35,15 @@ type DeploymentNetwork struct {
 	IfName string `bson:"ifName" json:"ifName" validate:"required"`
 	// can not validate nil
 	VlanTag    *int32                `bson:"vlanTag" json:"vlanTag" validate:"-"`
-	IPAddress  string                `bson:"ipAddress" json:"ipAddress" validate:"required,ipv4"`
-	Netmask    string                `bson:"netmask" json:"netmask" validate:"required,ipv4"`
+	IPAddress  string                `bson:"ipAddress" json:"ipAddress" validate:"omitempty,ipv4"`
+	Netmask    string                `bson:"netmask" json:"netmask" validate:"omitempty,ipv4"`
 	RoutesGw   []DeploymentRouteGw   `bson:"routesGw,omitempty" json:"routesGw" validate:"required,dive,required"`
 	RoutesIntf []DeploymentRouteIntf `bson:"routesIntf,omitempty" json:"routesIntf" validate:"required,dive,required"`
 
 	// It's from the entity.Network entity
 	BridgeName string `bson:"bridgeName" json:"bridgeName" validate:"-"`
+	// the allocated IP addresses of the replicas
+	IPAddresses []string `bson:"ipAddresses,omitempty" json:"ipAddresses,omitempty" validate:"-"`
 }
 
 // DeploymentVolume is the structure for deployment volume info
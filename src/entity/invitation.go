@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for InvitationCollectionName
+const (
+	InvitationCollectionName string = "invitations"
+)
+
+// Invitation is the structure for an invitation to sign up with the email and role
+type Invitation struct {
+	ID    bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
+	Email string        `bson:"email" json:"email" validate:"required,email"`
+	Role  string        `bson:"role" json:"role" validate:"required,eq=root|eq=user|eq=guest"`
+	// the SHA256 of the invite token, the plain token is only returned when creating
+	HashedToken string        `bson:"hashedToken" json:"-" validate:"-"`
+	Token       string        `bson:"-" json:"token,omitempty" validate:"-"`
+	CreatedBy   bson.ObjectId `bson:"createdBy,omitempty" json:"createdBy,omitempty" validate:"-"`
+	ExpiresAt   *time.Time    `bson:"expiresAt" json:"expiresAt" validate:"-"`
+	CreatedAt   *time.Time    `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (i Invitation) GetCollection() string {
+	return InvitationCollectionName
+}
+
+// SignupRequest is the structure to sign up the user, the invite token is required when the signup is invite-only
+type SignupRequest struct {
+	User
+	InviteToken string `json:"inviteToken" validate:"-"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for IPAllocationCollectionName
+const (
+	IPAllocationCollectionName string = "ipAllocations"
+)
+
+// IPAllocation is the structure for an IP address of the network which is used by a pod or a deployment
+// The address is unique in the network
+type IPAllocation struct {
+	ID        bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
+	NetworkID bson.ObjectId `bson:"networkID" json:"networkID" validate:"-"`
+	Address   string        `bson:"address" json:"address" validate:"-"`
+	Netmask   string        `bson:"netmask" json:"netmask" validate:"-"`
+	// the ID of the pod or the deployment which uses the address
+	ResourceID bson.ObjectId `bson:"resourceID" json:"resourceID" validate:"-"`
+	// the replica of the deployment, it's 0 for the pods
+	Replica   int        `bson:"replica" json:"replica" validate:"-"`
+	CreatedAt *time.Time `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (a IPAllocation) GetCollection() string {
+	return IPAllocationCollectionName
+}
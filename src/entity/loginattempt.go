@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+)
+
+// the const for LoginAttemptCollectionName
+const (
+	LoginAttemptCollectionName string = "loginAttempts"
+)
+
+// LoginAttempt is the structure for the failed sign in attempts of a username or a client IP
+// The ID is "username:<username>" or "ip:<ip>"
+type LoginAttempt struct {
+	ID       string `bson:"_id" json:"id" validate:"-"`
+	Failures int    `bson:"failures" json:"failures" validate:"-"`
+	// the next attempt is rejected before the time
+	BlockedUntil *time.Time `bson:"blockedUntil,omitempty" json:"blockedUntil,omitempty" validate:"-"`
+	// the account is locked until the time or being unlocked by the root role
+	LockedUntil *time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty" validate:"-"`
+	// the attempt is forgotten after the time
+	ExpiresAt *time.Time `bson:"expiresAt" json:"expiresAt" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (l LoginAttempt) GetCollection() string {
+	return LoginAttemptCollectionName
+}
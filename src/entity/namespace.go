@@ -15,6 +15,7 @@ const (
 type Namespace struct {
 	ID        bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
 	OwnerID   bson.ObjectId `bson:"ownerID,omitempty" json:"ownerID" validate:"-"`
+	TeamID    bson.ObjectId `bson:"teamID,omitempty" json:"teamID,omitempty" validate:"-"`
 	Name      string        `bson:"name" json:"name" validate:"required,k8sname"`
 	CreatedAt *time.Time    `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
 	CreatedBy User          `json:"createdBy" validate:"-"`
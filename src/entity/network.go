@@ -18,9 +18,26 @@ const (
 
 // The const for NetworkCollectionName
 const (
-	NetworkCollectionName string = "networks"
+	NetworkCollectionName       string = "networks"
+	NetworkStatusCollectionName string = "network_status"
 )
 
+// The status of the network on a node
+const (
+	NodeSucceeded      string = "succeeded"
+	NodeFailed         string = "failed"
+	NodeSkipped        string = "skipped"
+	NodeRolledBack     string = "rolledBack"
+	NodeRollbackFailed string = "rollbackFailed"
+)
+
+// NetworkNodeStatus is the structure for the result of the network on a node
+type NetworkNodeStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
 // PhyInterface is the structure for physical interface
 type PhyInterface struct {
 	Name  string `bson:"name" json:"name" validate:"required"`
@@ -33,6 +50,20 @@ type Node struct {
 	PhyInterfaces []PhyInterface `bson:"physicalInterfaces" json:"physicalInterfaces" validate:"required,dive,required"`
 }
 
+// AllocationPool is the range of the IP addresses which can be allocated to the pods
+type AllocationPool struct {
+	Start string `bson:"start" json:"start" validate:"required,ipv4"`
+	End   string `bson:"end" json:"end" validate:"required,ipv4"`
+}
+
+// Subnet is the structure for the IP addresses of the network
+// The whole subnet is used when no allocation pool is given, except the network, broadcast and gateway addresses
+type Subnet struct {
+	CIDR            string           `bson:"cidr" json:"cidr" validate:"required,cidrv4"`
+	Gateway         string           `bson:"gateway,omitempty" json:"gateway,omitempty" validate:"omitempty,ipv4"`
+	AllocationPools []AllocationPool `bson:"allocationPools,omitempty" json:"allocationPools" validate:"omitempty,dive,required"`
+}
+
 // Network is the structure for Network info
 type Network struct {
 	ID         bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
@@ -43,11 +74,42 @@ type Network struct {
 	VlanTags   []int32       `bson:"vlanTags" json:"vlanTags" validate:"required,dive,max=4095,min=0"`
 	BridgeName string        `bson:"bridgeName" json:"bridgeName" validate:"-"`
 	Nodes      []Node        `bson:"nodes" json:"nodes" validate:"required,dive,required"`
+	Subnets    []Subnet      `bson:"subnets,omitempty" json:"subnets" validate:"omitempty,dive,required"`
 	CreatedBy  User          `json:"createdBy" validate:"-"`
 	CreatedAt  *time.Time    `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+	// the network is being created on the nodes, it only reserves the name
+	Pending bool `bson:"pending,omitempty" json:"pending,omitempty" validate:"-"`
+	// the time when the network starts being created on the nodes
+	PendingAt *time.Time `bson:"pendingAt,omitempty" json:"pendingAt,omitempty" validate:"-"`
 }
 
 // GetCollection - get model mongo collection name.
 func (m Network) GetCollection() string {
 	return NetworkCollectionName
 }
+
+// NetworkUpdate is the structure for changing the nodes and the VLAN tags of the network
+type NetworkUpdate struct {
+	VlanTags []int32 `json:"vlanTags" validate:"required,dive,max=4095,min=0"`
+	Nodes    []Node  `json:"nodes" validate:"required,dive,required"`
+}
+
+// NodeNetworkStatus is the structure for the state of the network on a node
+type NodeNetworkStatus struct {
+	Name         string   `bson:"name" json:"name"`
+	BridgeExists bool     `bson:"bridgeExists" json:"bridgeExists"`
+	MissingPorts []string `bson:"missingPorts" json:"missingPorts"`
+	Drifted      bool     `bson:"drifted" json:"drifted"`
+	Repaired     bool     `bson:"repaired" json:"repaired"`
+	// the node can not be checked or repaired
+	Error string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// NetworkStatus is the structure for the drift between the network and the nodes
+type NetworkStatus struct {
+	// the ID of the network
+	ID        bson.ObjectId       `bson:"_id" json:"id"`
+	Drifted   bool                `bson:"drifted" json:"drifted"`
+	Nodes     []NodeNetworkStatus `bson:"nodes" json:"nodes"`
+	CheckedAt *time.Time          `bson:"checkedAt" json:"checkedAt"`
+}
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for OIDCStateCollectionName
+const (
+	OIDCStateCollectionName string = "oidcStates"
+)
+
+// OIDCState is the structure for the state of an OpenID Connect login
+// It's removed when the provider redirects back to the callback
+type OIDCState struct {
+	State     string     `bson:"_id" json:"state" validate:"-"`
+	Nonce     string     `bson:"nonce" json:"nonce" validate:"-"`
+	ExpiresAt *time.Time `bson:"expiresAt" json:"expiresAt" validate:"-"`
+	// the signed-in user who links the account, it's empty for a login
+	UserID bson.ObjectId `bson:"userID,omitempty" json:"userID,omitempty" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (s OIDCState) GetCollection() string {
+	return OIDCStateCollectionName
+}
+
+// OIDCClaims is the structure for the claims of the ID token used to provision the user
+type OIDCClaims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	PhoneNumber   string `json:"phone_number"`
+}
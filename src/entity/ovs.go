@@ -17,4 +17,16 @@ type OVSPortInfo struct {
 	MacAddress    string       `json:"macAddress"`
 	Received      OVSPortStats `json:"received"`
 	Transmitted   OVSPortStats `json:"traansmitted"`
+
+	// the node of the port, and the namespace and the deployment of the pod
+	NodeName       string `json:"nodeName"`
+	Namespace      string `json:"namespace"`
+	DeploymentName string `json:"deploymentName"`
+}
+
+// NetworkPortInfos is the ports of the network on all nodes
+// The nodes which can not be reached are failed in the nodes, and the ports of the other nodes are still returned
+type NetworkPortInfos struct {
+	Ports []OVSPortInfo       `json:"ports"`
+	Nodes []NetworkNodeStatus `json:"nodes"`
 }
@@ -42,8 +42,8 @@ type PodNetwork struct {
 	IfName string `bson:"ifName" json:"ifName" validate:"required"`
 	// can not validate nil
 	VlanTag    *int32         `bson:"vlanTag" json:"vlanTag" validate:"-"`
-	IPAddress  string         `bson:"ipAddress" json:"ipAddress" validate:"required,ipv4"`
-	Netmask    string         `bson:"netmask" json:"netmask" validate:"required,ipv4"`
+	IPAddress  string         `bson:"ipAddress" json:"ipAddress" validate:"omitempty,ipv4"`
+	Netmask    string         `bson:"netmask" json:"netmask" validate:"omitempty,ipv4"`
 	RoutesGw   []PodRouteGw   `bson:"routesGw,omitempty" json:"routesGw" validate:"required,dive,required"`
 	RoutesIntf []PodRouteIntf `bson:"routesIntf,omitempty" json:"routesIntf" validate:"required,dive,required"`
 
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for the collections of sessions and revoked tokens
+const (
+	SessionCollectionName      string = "sessions"
+	RevokedTokenCollectionName string = "revokedTokens"
+)
+
+// Session is the structure for a signed-in session of the user
+// The ID of the session is the token ID (jti) of the access tokens issued from it
+type Session struct {
+	ID     bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
+	UserID bson.ObjectId `bson:"userID" json:"userID" validate:"-"`
+	// the SHA256 of the refresh token, the plain refresh token is only returned to the user
+	RefreshToken string `bson:"refreshToken" json:"-" validate:"-"`
+	// the SHA256 of the last rotated refresh tokens, using one of them again revokes the session
+	UsedRefreshTokens []string   `bson:"usedRefreshTokens,omitempty" json:"-" validate:"-"`
+	ExpiresAt         *time.Time `bson:"expiresAt" json:"expiresAt" validate:"-"`
+	CreatedAt         *time.Time `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (s Session) GetCollection() string {
+	return SessionCollectionName
+}
+
+// RevokedToken is the structure for a revoked token ID (jti)
+// It can be removed once all access tokens with this ID are expired
+type RevokedToken struct {
+	ID        string        `bson:"_id" json:"id" validate:"-"`
+	UserID    bson.ObjectId `bson:"userID" json:"userID" validate:"-"`
+	ExpiresAt *time.Time    `bson:"expiresAt" json:"expiresAt" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (r RevokedToken) GetCollection() string {
+	return RevokedTokenCollectionName
+}
+
+// RefreshCredential is the structure to exchange the refresh token for a new access token
+type RefreshCredential struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the const for TeamCollectionName and the roles in a team
+const (
+	TeamCollectionName string = "teams"
+	// the admin manages the members and the namespaces of the team
+	TeamAdminRole string = "admin"
+	// the member creates and deletes the resources in the namespaces of the team
+	TeamMemberRole string = "member"
+	// the viewer only reads the namespaces of the team
+	TeamViewerRole string = "viewer"
+)
+
+// TeamMember is the structure for a user in the team
+type TeamMember struct {
+	UserID bson.ObjectId `bson:"userID" json:"userID" validate:"required"`
+	Role   string        `bson:"role" json:"role" validate:"required,eq=admin|eq=member|eq=viewer"`
+}
+
+// Team is the structure for a group of users which owns the namespaces
+type Team struct {
+	ID          bson.ObjectId `bson:"_id,omitempty" json:"id" validate:"-"`
+	Name        string        `bson:"name" json:"name" validate:"required"`
+	Description string        `bson:"description" json:"description" validate:"-"`
+	Members     []TeamMember  `bson:"members" json:"members" validate:"dive"`
+	CreatedAt   *time.Time    `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+}
+
+// GetCollection - get model mongo collection name.
+func (t Team) GetCollection() string {
+	return TeamCollectionName
+}
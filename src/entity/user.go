@@ -16,6 +16,11 @@ const (
 	GuestRole string = "guest"
 )
 
+// The const for the sources of the users, the local users have no source
+const (
+	LDAPUserSource string = "ldap"
+)
+
 // RegistryBasicAuthCredential is the structure for a user login credential
 type RegistryBasicAuthCredential struct {
 	Username string `bson:"username" json:"username" validate:"required"`
@@ -38,9 +43,35 @@ type User struct {
 	LastName        string          `bson:"lastName" json:"lastName" validate:"required"`
 	PhoneNumber     string          `bson:"phoneNumber" json:"phoneNumber" validate:"required,numeric"`
 	CreatedAt       *time.Time      `bson:"createdAt,omitempty" json:"createdAt,omitempty" validate:"-"`
+	// the issuer and subject of the OpenID Connect account linked to the user
+	OIDCIssuer  string `bson:"oidcIssuer,omitempty" json:"oidcIssuer,omitempty" validate:"-"`
+	OIDCSubject string `bson:"oidcSubject,omitempty" json:"oidcSubject,omitempty" validate:"-"`
+	// the external directory which provisioned the user, e.g. ldap
+	Source string `bson:"source,omitempty" json:"source,omitempty" validate:"-"`
 }
 
 // GetCollection - get model mongo collection name.
 func (u User) GetCollection() string {
 	return UserCollectionName
 }
+
+// UserUpdate is the structure for changing the profile and the role of a user
+// Only the given fields are validated and changed, so the users provisioned without some fields can still be updated
+type UserUpdate struct {
+	DisplayName string `json:"displayName" validate:"-"`
+	Role        string `json:"role" validate:"omitempty,eq=root|eq=user|eq=guest"`
+	FirstName   string `json:"firstName" validate:"-"`
+	LastName    string `json:"lastName" validate:"-"`
+	PhoneNumber string `json:"phoneNumber" validate:"omitempty,numeric"`
+}
+
+// PasswordChange is the structure for a user to change the password
+type PasswordChange struct {
+	OldPassword string `json:"oldPassword" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
+// PasswordReset is the structure for the root role to reset the password of a user
+type PasswordReset struct {
+	NewPassword string `json:"newPassword" validate:"required"`
+}
@@ -0,0 +1,206 @@
+package ipam
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ConflictError is returned when the IP address is already used in the network
+type ConflictError struct {
+	Network string
+	Address string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("the IP address %s of the network %s is already in use", e.Address, e.Network)
+}
+
+// IsConflict will check whether the error is caused by a used IP address
+func IsConflict(err error) bool {
+	_, ok := err.(*ConflictError)
+	return ok
+}
+
+// ValidateSubnets will check the allocation pools and the gateways are in their subnets, and the subnets do not overlap
+func ValidateSubnets(subnets []entity.Subnet) error {
+	ipNets := []*net.IPNet{}
+	for _, subnet := range subnets {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR)
+		if err != nil || ipNet.IP.To4() == nil {
+			return fmt.Errorf("Invalid subnet %s", subnet.CIDR)
+		}
+		for _, other := range ipNets {
+			if other.Contains(ipNet.IP) || ipNet.Contains(other.IP) {
+				return fmt.Errorf("The subnet %s overlaps the subnet %s", subnet.CIDR, other.String())
+			}
+		}
+		ipNets = append(ipNets, ipNet)
+
+		if subnet.Gateway != "" && !contains(ipNet, subnet.Gateway) {
+			return fmt.Errorf("The gateway %s is not in the subnet %s", subnet.Gateway, subnet.CIDR)
+		}
+		for _, pool := range subnet.AllocationPools {
+			if !contains(ipNet, pool.Start) || !contains(ipNet, pool.End) {
+				return fmt.Errorf("The allocation pool %s-%s is not in the subnet %s", pool.Start, pool.End, subnet.CIDR)
+			}
+			if ipToUint32(net.ParseIP(pool.Start)) > ipToUint32(net.ParseIP(pool.End)) {
+				return fmt.Errorf("The start of the allocation pool %s-%s is greater than the end", pool.Start, pool.End)
+			}
+		}
+	}
+	return nil
+}
+
+// Allocate will allocate the first free IP address from the subnets of the network
+func Allocate(session *mongo.Session, network entity.Network, resourceID bson.ObjectId, replica int) (entity.IPAllocation, error) {
+	if err := ensureIndex(session); err != nil {
+		return entity.IPAllocation{}, err
+	}
+
+	allocations := []entity.IPAllocation{}
+	if err := session.FindAll(entity.IPAllocationCollectionName, bson.M{"networkID": network.ID}, &allocations); err != nil {
+		return entity.IPAllocation{}, err
+	}
+	used := map[string]bool{}
+	for _, allocation := range allocations {
+		used[allocation.Address] = true
+	}
+
+	for _, subnet := range network.Subnets {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR)
+		if err != nil {
+			return entity.IPAllocation{}, err
+		}
+		for _, pool := range allocationPools(ipNet, subnet) {
+			for ip := pool[0]; ip <= pool[1] && ip >= pool[0]; ip++ {
+				address := uint32ToIP(ip).String()
+				if used[address] || address == subnet.Gateway {
+					continue
+				}
+				allocation, err := insert(session, network, address, net.IP(ipNet.Mask).String(), resourceID, replica)
+				if IsConflict(err) {
+					// the address is allocated by another request at the same time
+					continue
+				}
+				return allocation, err
+			}
+		}
+	}
+	return entity.IPAllocation{}, fmt.Errorf("no free IP address in the network %s", network.Name)
+}
+
+// Reserve will reserve the static IP address, the address should be in a subnet of the network and not used
+func Reserve(session *mongo.Session, network entity.Network, address string, resourceID bson.ObjectId, replica int) (entity.IPAllocation, error) {
+	if err := ensureIndex(session); err != nil {
+		return entity.IPAllocation{}, err
+	}
+
+	for _, subnet := range network.Subnets {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR)
+		if err != nil {
+			return entity.IPAllocation{}, err
+		}
+		if !contains(ipNet, address) {
+			continue
+		}
+		if address == subnet.Gateway {
+			return entity.IPAllocation{}, &ConflictError{Network: network.Name, Address: address}
+		}
+		return insert(session, network, address, net.IP(ipNet.Mask).String(), resourceID, replica)
+	}
+	return entity.IPAllocation{}, fmt.Errorf("the IP address %s is not in the subnets of the network %s", address, network.Name)
+}
+
+// ReserveStatic will reserve the static IP address in the network without subnets
+// The address can be any IPv4 address with the given netmask, it's only reserved so it's not used twice in the network
+func ReserveStatic(session *mongo.Session, network entity.Network, address string, netmask string, resourceID bson.ObjectId, replica int) (entity.IPAllocation, error) {
+	if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+		return entity.IPAllocation{}, fmt.Errorf("the IP address %s is not a valid IPv4 address", address)
+	}
+	if err := ensureIndex(session); err != nil {
+		return entity.IPAllocation{}, err
+	}
+	return insert(session, network, address, netmask, resourceID, replica)
+}
+
+// Release will release all IP addresses of the pod or the deployment
+func Release(session *mongo.Session, resourceID bson.ObjectId) error {
+	_, err := session.C(entity.IPAllocationCollectionName).RemoveAll(bson.M{"resourceID": resourceID})
+	return err
+}
+
+// ReleaseNetwork will release all IP addresses of the network
+func ReleaseNetwork(session *mongo.Session, networkID bson.ObjectId) error {
+	_, err := session.C(entity.IPAllocationCollectionName).RemoveAll(bson.M{"networkID": networkID})
+	return err
+}
+
+func ensureIndex(session *mongo.Session) error {
+	return session.C(entity.IPAllocationCollectionName).EnsureIndex(mgo.Index{
+		Key:    []string{"networkID", "address"},
+		Unique: true,
+	})
+}
+
+// insert will save the allocation, the unique index makes sure an address is used only once in the network
+func insert(session *mongo.Session, network entity.Network, address string, netmask string, resourceID bson.ObjectId, replica int) (entity.IPAllocation, error) {
+	allocation := entity.IPAllocation{
+		ID:         bson.NewObjectId(),
+		NetworkID:  network.ID,
+		Address:    address,
+		Netmask:    netmask,
+		ResourceID: resourceID,
+		Replica:    replica,
+		CreatedAt:  timeutils.Now(),
+	}
+	if err := session.Insert(entity.IPAllocationCollectionName, &allocation); err != nil {
+		if mgo.IsDup(err) {
+			return entity.IPAllocation{}, &ConflictError{Network: network.Name, Address: address}
+		}
+		return entity.IPAllocation{}, err
+	}
+	return allocation, nil
+}
+
+// allocationPools will return the ranges of the addresses which can be allocated in the subnet
+// The network and broadcast addresses are excluded when the subnet has no allocation pool
+func allocationPools(ipNet *net.IPNet, subnet entity.Subnet) [][2]uint32 {
+	pools := [][2]uint32{}
+	for _, pool := range subnet.AllocationPools {
+		pools = append(pools, [2]uint32{ipToUint32(net.ParseIP(pool.Start)), ipToUint32(net.ParseIP(pool.End))})
+	}
+	if len(pools) > 0 {
+		return pools
+	}
+
+	first := ipToUint32(ipNet.IP)
+	ones, bits := ipNet.Mask.Size()
+	last := first | (1<<uint(bits-ones) - 1)
+	if bits-ones >= 2 {
+		first++
+		last--
+	}
+	return [][2]uint32{{first, last}}
+}
+
+func contains(ipNet *net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ipNet.Contains(ip)
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
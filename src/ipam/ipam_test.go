@@ -0,0 +1,182 @@
+package ipam
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestValidateSubnets(t *testing.T) {
+	testCases := []struct {
+		cases   string
+		subnets []entity.Subnet
+		valid   bool
+	}{
+		{"empty", []entity.Subnet{}, true},
+		{"subnets", []entity.Subnet{
+			{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", AllocationPools: []entity.AllocationPool{{Start: "10.0.0.10", End: "10.0.0.20"}}},
+			{CIDR: "10.0.1.0/24"},
+		}, true},
+		{"invalid CIDR", []entity.Subnet{{CIDR: "10.0.0.0"}}, false},
+		{"overlapped subnets", []entity.Subnet{{CIDR: "10.0.0.0/16"}, {CIDR: "10.0.1.0/24"}}, false},
+		{"gateway out of subnet", []entity.Subnet{{CIDR: "10.0.0.0/24", Gateway: "10.0.1.1"}}, false},
+		{"pool out of subnet", []entity.Subnet{
+			{CIDR: "10.0.0.0/24", AllocationPools: []entity.AllocationPool{{Start: "10.0.0.10", End: "10.0.1.20"}}},
+		}, false},
+		{"reversed pool", []entity.Subnet{
+			{CIDR: "10.0.0.0/24", AllocationPools: []entity.AllocationPool{{Start: "10.0.0.20", End: "10.0.0.10"}}},
+		}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			err := ValidateSubnets(tc.subnets)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAllocationPools(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/24")
+	pools := allocationPools(ipNet, entity.Subnet{CIDR: "10.0.0.0/24"})
+	assert.Equal(t, [][2]uint32{{ipToUint32(net.ParseIP("10.0.0.1")), ipToUint32(net.ParseIP("10.0.0.254"))}}, pools)
+
+	pools = allocationPools(ipNet, entity.Subnet{
+		CIDR:            "10.0.0.0/24",
+		AllocationPools: []entity.AllocationPool{{Start: "10.0.0.10", End: "10.0.0.20"}},
+	})
+	assert.Equal(t, [][2]uint32{{ipToUint32(net.ParseIP("10.0.0.10")), ipToUint32(net.ParseIP("10.0.0.20"))}}, pools)
+
+	// the point-to-point subnet has no network and broadcast addresses
+	_, ipNet, _ = net.ParseCIDR("10.0.0.0/31")
+	pools = allocationPools(ipNet, entity.Subnet{CIDR: "10.0.0.0/31"})
+	assert.Equal(t, [][2]uint32{{ipToUint32(net.ParseIP("10.0.0.0")), ipToUint32(net.ParseIP("10.0.0.1"))}}, pools)
+}
+
+type IPAMTestSuite struct {
+	suite.Suite
+	session *mongo.Session
+	network entity.Network
+}
+
+func (suite *IPAMTestSuite) SetupSuite() {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	suite.session = sp.Mongo.NewSession()
+}
+
+func (suite *IPAMTestSuite) SetupTest() {
+	suite.network = entity.Network{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Subnets: []entity.Subnet{
+			// the addresses are 10.0.0.1 - 10.0.0.6, and 10.0.0.1 is the gateway
+			{CIDR: "10.0.0.0/29", Gateway: "10.0.0.1"},
+			{CIDR: "10.0.1.0/24", AllocationPools: []entity.AllocationPool{{Start: "10.0.1.100", End: "10.0.1.100"}}},
+		},
+	}
+}
+
+func (suite *IPAMTestSuite) TearDownTest() {
+	ReleaseNetwork(suite.session, suite.network.ID)
+}
+
+func (suite *IPAMTestSuite) TearDownSuite() {
+	suite.session.Close()
+}
+
+func TestIPAMSuite(t *testing.T) {
+	suite.Run(t, new(IPAMTestSuite))
+}
+
+func (suite *IPAMTestSuite) TestAllocate() {
+	addresses := []string{}
+	for i := 0; i < 6; i++ {
+		allocation, err := Allocate(suite.session, suite.network, bson.NewObjectId(), 0)
+		suite.NoError(err)
+		addresses = append(addresses, allocation.Address)
+	}
+	suite.Equal([]string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6", "10.0.1.100"}, addresses)
+
+	allocation := entity.IPAllocation{}
+	err := suite.session.FindOne(entity.IPAllocationCollectionName, bson.M{"networkID": suite.network.ID, "address": "10.0.1.100"}, &allocation)
+	suite.NoError(err)
+	suite.Equal("255.255.255.0", allocation.Netmask)
+
+	// all addresses are used
+	_, err = Allocate(suite.session, suite.network, bson.NewObjectId(), 0)
+	suite.Error(err)
+}
+
+func (suite *IPAMTestSuite) TestReserve() {
+	resourceID := bson.NewObjectId()
+	allocation, err := Reserve(suite.session, suite.network, "10.0.0.3", resourceID, 0)
+	suite.NoError(err)
+	suite.Equal("10.0.0.3", allocation.Address)
+	suite.Equal("255.255.255.248", allocation.Netmask)
+
+	// the reserved address is skipped
+	allocation, err = Allocate(suite.session, suite.network, resourceID, 1)
+	suite.NoError(err)
+	suite.Equal("10.0.0.2", allocation.Address)
+	allocation, err = Allocate(suite.session, suite.network, resourceID, 2)
+	suite.NoError(err)
+	suite.Equal("10.0.0.4", allocation.Address)
+
+	_, err = Reserve(suite.session, suite.network, "10.0.0.3", bson.NewObjectId(), 0)
+	suite.True(IsConflict(err))
+	_, err = Reserve(suite.session, suite.network, "10.0.0.1", bson.NewObjectId(), 0)
+	suite.True(IsConflict(err))
+	_, err = Reserve(suite.session, suite.network, "10.0.2.1", bson.NewObjectId(), 0)
+	suite.Error(err)
+	suite.False(IsConflict(err))
+}
+
+func (suite *IPAMTestSuite) TestReserveStatic() {
+	// the network without subnets only keeps the static addresses from being used twice
+	suite.network.Subnets = nil
+	allocation, err := ReserveStatic(suite.session, suite.network, "192.168.0.10", "255.255.255.0", bson.NewObjectId(), 0)
+	suite.NoError(err)
+	suite.Equal("192.168.0.10", allocation.Address)
+	suite.Equal("255.255.255.0", allocation.Netmask)
+
+	_, err = ReserveStatic(suite.session, suite.network, "192.168.0.10", "255.255.255.0", bson.NewObjectId(), 0)
+	suite.True(IsConflict(err))
+	_, err = ReserveStatic(suite.session, suite.network, "192.168.0.256", "255.255.255.0", bson.NewObjectId(), 0)
+	suite.Error(err)
+	suite.False(IsConflict(err))
+}
+
+func (suite *IPAMTestSuite) TestRelease() {
+	resourceID := bson.NewObjectId()
+	for i := 0; i < 2; i++ {
+		_, err := Allocate(suite.session, suite.network, resourceID, i)
+		suite.NoError(err)
+	}
+	other, err := Allocate(suite.session, suite.network, bson.NewObjectId(), 0)
+	suite.NoError(err)
+	suite.Equal("10.0.0.4", other.Address)
+
+	err = Release(suite.session, resourceID)
+	suite.NoError(err)
+	count, err := suite.session.Count(entity.IPAllocationCollectionName, bson.M{"networkID": suite.network.ID})
+	suite.NoError(err)
+	suite.Equal(1, count)
+
+	// the released address can be allocated again
+	allocation, err := Allocate(suite.session, suite.network, bson.NewObjectId(), 0)
+	suite.NoError(err)
+	suite.Equal("10.0.0.2", allocation.Address)
+}
@@ -0,0 +1,18 @@
+package kubernetes
+
+import (
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// ReviewToken will validate the bearer token by the TokenReview API and return the status of the review
+func (kc *KubeCtl) ReviewToken(token string) (*authenticationv1.TokenReviewStatus, error) {
+	review, err := kc.Clientset.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review.Status, nil
+}
@@ -0,0 +1,61 @@
+package kubernetes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type KubeCtlTokenReviewTestSuite struct {
+	suite.Suite
+	kubectl    *KubeCtl
+	fakeclient *fakeclientset.Clientset
+}
+
+func (suite *KubeCtlTokenReviewTestSuite) SetupSuite() {
+	suite.fakeclient = fakeclientset.NewSimpleClientset()
+	suite.fakeclient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "valid-token":
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:default:operator",
+				},
+			}
+		case "error-token":
+			return true, nil, fmt.Errorf("the TokenReview API is unavailable")
+		}
+		return true, review, nil
+	})
+	suite.kubectl = New(suite.fakeclient)
+}
+
+func (suite *KubeCtlTokenReviewTestSuite) TestReviewToken() {
+	status, err := suite.kubectl.ReviewToken("valid-token")
+	suite.NoError(err)
+	suite.True(status.Authenticated)
+	suite.Equal("system:serviceaccount:default:operator", status.User.Username)
+}
+
+func (suite *KubeCtlTokenReviewTestSuite) TestReviewInvalidToken() {
+	status, err := suite.kubectl.ReviewToken("invalid-token")
+	suite.NoError(err)
+	suite.False(status.Authenticated)
+}
+
+func (suite *KubeCtlTokenReviewTestSuite) TestReviewTokenFail() {
+	_, err := suite.kubectl.ReviewToken("error-token")
+	suite.Error(err)
+}
+
+func TestKubeTokenReviewTestSuite(t *testing.T) {
+	suite.Run(t, new(KubeCtlTokenReviewTestSuite))
+}
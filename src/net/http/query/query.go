@@ -76,3 +76,19 @@ func (query *QueryUrl) Str(key string) (string, bool) {
 
 	return values[0], true
 }
+
+// Bool is a function for bool
+func (query *QueryUrl) Bool(key string, defaultValue bool) (bool, error) {
+	values := query.Url[key]
+
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+
+	val, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return defaultValue, err
+	}
+
+	return val, nil
+}
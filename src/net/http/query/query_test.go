@@ -101,3 +101,36 @@ func TestStrByDefault(t *testing.T) {
 	assert.False(t, ok)
 	assert.Equal(t, "", v)
 }
+
+func TestBool(t *testing.T) {
+	req, err := http.NewRequest("GET", "/test?Hey=true", nil)
+	assert.NoError(t, err)
+
+	q := New(req.URL.Query())
+
+	v, err := q.Bool("Hey", false)
+	assert.NoError(t, err)
+	assert.True(t, v)
+}
+
+func TestBoolByDefault(t *testing.T) {
+	req, err := http.NewRequest("GET", "/test", nil)
+	assert.NoError(t, err)
+
+	q := New(req.URL.Query())
+
+	v, err := q.Bool("notfound", true)
+	assert.NoError(t, err)
+	assert.True(t, v)
+}
+
+func TestBoolFail(t *testing.T) {
+	req, err := http.NewRequest("GET", "/test?Hey=YoYo", nil)
+	assert.NoError(t, err)
+
+	q := New(req.URL.Query())
+
+	v, err := q.Bool("Hey", false)
+	assert.Error(t, err)
+	assert.False(t, v)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"net/http"
+
+	"github.com/hwchiu/vortex/src/entity"
 )
 
 // ErrorPayload is the Structure to contain the Error Message from the HTTP response
@@ -21,6 +23,25 @@ type ActionResponse struct {
 	Message string `json:"message"`
 }
 
+// TokenResponse is the structure for the response of signing in and refreshing token
+// The message is the access token, so it's compatible with the ActionResponse
+type TokenResponse struct {
+	Error        bool   `json:"error"`
+	Message      string `json:"message"`
+	RefreshToken string `json:"refreshToken"`
+	// the lifetime in seconds of the access token
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+// NodesErrorResponse is the structure for the response of the errors on the nodes
+// It's compatible with the ActionResponse
+type NodesErrorResponse struct {
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+	// the status of each node
+	Nodes []entity.NetworkNodeStatus `json:"nodes"`
+}
+
 // NewErrorPayload will return the ErrorPayload message according the parameters (errors)
 // The ErrorPayload contains at most error messages
 func NewErrorPayload(errs ...error) ErrorPayload {
@@ -112,3 +133,8 @@ func MethodNotAllow(req *http.Request, resp http.ResponseWriter, errs ...error)
 func UnprocessableEntity(req *http.Request, resp http.ResponseWriter, errs ...error) (int, error) {
 	return WriteStatusAndError(req, resp, http.StatusUnprocessableEntity, errs...)
 }
+
+// TooManyRequests will set the status code http.StatusTooManyRequests to the HTTP response message
+func TooManyRequests(req *http.Request, resp http.ResponseWriter, errs ...error) (int, error) {
+	return WriteStatusAndError(req, resp, http.StatusTooManyRequests, errs...)
+}
@@ -87,6 +87,7 @@ func TestSetStatus(t *testing.T) {
 		{"Conflict", Conflict, http.StatusConflict},
 		{"UnprocessableEntity", UnprocessableEntity, http.StatusUnprocessableEntity},
 		{"MethodNotAllow", MethodNotAllow, http.StatusMethodNotAllowed},
+		{"TooManyRequests", TooManyRequests, http.StatusTooManyRequests},
 	}
 
 	for _, tc := range testCases {
@@ -7,6 +7,8 @@ import (
 	"github.com/hwchiu/vortex/src/entity"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // DEFAULT_CONTROLLER_PORT set the default port as 50051
@@ -16,9 +18,12 @@ const DEFAULT_CONTROLLER_PORT = "50051"
 type NetworkController struct {
 	ClientCtl pb.NetworkControlClient
 	Context   context.Context
+	conn      *grpc.ClientConn
+	cancel    context.CancelFunc
 }
 
 // New will Set up a connection to the Network Controller server
+// The connection should be closed by Close after using it
 func New(serverAddress string) (*NetworkController, error) {
 	// Set up a connection to the server.
 	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
@@ -26,14 +31,27 @@ func New(serverAddress string) (*NetworkController, error) {
 		return nil, err
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 
 	return &NetworkController{
 		ClientCtl: pb.NewNetworkControlClient(conn),
 		Context:   ctx,
+		conn:      conn,
+		cancel:    cancel,
 	}, nil
 }
 
+// Close will cancel the context and close the connection to the Network Controller server
+func (nc *NetworkController) Close() error {
+	if nc.cancel != nil {
+		nc.cancel()
+	}
+	if nc.conn == nil {
+		return nil
+	}
+	return nc.conn.Close()
+}
+
 // CreateOVSNetwork will Create OVS Network by Network Controller
 func (nc *NetworkController) CreateOVSNetwork(datapathType string, bridgeName string, phyIfaces []entity.PhyInterface, vlanTags []int32) error {
 	if _, err := nc.ClientCtl.CreateBridge(
@@ -46,27 +64,11 @@ func (nc *NetworkController) CreateOVSNetwork(datapathType string, bridgeName st
 	}
 
 	for _, phyIface := range phyIfaces {
-		_, err := nc.ClientCtl.AddPort(
-			nc.Context,
-			&pb.AddPortRequest{
-				BridgeName: bridgeName,
-				IfaceName:  phyIface.Name,
-			})
-		if err != nil {
+		if err := nc.AddOVSPort(bridgeName, phyIface, false); err != nil {
 			return err
 		}
-
 		if len(vlanTags) > 0 {
-			_, err := nc.ClientCtl.SetPort(
-				nc.Context,
-				&pb.SetPortRequest{
-					IfaceName: phyIface.Name,
-					Options: &pb.PortOptions{
-						VLANMode: "trunk",
-						Trunk:    vlanTags,
-					},
-				})
-			if err != nil {
+			if err := nc.SetOVSPortTrunk(phyIface.Name, vlanTags); err != nil {
 				return err
 			}
 		}
@@ -86,28 +88,11 @@ func (nc *NetworkController) CreateOVSDPDKNetwork(bridgeName string, phyIfaces [
 	}
 
 	for _, phyIface := range phyIfaces {
-		_, err := nc.ClientCtl.AddDPDKPort(
-			nc.Context,
-			&pb.AddPortRequest{
-				BridgeName:  bridgeName,
-				IfaceName:   phyIface.Name,
-				DpdkDevargs: phyIface.PCIID,
-			})
-		if err != nil {
+		if err := nc.AddOVSPort(bridgeName, phyIface, true); err != nil {
 			return err
 		}
-
 		if len(vlanTags) > 0 {
-			_, err := nc.ClientCtl.SetPort(
-				nc.Context,
-				&pb.SetPortRequest{
-					IfaceName: phyIface.Name,
-					Options: &pb.PortOptions{
-						VLANMode: "trunk",
-						Trunk:    vlanTags,
-					},
-				})
-			if err != nil {
+			if err := nc.SetOVSPortTrunk(phyIface.Name, vlanTags); err != nil {
 				return err
 			}
 		}
@@ -115,6 +100,52 @@ func (nc *NetworkController) CreateOVSDPDKNetwork(bridgeName string, phyIfaces [
 	return nil
 }
 
+// AddOVSPort will add the physical interface to the bridge, the DPDK port is added by its PCI ID
+func (nc *NetworkController) AddOVSPort(bridgeName string, phyIface entity.PhyInterface, isDPDK bool) error {
+	if isDPDK {
+		_, err := nc.ClientCtl.AddDPDKPort(
+			nc.Context,
+			&pb.AddPortRequest{
+				BridgeName:  bridgeName,
+				IfaceName:   phyIface.Name,
+				DpdkDevargs: phyIface.PCIID,
+			})
+		return err
+	}
+	_, err := nc.ClientCtl.AddPort(
+		nc.Context,
+		&pb.AddPortRequest{
+			BridgeName: bridgeName,
+			IfaceName:  phyIface.Name,
+		})
+	return err
+}
+
+// SetOVSPortTrunk will set the VLAN trunk of the port, the port trunks all VLANs without the VLAN tags
+func (nc *NetworkController) SetOVSPortTrunk(ifaceName string, vlanTags []int32) error {
+	_, err := nc.ClientCtl.SetPort(
+		nc.Context,
+		&pb.SetPortRequest{
+			IfaceName: ifaceName,
+			Options: &pb.PortOptions{
+				VLANMode: "trunk",
+				Trunk:    vlanTags,
+			},
+		})
+	return err
+}
+
+// DeleteOVSPort will remove the port from the bridge
+func (nc *NetworkController) DeleteOVSPort(bridgeName string, ifaceName string) error {
+	_, err := nc.ClientCtl.DeletePort(
+		nc.Context,
+		&pb.DeletePortRequest{
+			BridgeName: bridgeName,
+			IfaceName:  ifaceName,
+		})
+	return err
+}
+
 // DeleteOVSNetwork will delete OVS network controller
 func (nc *NetworkController) DeleteOVSNetwork(bridgeName string) error {
 	_, err := nc.ClientCtl.DeleteBridge(
@@ -141,3 +172,11 @@ func (nc *NetworkController) DumpOVSPorts(bridgeName string) ([]*pb.PortInfo, er
 
 	return data.Ports, nil
 }
+
+// IsUnavailable will check whether the error is caused by the unreachable Network Controller
+func IsUnavailable(err error) bool {
+	if s, ok := status.FromError(err); ok {
+		return s.Code() == codes.Unavailable || s.Code() == codes.DeadlineExceeded
+	}
+	return false
+}
@@ -24,3 +24,10 @@ func (fnp fakeNetworkProvider) DeleteNetwork(sp *serviceprovider.Container) erro
 	}
 	return nil
 }
+
+func (fnp fakeNetworkProvider) UpdateNetwork(sp *serviceprovider.Container, updated entity.Network) error {
+	if !fnp.IsDPDKPort {
+		return fmt.Errorf("fail to update network but don't worry, I'm fake network")
+	}
+	return nil
+}
@@ -45,3 +45,22 @@ func TestFakeNetworkDeleteFail(t *testing.T) {
 	err = fake.DeleteNetwork(nil)
 	assert.Error(t, err)
 }
+
+func TestFakeNetworkUpdate(t *testing.T) {
+	fake, err := GetNetworkProvider(&entity.Network{
+		IsDPDKPort: true, // for fake testing
+		Type:       entity.FakeNetworkType,
+	})
+	assert.NoError(t, err)
+	err = fake.UpdateNetwork(nil, entity.Network{})
+	assert.NoError(t, err)
+}
+
+func TestFakeNetworkUpdateFail(t *testing.T) {
+	fake, err := GetNetworkProvider(&entity.Network{
+		Type: entity.FakeNetworkType,
+	})
+	assert.NoError(t, err)
+	err = fake.UpdateNetwork(nil, entity.Network{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package networkprovider
+
+import (
+	"sync"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// networkLock is the lock of a network and the number of its holders and waiters
+type networkLock struct {
+	sync.Mutex
+	refs int
+}
+
+// the locks of the networks which are being changed, they are removed when nobody holds or waits for them
+var (
+	networkLocksMutex sync.Mutex
+	networkLocks      = map[bson.ObjectId]*networkLock{}
+)
+
+// LockNetwork will lock the network by its ID and return the function to unlock it
+// The requests and the reconciler lock the network before reading and changing it, so they never change the nodes at the same time
+// It only works in the same process, the networks are not locked between the replicas of the server
+func LockNetwork(id bson.ObjectId) func() {
+	networkLocksMutex.Lock()
+	lock, ok := networkLocks[id]
+	if !ok {
+		lock = &networkLock{}
+		networkLocks[id] = lock
+	}
+	lock.refs++
+	networkLocksMutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		networkLocksMutex.Lock()
+		defer networkLocksMutex.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(networkLocks, id)
+		}
+	}
+}
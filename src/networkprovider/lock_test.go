@@ -0,0 +1,45 @@
+package networkprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestLockNetwork(t *testing.T) {
+	id := bson.NewObjectId()
+	unlock := LockNetwork(id)
+
+	// the other networks are not blocked
+	LockNetwork(bson.NewObjectId())()
+
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		unlock := LockNetwork(id)
+		close(locked)
+		unlock()
+		close(done)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the network is locked twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("the network is not unlocked")
+	}
+
+	// the lock is removed when nobody holds it
+	<-done
+	networkLocksMutex.Lock()
+	defer networkLocksMutex.Unlock()
+	assert.NotContains(t, networkLocks, id)
+}
@@ -2,22 +2,40 @@ package networkprovider
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hwchiu/vortex/src/entity"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/hwchiu/vortex/src/utils"
+	"gopkg.in/mgo.v2/bson"
 )
 
+// PendingTimeout is the longest time to create a network on the nodes
+// The network which is pending for longer is left by a failed request, e.g. the server crashed, and it can be deleted
+const PendingTimeout = 10 * time.Minute
+
 // NetworkProvider is the structure for Network Provider
 type NetworkProvider interface {
 	CreateNetwork(sp *serviceprovider.Container) error
 	DeleteNetwork(sp *serviceprovider.Container) error
+	// UpdateNetwork will change the network on the nodes to the updated network
+	UpdateNetwork(sp *serviceprovider.Container, updated entity.Network) error
+}
+
+// nodeNetworkProvider is the network provider which can create and delete the network on a single node
+type nodeNetworkProvider interface {
+	createNodeNetwork(sp *serviceprovider.Container, node entity.Node) error
+	deleteNodeNetwork(sp *serviceprovider.Container, node entity.Node) error
 }
 
 // GetNetworkProvider will get network provider if you gave *entity.Network
 func GetNetworkProvider(network *entity.Network) (NetworkProvider, error) {
 	switch network.Type {
 	case entity.OVSKernelspaceNetworkType:
+		if network.IsDPDKPort {
+			return nil, fmt.Errorf("the DPDK ports are only supported by the %s network", entity.OVSUserspaceNetworkType)
+		}
 		return kernelspaceNetworkProvider{
 			*network,
 		}, nil
@@ -34,9 +52,41 @@ func GetNetworkProvider(network *entity.Network) (NetworkProvider, error) {
 	}
 }
 
+// IsCreating will check whether the network is still being created on the nodes by a request
+func IsCreating(network entity.Network) bool {
+	return network.Pending && network.PendingAt != nil && time.Since(*network.PendingAt) < PendingTimeout
+}
+
+// NotCreatingSelector will return the mongo selector of the networks which are not being created on the nodes
+// The stale pending networks are selected, so they can be found and deleted
+func NotCreatingSelector() bson.M {
+	return bson.M{"$nor": []bson.M{{
+		"pending":   true,
+		"pendingAt": bson.M{"$gt": time.Now().Add(-PendingTimeout)},
+	}}}
+}
+
 // GenerateBridgeName will generate bridge name
 func GenerateBridgeName(datapathType, networkName string) string {
 	tmp := fmt.Sprintf("%s%s", datapathType, networkName)
 	str := utils.SHA256String(tmp)
 	return fmt.Sprintf("%s-%s", datapathType, str[0:6])
 }
+
+// NodesError is the per-node report of the failed network operation
+type NodesError struct {
+	// the nodes are not called since the request is invalid
+	Invalid bool
+	// the status of each node
+	Nodes []entity.NetworkNodeStatus
+}
+
+func (e *NodesError) Error() string {
+	messages := []string{}
+	for _, node := range e.Nodes {
+		if node.Status == entity.NodeFailed {
+			messages = append(messages, fmt.Sprintf("%s: %s", node.Name, node.Error))
+		}
+	}
+	return fmt.Sprintf("failed on %d nodes: %s", len(messages), strings.Join(messages, "; "))
+}
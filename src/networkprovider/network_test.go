@@ -38,9 +38,23 @@ func TestGetNetworkProviderFail(t *testing.T) {
 			Type: "Unknown",
 		})
 	assert.Error(t, err)
+
+	// the DPDK ports need the userspace datapath
+	_, err = GetNetworkProvider(
+		&entity.Network{
+			Type:       entity.OVSKernelspaceNetworkType,
+			IsDPDKPort: true,
+		})
+	assert.Error(t, err)
 }
 
-func TestGenerateBridgeName(t *testing.T) {
-	ans := GenerateBridgeName("netdev", "my network 1")
-	assert.Equal(t, "netdev-de0165", ans)
+func TestNodesError(t *testing.T) {
+	err := &NodesError{
+		Nodes: []entity.NetworkNodeStatus{
+			{Name: "node-a", Status: entity.NodeFailed, Error: "the NIC is not found"},
+			{Name: "node-b", Status: entity.NodeRolledBack},
+			{Name: "node-c", Status: entity.NodeFailed, Error: "connection refused"},
+		},
+	}
+	assert.Equal(t, "failed on 2 nodes: node-a: the NIC is not found; node-c: connection refused", err.Error())
 }
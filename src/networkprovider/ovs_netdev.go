@@ -1,10 +1,12 @@
 package networkprovider
 
 import (
+	"fmt"
 	"net"
 
 	"github.com/hwchiu/vortex/src/entity"
 	"github.com/hwchiu/vortex/src/networkcontroller"
+	pc "github.com/hwchiu/vortex/src/prometheuscontroller"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 )
 
@@ -13,45 +15,106 @@ type userspaceNetworkProvider struct {
 }
 
 func (unp userspaceNetworkProvider) CreateNetwork(sp *serviceprovider.Container) error {
-	for _, node := range unp.Nodes {
-		nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
-		if err != nil {
+	if unp.IsDPDKPort {
+		if err := unp.validateDPDKInterfaces(sp); err != nil {
 			return err
 		}
-		if unp.IsDPDKPort {
-			if err := createOVSDPDKNetwork(
-				nodeIP,
-				unp.BridgeName,
-				node.PhyInterfaces,
-				unp.VlanTags,
-			); err != nil {
-				return err
-			}
-		} else {
-			if err := createOVSUserspaceNetwork(
-				nodeIP,
-				unp.BridgeName,
-				node.PhyInterfaces,
-				unp.VlanTags,
-			); err != nil {
-				return err
-			}
-		}
 	}
-	return nil
+	return applyNodes(sp, unp.Nodes, unp.createNodeNetwork, unp.deleteNodeNetwork)
 }
 
 func (unp userspaceNetworkProvider) DeleteNetwork(sp *serviceprovider.Container) error {
-	for _, node := range unp.Nodes {
-		nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
-		if err != nil {
+	return applyNodes(sp, unp.Nodes, unp.deleteNodeNetwork, unp.createNodeNetwork)
+}
+
+func (unp userspaceNetworkProvider) UpdateNetwork(sp *serviceprovider.Container, updated entity.Network) error {
+	// the interfaces are checked by the settings of the updated network, not the stored one
+	if updated.IsDPDKPort {
+		if err := (userspaceNetworkProvider{updated}).validateDPDKInterfaces(sp); err != nil {
 			return err
 		}
-		if err := deleteOVSUserspaceNetwork(
+	}
+	return updateOVSNetwork(sp, unp.Network, updated)
+}
+
+func (unp userspaceNetworkProvider) createNodeNetwork(sp *serviceprovider.Container, node entity.Node) error {
+	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
+	if err != nil {
+		return err
+	}
+	if unp.IsDPDKPort {
+		return createOVSDPDKNetwork(
 			nodeIP,
 			unp.BridgeName,
-		); err != nil {
-			return err
+			node.PhyInterfaces,
+			unp.VlanTags,
+		)
+	}
+	return createOVSUserspaceNetwork(
+		nodeIP,
+		unp.BridgeName,
+		node.PhyInterfaces,
+		unp.VlanTags,
+	)
+}
+
+func (unp userspaceNetworkProvider) deleteNodeNetwork(sp *serviceprovider.Container, node entity.Node) error {
+	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
+	if err != nil {
+		return err
+	}
+	return deleteOVSUserspaceNetwork(
+		nodeIP,
+		unp.BridgeName,
+	)
+}
+
+// validateDPDKInterfaces will check the physical interfaces of all nodes by the NICs reported to the prometheus
+// The nodes are not called if any interface is invalid
+func (unp userspaceNetworkProvider) validateDPDKInterfaces(sp *serviceprovider.Container) error {
+	failed := false
+	statuses := []entity.NetworkNodeStatus{}
+	for _, node := range unp.Nodes {
+		nicList, err := pc.ListNodeNICs(sp, node.Name)
+		if err != nil {
+			return fmt.Errorf("fail to list the NICs of the node %s: %v", node.Name, err)
+		}
+		status := entity.NetworkNodeStatus{
+			Name:   node.Name,
+			Status: entity.NodeSkipped,
+		}
+		if err := checkDPDKInterfaces(node.PhyInterfaces, nicList); err != nil {
+			failed = true
+			status.Status = entity.NodeFailed
+			status.Error = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	if failed {
+		return &NodesError{Invalid: true, Nodes: statuses}
+	}
+	return nil
+}
+
+// checkDPDKInterfaces will check each physical interface has the PCI ID of a DPDK NIC
+func checkDPDKInterfaces(phyIfaces []entity.PhyInterface, nicList entity.NodeNICsMetrics) error {
+	dpdkNICs := map[string]bool{}
+	for _, nic := range nicList.NICs {
+		if nic.PCIID != "" {
+			dpdkNICs[nic.PCIID] = nic.DPDK
+		}
+	}
+
+	for _, phyIface := range phyIfaces {
+		if phyIface.PCIID == "" {
+			return fmt.Errorf("the PCI ID of the interface %s is required for the DPDK port", phyIface.Name)
+		}
+		dpdk, ok := dpdkNICs[phyIface.PCIID]
+		if !ok {
+			return fmt.Errorf("the NIC of the PCI ID %s is not found", phyIface.PCIID)
+		}
+		if !dpdk {
+			return fmt.Errorf("the NIC of the PCI ID %s is not bound to the DPDK", phyIface.PCIID)
 		}
 	}
 	return nil
@@ -63,6 +126,7 @@ func createOVSDPDKNetwork(nodeIP string, bridgeName string, phyIfaces []entity.P
 	if err != nil {
 		return err
 	}
+	defer nc.Close()
 	return nc.CreateOVSDPDKNetwork(bridgeName, phyIfaces, vlanTags)
 }
 
@@ -72,6 +136,7 @@ func createOVSUserspaceNetwork(nodeIP string, bridgeName string, phyIfaces []ent
 	if err != nil {
 		return err
 	}
+	defer nc.Close()
 	return nc.CreateOVSNetwork("netdev", bridgeName, phyIfaces, vlanTags)
 }
 
@@ -81,5 +146,6 @@ func deleteOVSUserspaceNetwork(nodeIP string, bridgeName string) error {
 	if err != nil {
 		return err
 	}
+	defer nc.Close()
 	return nc.DeleteOVSNetwork(bridgeName)
 }
@@ -14,6 +14,7 @@ import (
 	kc "github.com/hwchiu/vortex/src/kubernetes"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	corev1 "k8s.io/api/core/v1"
@@ -238,3 +239,37 @@ func (suite *OVSNetdevNetworkTestSuite) TestDeleteNetworkFail() {
 	err = np.DeleteNetwork(suite.sp)
 	suite.Error(err)
 }
+
+func TestCheckDPDKInterfaces(t *testing.T) {
+	nicList := entity.NodeNICsMetrics{
+		NICs: []entity.NICOverviewMetrics{
+			{Name: "eth0", PCIID: "0000:00:03.0", DPDK: false},
+			{Name: "eth1", PCIID: "0000:00:08.0", DPDK: true},
+			{Name: "eth2", PCIID: "0000:00:09.0", DPDK: true},
+			{Name: "lo"},
+		},
+	}
+
+	testCases := []struct {
+		cases    string
+		ifaces   []entity.PhyInterface
+		hasError bool
+	}{
+		{"dpdk", []entity.PhyInterface{{Name: "eth1", PCIID: "0000:00:08.0"}, {Name: "eth2", PCIID: "0000:00:09.0"}}, false},
+		{"noInterface", []entity.PhyInterface{}, false},
+		{"noPCIID", []entity.PhyInterface{{Name: "lo"}}, true},
+		{"notFound", []entity.PhyInterface{{Name: "eth3", PCIID: "0000:00:0a.0"}}, true},
+		{"notDPDK", []entity.PhyInterface{{Name: "eth0", PCIID: "0000:00:03.0"}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			err := checkDPDKInterfaces(tc.ifaces, nicList)
+			if tc.hasError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -13,37 +13,39 @@ type kernelspaceNetworkProvider struct {
 }
 
 func (knp kernelspaceNetworkProvider) CreateNetwork(sp *serviceprovider.Container) error {
-	for _, node := range knp.Nodes {
-		nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
-		if err != nil {
-			return err
-		}
-		if err := createOVSNetwork(
-			nodeIP,
-			knp.BridgeName,
-			node.PhyInterfaces,
-			knp.VlanTags,
-		); err != nil {
-			return err
-		}
-	}
-	return nil
+	return applyNodes(sp, knp.Nodes, knp.createNodeNetwork, knp.deleteNodeNetwork)
 }
 
 func (knp kernelspaceNetworkProvider) DeleteNetwork(sp *serviceprovider.Container) error {
-	for _, node := range knp.Nodes {
-		nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
-		if err != nil {
-			return err
-		}
-		if err := deleteOVSNetwork(
-			nodeIP,
-			knp.BridgeName,
-		); err != nil {
-			return err
-		}
+	return applyNodes(sp, knp.Nodes, knp.deleteNodeNetwork, knp.createNodeNetwork)
+}
+
+func (knp kernelspaceNetworkProvider) UpdateNetwork(sp *serviceprovider.Container, updated entity.Network) error {
+	return updateOVSNetwork(sp, knp.Network, updated)
+}
+
+func (knp kernelspaceNetworkProvider) createNodeNetwork(sp *serviceprovider.Container, node entity.Node) error {
+	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
+	if err != nil {
+		return err
+	}
+	return createOVSNetwork(
+		nodeIP,
+		knp.BridgeName,
+		node.PhyInterfaces,
+		knp.VlanTags,
+	)
+}
+
+func (knp kernelspaceNetworkProvider) deleteNodeNetwork(sp *serviceprovider.Container, node entity.Node) error {
+	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(node.Name)
+	if err != nil {
+		return err
 	}
-	return nil
+	return deleteOVSNetwork(
+		nodeIP,
+		knp.BridgeName,
+	)
 }
 
 func createOVSNetwork(nodeIP string, bridgeName string, phyIfaces []entity.PhyInterface, vlanTags []int32) error {
@@ -52,6 +54,7 @@ func createOVSNetwork(nodeIP string, bridgeName string, phyIfaces []entity.PhyIn
 	if err != nil {
 		return err
 	}
+	defer nc.Close()
 	return nc.CreateOVSNetwork("system", bridgeName, phyIfaces, vlanTags)
 }
 
@@ -61,5 +64,6 @@ func deleteOVSNetwork(nodeIP string, bridgeName string) error {
 	if err != nil {
 		return err
 	}
+	defer nc.Close()
 	return nc.DeleteOVSNetwork(bridgeName)
 }
@@ -0,0 +1,290 @@
+package networkprovider
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/networkcontroller"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/logger"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// DefaultReconcileInterval is the default interval between the checks of the networks
+const DefaultReconcileInterval = 5 * time.Minute
+
+// Reconciler checks the networks on the nodes periodically, and repairs the drifted nodes in the auto repair mode
+type Reconciler struct {
+	sp         *serviceprovider.Container
+	Interval   time.Duration
+	AutoRepair bool
+	stop       chan struct{}
+}
+
+// NewReconciler will create the network reconciler from the config
+func NewReconciler(sp *serviceprovider.Container, cf *config.NetworkReconcilerConfig) (*Reconciler, error) {
+	r := &Reconciler{
+		sp:         sp,
+		Interval:   DefaultReconcileInterval,
+		AutoRepair: cf.AutoRepair,
+		stop:       make(chan struct{}),
+	}
+	if cf.Interval != "" {
+		interval, err := time.ParseDuration(cf.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid reconcile interval %s: %v", cf.Interval, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("The reconcile interval should be positive: %s", cf.Interval)
+		}
+		r.Interval = interval
+	}
+	return r, nil
+}
+
+// Start will check the networks every interval in the background until it's stopped
+func (r *Reconciler) Start() {
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.ReconcileAll(); err != nil {
+					logger.Warnf("fail to reconcile the networks: %v", err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop will stop the background checks
+func (r *Reconciler) Stop() {
+	close(r.stop)
+}
+
+// ReconcileAll will check all OVS networks and save their status
+func (r *Reconciler) ReconcileAll() error {
+	session := r.sp.Mongo.NewSession()
+	defer session.Close()
+
+	networks := []entity.Network{}
+	if err := session.FindAll(
+		entity.NetworkCollectionName,
+		// the pending networks are still being created by the requests
+		bson.M{
+			"type":    bson.M{"$in": []entity.NetworkType{entity.OVSKernelspaceNetworkType, entity.OVSUserspaceNetworkType}},
+			"pending": bson.M{"$ne": true},
+		},
+		&networks,
+	); err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		r.reconcile(network.ID)
+	}
+	return nil
+}
+
+// reconcile will lock the network and read it again, so the network changed or deleted by the requests is never repaired with its old spec
+func (r *Reconciler) reconcile(id bson.ObjectId) {
+	unlock := LockNetwork(id)
+	defer unlock()
+
+	session := r.sp.Mongo.NewSession()
+	defer session.Close()
+
+	network := entity.Network{}
+	if err := session.FindOne(
+		entity.NetworkCollectionName,
+		bson.M{"_id": id, "pending": bson.M{"$ne": true}},
+		&network,
+	); err != nil {
+		if err != mgo.ErrNotFound {
+			logger.Warnf("fail to read the network %s: %v", id.Hex(), err)
+		}
+		return
+	}
+
+	status, err := ReconcileNetwork(r.sp, network, r.AutoRepair)
+	if err != nil {
+		logger.Warnf("fail to reconcile the network %s: %v", network.Name, err)
+		return
+	}
+	if status.Drifted {
+		logger.Warnf("the network %s is drifted on the nodes", network.Name)
+	}
+}
+
+// ReconcileNetwork will check the network on its nodes, repair the drifted nodes if repair is true, and save the status
+func ReconcileNetwork(sp *serviceprovider.Container, network entity.Network, repair bool) (entity.NetworkStatus, error) {
+	status, err := CheckNetwork(sp, network)
+	if err != nil {
+		return entity.NetworkStatus{}, err
+	}
+	if repair && status.Drifted {
+		RepairNetwork(sp, network, &status)
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+	if _, err := session.C(entity.NetworkStatusCollectionName).UpsertId(network.ID, &status); err != nil {
+		return entity.NetworkStatus{}, err
+	}
+	return status, nil
+}
+
+// CheckNetwork will compare the bridge and the ports of the network with the nodes
+func CheckNetwork(sp *serviceprovider.Container, network entity.Network) (entity.NetworkStatus, error) {
+	if network.Type != entity.OVSKernelspaceNetworkType && network.Type != entity.OVSUserspaceNetworkType {
+		return entity.NetworkStatus{}, fmt.Errorf("the network type %s can not be checked", network.Type)
+	}
+
+	var lock sync.Mutex
+	nodeStatuses := map[string]entity.NodeNetworkStatus{}
+	runNodes(sp, network.Nodes, maxNodeWorkers, func(sp *serviceprovider.Container, node entity.Node) error {
+		nodeStatus := checkNodeNetwork(sp, network.BridgeName, node)
+		lock.Lock()
+		defer lock.Unlock()
+		nodeStatuses[node.Name] = nodeStatus
+		return nil
+	})
+
+	status := entity.NetworkStatus{
+		ID:        network.ID,
+		Nodes:     []entity.NodeNetworkStatus{},
+		CheckedAt: timeutils.Now(),
+	}
+	for _, node := range network.Nodes {
+		nodeStatus := nodeStatuses[node.Name]
+		status.Drifted = status.Drifted || nodeStatus.Drifted
+		status.Nodes = append(status.Nodes, nodeStatus)
+	}
+	return status, nil
+}
+
+// RepairNetwork will recreate the missing bridges, ports and VLAN trunks on the drifted nodes
+func RepairNetwork(sp *serviceprovider.Container, network entity.Network, status *entity.NetworkStatus) {
+	indexes := map[string]int{}
+	for i, nodeStatus := range status.Nodes {
+		indexes[nodeStatus.Name] = i
+	}
+	drifted := []entity.Node{}
+	for _, node := range network.Nodes {
+		if i, ok := indexes[node.Name]; ok && status.Nodes[i].Drifted {
+			drifted = append(drifted, node)
+		}
+	}
+
+	errs := runNodes(sp, drifted, maxNodeWorkers, func(sp *serviceprovider.Container, node entity.Node) error {
+		return repairNodeNetwork(sp, network, node, status.Nodes[indexes[node.Name]])
+	})
+	for i, node := range drifted {
+		nodeStatus := &status.Nodes[indexes[node.Name]]
+		if errs[i] != nil {
+			nodeStatus.Error = errs[i].Error()
+		} else {
+			nodeStatus.Repaired = true
+		}
+	}
+}
+
+// checkNodeNetwork will dump the ports of the bridge on the node, the bridge is missing if it can not be dumped
+func checkNodeNetwork(sp *serviceprovider.Container, bridgeName string, node entity.Node) entity.NodeNetworkStatus {
+	nc, err := newNodeController(sp, node.Name)
+	if err != nil {
+		return entity.NodeNetworkStatus{Name: node.Name, MissingPorts: []string{}, Error: err.Error()}
+	}
+	defer nc.Close()
+
+	ports, err := nc.DumpOVSPorts(bridgeName)
+	if err != nil {
+		if networkcontroller.IsUnavailable(err) {
+			return entity.NodeNetworkStatus{Name: node.Name, MissingPorts: []string{}, Error: err.Error()}
+		}
+		return diffNodeNetwork(node, false, nil)
+	}
+
+	portNames := []string{}
+	for _, port := range ports {
+		portNames = append(portNames, port.Name)
+	}
+	return diffNodeNetwork(node, true, portNames)
+}
+
+// diffNodeNetwork will find the physical interfaces which are not the ports of the bridge
+// The other ports on the bridge, e.g. the veths of the pods, are ignored
+// Only the port names are compared, the network controller does not dump the VLAN trunks and the interface types of the ports
+func diffNodeNetwork(node entity.Node, bridgeExists bool, portNames []string) entity.NodeNetworkStatus {
+	status := entity.NodeNetworkStatus{
+		Name:         node.Name,
+		BridgeExists: bridgeExists,
+		MissingPorts: []string{},
+	}
+
+	ports := map[string]bool{}
+	for _, name := range portNames {
+		ports[name] = true
+	}
+	for _, phyIface := range node.PhyInterfaces {
+		if !ports[phyIface.Name] {
+			status.MissingPorts = append(status.MissingPorts, phyIface.Name)
+		}
+	}
+	status.Drifted = !bridgeExists || len(status.MissingPorts) > 0
+	return status
+}
+
+// repairNodeNetwork will create the whole network if the bridge is missing, or add the missing ports with the VLAN trunks
+func repairNodeNetwork(sp *serviceprovider.Container, network entity.Network, node entity.Node, status entity.NodeNetworkStatus) error {
+	if !status.BridgeExists {
+		provider, err := getNodeNetworkProvider(network)
+		if err != nil {
+			return err
+		}
+		return provider.createNodeNetwork(sp, node)
+	}
+
+	nc, err := newNodeController(sp, node.Name)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	missing := map[string]bool{}
+	for _, name := range status.MissingPorts {
+		missing[name] = true
+	}
+	for _, phyIface := range node.PhyInterfaces {
+		if !missing[phyIface.Name] {
+			continue
+		}
+		if err := nc.AddOVSPort(network.BridgeName, phyIface, network.IsDPDKPort); err != nil {
+			return err
+		}
+		if len(network.VlanTags) > 0 {
+			if err := nc.SetOVSPortTrunk(phyIface.Name, network.VlanTags); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newNodeController will connect to the Network Controller of the node, the caller should close it
+func newNodeController(sp *serviceprovider.Container, nodeName string) (*networkcontroller.NetworkController, error) {
+	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	nodeAddr := net.JoinHostPort(nodeIP, networkcontroller.DEFAULT_CONTROLLER_PORT)
+	return networkcontroller.New(nodeAddr)
+}
@@ -0,0 +1,65 @@
+package networkprovider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReconciler(t *testing.T) {
+	r, err := NewReconciler(nil, &config.NetworkReconcilerConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultReconcileInterval, r.Interval)
+	assert.False(t, r.AutoRepair)
+
+	r, err = NewReconciler(nil, &config.NetworkReconcilerConfig{Interval: "30s", AutoRepair: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, r.Interval)
+	assert.True(t, r.AutoRepair)
+
+	_, err = NewReconciler(nil, &config.NetworkReconcilerConfig{Interval: "invalid"})
+	assert.Error(t, err)
+	_, err = NewReconciler(nil, &config.NetworkReconcilerConfig{Interval: "-1m"})
+	assert.Error(t, err)
+}
+
+func TestDiffNodeNetwork(t *testing.T) {
+	node := entity.Node{
+		Name: "node-a",
+		PhyInterfaces: []entity.PhyInterface{
+			{Name: "eth1"},
+			{Name: "eth2"},
+		},
+	}
+
+	testCases := []struct {
+		cases        string
+		bridgeExists bool
+		portNames    []string
+		missingPorts []string
+		drifted      bool
+	}{
+		{"synced", true, []string{"br0", "eth1", "eth2", "veth12345678"}, []string{}, false},
+		{"missingPort", true, []string{"br0", "eth1"}, []string{"eth2"}, true},
+		{"missingBridge", false, nil, []string{"eth1", "eth2"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			status := diffNodeNetwork(node, tc.bridgeExists, tc.portNames)
+			assert.Equal(t, "node-a", status.Name)
+			assert.Equal(t, tc.bridgeExists, status.BridgeExists)
+			assert.Equal(t, tc.missingPorts, status.MissingPorts)
+			assert.Equal(t, tc.drifted, status.Drifted)
+			assert.False(t, status.Repaired)
+		})
+	}
+}
+
+func TestCheckNetworkFail(t *testing.T) {
+	_, err := CheckNetwork(nil, entity.Network{Type: entity.FakeNetworkType})
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package networkprovider
+
+import (
+	"sync"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+)
+
+// the max number of the nodes which are called at the same time
+const maxNodeWorkers = 8
+
+// nodeTask is the operation of the network on a node
+type nodeTask func(sp *serviceprovider.Container, node entity.Node) error
+
+// applyNodes will apply the task to all nodes concurrently
+// When any node fails, the succeeded nodes are rolled back, so the network is changed on all nodes or none of them
+// The failed nodes are also rolled back to clean up the partial changes, but it's best-effort and its errors are ignored
+func applyNodes(sp *serviceprovider.Container, nodes []entity.Node, apply nodeTask, rollback nodeTask) error {
+	errs := runNodes(sp, nodes, maxNodeWorkers, apply)
+
+	failed := false
+	for _, err := range errs {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return nil
+	}
+
+	rollbackErrs := runNodes(sp, nodes, maxNodeWorkers, rollback)
+
+	statuses := make([]entity.NetworkNodeStatus, 0, len(nodes))
+	for i, node := range nodes {
+		if errs[i] != nil {
+			statuses = append(statuses, entity.NetworkNodeStatus{
+				Name:   node.Name,
+				Status: entity.NodeFailed,
+				Error:  errs[i].Error(),
+			})
+			continue
+		}
+		if rollbackErrs[i] != nil {
+			statuses = append(statuses, entity.NetworkNodeStatus{
+				Name:   node.Name,
+				Status: entity.NodeRollbackFailed,
+				Error:  rollbackErrs[i].Error(),
+			})
+			continue
+		}
+		statuses = append(statuses, entity.NetworkNodeStatus{
+			Name:   node.Name,
+			Status: entity.NodeRolledBack,
+		})
+	}
+	return &NodesError{Nodes: statuses}
+}
+
+// RunNodes will run the task on the nodes with at most maxNodeWorkers goroutines
+// The errors are in the same order as the nodes
+func RunNodes(sp *serviceprovider.Container, nodes []entity.Node, task func(sp *serviceprovider.Container, node entity.Node) error) []error {
+	return runNodes(sp, nodes, maxNodeWorkers, task)
+}
+
+// runNodes will run the task on the nodes with at most workers goroutines
+// The errors are in the same order as the nodes
+func runNodes(sp *serviceprovider.Container, nodes []entity.Node, workers int, task nodeTask) []error {
+	errs := make([]error, len(nodes))
+	if workers > len(nodes) {
+		workers = len(nodes)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				errs[index] = task(sp, nodes[index])
+			}
+		}()
+	}
+	for index := range nodes {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,145 @@
+package networkprovider
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+// nodeRecorder records the nodes which the tasks are applied to
+type nodeRecorder struct {
+	sync.Mutex
+	applied    map[string]bool
+	rolledBack map[string]bool
+}
+
+func newNodeRecorder() *nodeRecorder {
+	return &nodeRecorder{
+		applied:    map[string]bool{},
+		rolledBack: map[string]bool{},
+	}
+}
+
+func (r *nodeRecorder) apply(failed map[string]bool) nodeTask {
+	return func(sp *serviceprovider.Container, node entity.Node) error {
+		if failed[node.Name] {
+			return fmt.Errorf("fail to create on %s", node.Name)
+		}
+		r.Lock()
+		defer r.Unlock()
+		r.applied[node.Name] = true
+		return nil
+	}
+}
+
+func (r *nodeRecorder) rollback(failed map[string]bool) nodeTask {
+	return func(sp *serviceprovider.Container, node entity.Node) error {
+		if failed[node.Name] {
+			return fmt.Errorf("fail to delete on %s", node.Name)
+		}
+		r.Lock()
+		defer r.Unlock()
+		r.rolledBack[node.Name] = true
+		return nil
+	}
+}
+
+func testNodes(names ...string) []entity.Node {
+	nodes := []entity.Node{}
+	for _, name := range names {
+		nodes = append(nodes, entity.Node{Name: name})
+	}
+	return nodes
+}
+
+func TestApplyNodes(t *testing.T) {
+	recorder := newNodeRecorder()
+	err := applyNodes(nil, testNodes("node-a", "node-b", "node-c"), recorder.apply(nil), recorder.rollback(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"node-a": true, "node-b": true, "node-c": true}, recorder.applied)
+	assert.Empty(t, recorder.rolledBack)
+}
+
+func TestApplyNodesRollback(t *testing.T) {
+	recorder := newNodeRecorder()
+	err := applyNodes(
+		nil,
+		testNodes("node-a", "node-b", "node-c", "node-d"),
+		recorder.apply(map[string]bool{"node-b": true}),
+		recorder.rollback(map[string]bool{"node-d": true}),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, "failed on 1 nodes: node-b: fail to create on node-b", err.Error())
+
+	nodesErr, ok := err.(*NodesError)
+	assert.True(t, ok)
+	assert.False(t, nodesErr.Invalid)
+	assert.Equal(t, []entity.NetworkNodeStatus{
+		{Name: "node-a", Status: entity.NodeRolledBack},
+		{Name: "node-b", Status: entity.NodeFailed, Error: "fail to create on node-b"},
+		{Name: "node-c", Status: entity.NodeRolledBack},
+		{Name: "node-d", Status: entity.NodeRollbackFailed, Error: "fail to delete on node-d"},
+	}, nodesErr.Nodes)
+	// the failed node is also cleaned up
+	assert.Equal(t, map[string]bool{"node-a": true, "node-b": true, "node-c": true}, recorder.rolledBack)
+}
+
+func TestApplyNodesCleanupFailed(t *testing.T) {
+	recorder := newNodeRecorder()
+	err := applyNodes(
+		nil,
+		testNodes("node-a", "node-b"),
+		recorder.apply(map[string]bool{"node-b": true}),
+		recorder.rollback(map[string]bool{"node-b": true}),
+	)
+	assert.Error(t, err)
+
+	// the cleanup error of the failed node is ignored
+	nodesErr, ok := err.(*NodesError)
+	assert.True(t, ok)
+	assert.Equal(t, []entity.NetworkNodeStatus{
+		{Name: "node-a", Status: entity.NodeRolledBack},
+		{Name: "node-b", Status: entity.NodeFailed, Error: "fail to create on node-b"},
+	}, nodesErr.Nodes)
+}
+
+func TestRunNodes(t *testing.T) {
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	task := func(sp *serviceprovider.Container, node entity.Node) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+		if node.Name == "node-c" {
+			return fmt.Errorf("fail on %s", node.Name)
+		}
+		return nil
+	}
+
+	errs := runNodes(nil, testNodes("node-a", "node-b", "node-c", "node-d", "node-e"), 2, task)
+	assert.Len(t, errs, 5)
+	for i, err := range errs {
+		if i == 2 {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	assert.True(t, maxRunning <= 2)
+
+	assert.Empty(t, runNodes(nil, []entity.Node{}, 2, task))
+}
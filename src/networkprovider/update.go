@@ -0,0 +1,176 @@
+package networkprovider
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+)
+
+// updateOVSNetwork will change the OVS network on the changed nodes concurrently
+// The new nodes get the whole network, the removed nodes lose the bridge, and the other nodes only change the ports and the VLAN trunks
+// When any node fails, the updated nodes are changed back to the current network
+func updateOVSNetwork(sp *serviceprovider.Container, current entity.Network, updated entity.Network) error {
+	return applyNodes(
+		sp,
+		changedNodes(current, updated),
+		func(sp *serviceprovider.Container, node entity.Node) error {
+			return updateNodeNetwork(sp, current, updated, node.Name)
+		},
+		func(sp *serviceprovider.Container, node entity.Node) error {
+			return updateNodeNetwork(sp, updated, current, node.Name)
+		},
+	)
+}
+
+// updateNodeNetwork will change the network on the node from one network to the other
+// All ports are tried even if one of them fails, so rolling back a partly changed node restores the rest of it
+// It returns the first error
+func updateNodeNetwork(sp *serviceprovider.Container, from entity.Network, to entity.Network, nodeName string) error {
+	fromNode, inFrom := findNode(from.Nodes, nodeName)
+	toNode, inTo := findNode(to.Nodes, nodeName)
+
+	switch {
+	case !inFrom && inTo:
+		provider, err := getNodeNetworkProvider(to)
+		if err != nil {
+			return err
+		}
+		return provider.createNodeNetwork(sp, toNode)
+	case inFrom && !inTo:
+		provider, err := getNodeNetworkProvider(from)
+		if err != nil {
+			return err
+		}
+		return provider.deleteNodeNetwork(sp, fromNode)
+	case !inFrom && !inTo:
+		return nil
+	}
+
+	nc, err := newNodeController(sp, nodeName)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	var firstErr error
+	keep := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	added, removed := diffPhyInterfaces(fromNode.PhyInterfaces, toNode.PhyInterfaces)
+	for _, phyIface := range removed {
+		if err := nc.DeleteOVSPort(to.BridgeName, phyIface.Name); err != nil {
+			keep(err)
+		}
+	}
+	for _, phyIface := range added {
+		if err := nc.AddOVSPort(to.BridgeName, phyIface, to.IsDPDKPort); err != nil {
+			keep(err)
+			continue
+		}
+		if len(to.VlanTags) > 0 {
+			if err := nc.SetOVSPortTrunk(phyIface.Name, to.VlanTags); err != nil {
+				keep(err)
+			}
+		}
+	}
+
+	// the new ports already have the new VLAN trunk
+	if !sameVlanTags(from.VlanTags, to.VlanTags) {
+		for _, phyIface := range toNode.PhyInterfaces {
+			if err := nc.SetOVSPortTrunk(phyIface.Name, to.VlanTags); err != nil {
+				keep(err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// changedNodes will return the nodes which need to be changed, including the new and the removed nodes
+func changedNodes(current entity.Network, updated entity.Network) []entity.Node {
+	vlanChanged := !sameVlanTags(current.VlanTags, updated.VlanTags)
+	nodes := []entity.Node{}
+	for _, node := range updated.Nodes {
+		currentNode, ok := findNode(current.Nodes, node.Name)
+		if !ok || vlanChanged {
+			nodes = append(nodes, entity.Node{Name: node.Name})
+			continue
+		}
+		added, removed := diffPhyInterfaces(currentNode.PhyInterfaces, node.PhyInterfaces)
+		if len(added) > 0 || len(removed) > 0 {
+			nodes = append(nodes, entity.Node{Name: node.Name})
+		}
+	}
+	for _, node := range current.Nodes {
+		if _, ok := findNode(updated.Nodes, node.Name); !ok {
+			nodes = append(nodes, entity.Node{Name: node.Name})
+		}
+	}
+	return nodes
+}
+
+// diffPhyInterfaces will return the interfaces which are only in the new list and the interfaces which are only in the old list
+func diffPhyInterfaces(from []entity.PhyInterface, to []entity.PhyInterface) (added []entity.PhyInterface, removed []entity.PhyInterface) {
+	fromIfaces := map[entity.PhyInterface]bool{}
+	for _, phyIface := range from {
+		fromIfaces[phyIface] = true
+	}
+	toIfaces := map[entity.PhyInterface]bool{}
+	for _, phyIface := range to {
+		toIfaces[phyIface] = true
+	}
+
+	added = []entity.PhyInterface{}
+	for _, phyIface := range to {
+		if !fromIfaces[phyIface] {
+			added = append(added, phyIface)
+		}
+	}
+	removed = []entity.PhyInterface{}
+	for _, phyIface := range from {
+		if !toIfaces[phyIface] {
+			removed = append(removed, phyIface)
+		}
+	}
+	return added, removed
+}
+
+// sameVlanTags will check whether the VLAN tags are the same regardless of the order
+func sameVlanTags(a []int32, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]int32{}, a...)
+	sortedB := append([]int32{}, b...)
+	sort.Slice(sortedA, func(i, j int) bool { return sortedA[i] < sortedA[j] })
+	sort.Slice(sortedB, func(i, j int) bool { return sortedB[i] < sortedB[j] })
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func findNode(nodes []entity.Node, name string) (entity.Node, bool) {
+	for _, node := range nodes {
+		if node.Name == name {
+			return node, true
+		}
+	}
+	return entity.Node{}, false
+}
+
+func getNodeNetworkProvider(network entity.Network) (nodeNetworkProvider, error) {
+	provider, err := GetNetworkProvider(&network)
+	if err != nil {
+		return nil, err
+	}
+	nodeProvider, ok := provider.(nodeNetworkProvider)
+	if !ok {
+		return nil, fmt.Errorf("the network type %s can not be changed on a single node", network.Type)
+	}
+	return nodeProvider, nil
+}
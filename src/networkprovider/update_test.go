@@ -0,0 +1,81 @@
+package networkprovider
+
+import (
+	"testing"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPhyInterfaces(t *testing.T) {
+	from := []entity.PhyInterface{
+		{Name: "eth1"},
+		{Name: "eth2", PCIID: "0000:00:08.0"},
+		{Name: "eth3"},
+	}
+	to := []entity.PhyInterface{
+		{Name: "eth1"},
+		{Name: "eth2", PCIID: "0000:00:09.0"},
+		{Name: "eth4"},
+	}
+
+	added, removed := diffPhyInterfaces(from, to)
+	assert.Equal(t, []entity.PhyInterface{{Name: "eth2", PCIID: "0000:00:09.0"}, {Name: "eth4"}}, added)
+	assert.Equal(t, []entity.PhyInterface{{Name: "eth2", PCIID: "0000:00:08.0"}, {Name: "eth3"}}, removed)
+
+	added, removed = diffPhyInterfaces(from, from)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+}
+
+func TestSameVlanTags(t *testing.T) {
+	testCases := []struct {
+		cases  string
+		a      []int32
+		b      []int32
+		expect bool
+	}{
+		{"empty", []int32{}, nil, true},
+		{"same", []int32{100, 200}, []int32{100, 200}, true},
+		{"order", []int32{200, 100}, []int32{100, 200}, true},
+		{"added", []int32{100}, []int32{100, 200}, false},
+		{"changed", []int32{100, 300}, []int32{100, 200}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			assert.Equal(t, tc.expect, sameVlanTags(tc.a, tc.b))
+		})
+	}
+
+	// the VLAN tags are not sorted in place
+	tags := []int32{200, 100}
+	sameVlanTags(tags, []int32{100, 200})
+	assert.Equal(t, []int32{200, 100}, tags)
+}
+
+func TestChangedNodes(t *testing.T) {
+	current := entity.Network{
+		VlanTags: []int32{100},
+		Nodes: []entity.Node{
+			{Name: "node-a", PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}}},
+			{Name: "node-b", PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}}},
+			{Name: "node-c", PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}}},
+		},
+	}
+
+	updated := current
+	updated.Nodes = []entity.Node{
+		{Name: "node-a", PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}}},
+		{Name: "node-b", PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}, {Name: "eth2"}}},
+		{Name: "node-d", PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}}},
+	}
+	assert.Equal(t, []entity.Node{{Name: "node-b"}, {Name: "node-d"}, {Name: "node-c"}}, changedNodes(current, updated))
+
+	// all nodes change the VLAN trunk
+	updated = current
+	updated.VlanTags = []int32{100, 200}
+	assert.Equal(t, []entity.Node{{Name: "node-a"}, {Name: "node-b"}, {Name: "node-c"}}, changedNodes(current, updated))
+
+	assert.Empty(t, changedNodes(current, current))
+}
@@ -2,89 +2,161 @@ package ovscontroller
 
 import (
 	"net"
+	"sync"
 
 	"github.com/linkernetworks/network-controller/utils"
 	"github.com/hwchiu/vortex/src/entity"
 	"github.com/hwchiu/vortex/src/networkcontroller"
+	np "github.com/hwchiu/vortex/src/networkprovider"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"gopkg.in/mgo.v2/bson"
 )
 
 func DumpPorts(sp *serviceprovider.Container, nodeName string, bridgeName string) ([]entity.OVSPortInfo, error) {
-	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(nodeName)
+	owners, err := portOwners(sp, bridgeName)
 	if err != nil {
 		return nil, err
 	}
+	return dumpNodePorts(sp, nodeName, bridgeName, owners)
+}
 
-	nodeAddr := net.JoinHostPort(nodeIP, networkcontroller.DEFAULT_CONTROLLER_PORT)
-	nc, err := networkcontroller.New(nodeAddr)
+// DumpNetworkPorts will dump the ports of the bridge of the network on all nodes concurrently
+// The nodes are called by the bounded workers of the network provider, so a large network does not open a connection to every node at once
+// The ports of the reachable nodes are returned even if some nodes fail, and the status of each node is reported
+func DumpNetworkPorts(sp *serviceprovider.Container, network entity.Network) (entity.NetworkPortInfos, error) {
+	owners, err := portOwners(sp, network.BridgeName)
 	if err != nil {
-		return nil, err
+		return entity.NetworkPortInfos{}, err
 	}
 
-	//Get the information of OVS ports
-	retPorts, err := nc.DumpOVSPorts(bridgeName)
-	if err != nil {
-		return nil, err
+	var lock sync.Mutex
+	nodePorts := map[string][]entity.OVSPortInfo{}
+	errs := np.RunNodes(sp, network.Nodes, func(sp *serviceprovider.Container, node entity.Node) error {
+		ports, err := dumpNodePorts(sp, node.Name, network.BridgeName, owners)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		nodePorts[node.Name] = ports
+		return nil
+	})
+
+	infos := entity.NetworkPortInfos{
+		Ports: []entity.OVSPortInfo{},
+		Nodes: []entity.NetworkNodeStatus{},
 	}
+	for i, node := range network.Nodes {
+		status := entity.NetworkNodeStatus{
+			Name:   node.Name,
+			Status: entity.NodeSucceeded,
+		}
+		if errs[i] != nil {
+			status.Status = entity.NodeFailed
+			status.Error = errs[i].Error()
+		}
+		infos.Nodes = append(infos.Nodes, status)
+		infos.Ports = append(infos.Ports, nodePorts[node.Name]...)
+	}
+	return infos, nil
+}
+
+// portOwner is the pod interface which the veth belongs to
+type portOwner struct {
+	podName        string
+	namespace      string
+	deploymentName string
+	ifName         string
+}
 
+// portOwners will find the pods and the deployments on the bridge, the key of the map is the veth name
+func portOwners(sp *serviceprovider.Container, bridgeName string) (map[string]portOwner, error) {
 	//We need to find a mapping for veth to podName
-	//1. lookup the mongodb to find all deployments which bridge name is equal to bridgeName
-	//2. lookup all current pods which is belogs to above deployments
-	//3. use the pod's UID and the interfae of each entity.DeploymentNetowrk to geneate the vetxXXXXXX
-	//4. use the vtxXXXXXXXXX as the key to combine the podName/interfaace and the OVSPorts
+	//1. lookup the mongodb to find all pods and deployments which bridge name is equal to bridgeName
+	//2. lookup all current pods which is belogs to above pods and deployments
+	//3. use the pod's UID and the interfae of each network to geneate the vetxXXXXXX
 	session := sp.Mongo.NewSession()
 	defer session.Close()
 
-	//1. lookup the mongodb to find all deployments which bridge name is equal to bridgeName
-	//In order to the following use, use the map here and the key is the deploynent name and the value is the deployment object.
+	//1. lookup the mongodb to find all pods and deployments which bridge name is equal to bridgeName
+	//In order to the following use, use the map here and the key is the namespace and the name and the value is the interface names.
 	//We need to mapping the deployment.Networks with Pod's UID and the connection is the label of the Pod is vortex=deployment.name.
 	deployments := []entity.Deployment{}
-	session.FindAll(entity.DeploymentCollectionName, bson.M{"networks.bridgeName": bridgeName}, &deployments)
-	deployMap := map[string]*entity.Deployment{}
+	if err := session.FindAll(entity.DeploymentCollectionName, bson.M{"networks.bridgeName": bridgeName}, &deployments); err != nil {
+		return nil, err
+	}
+	deployMap := map[string][]string{}
+	for _, deploy := range deployments {
+		for _, network := range deploy.Networks {
+			deployMap[deploy.Namespace+"/"+deploy.Name] = append(deployMap[deploy.Namespace+"/"+deploy.Name], network.IfName)
+		}
+	}
 
-	for _, v := range deployments {
-		deployMap[v.Name] = &v
+	pods := []entity.Pod{}
+	if err := session.FindAll(entity.PodCollectionName, bson.M{"networks.bridgeName": bridgeName}, &pods); err != nil {
+		return nil, err
+	}
+	podMap := map[string][]string{}
+	for _, pod := range pods {
+		for _, network := range pod.Networks {
+			podMap[pod.Namespace+"/"+pod.Name] = append(podMap[pod.Namespace+"/"+pod.Name], network.IfName)
+		}
 	}
 
-	//2. lookup all current pods which is belogs to above deployments
-	pods, err := sp.KubeCtl.GetPods("")
+	//2. lookup all current pods which is belogs to above pods and deployments
+	kubePods, err := sp.KubeCtl.GetPods("")
 	if err != nil {
 		return nil, err
 	}
 
-	//Create a local structure to combine the deploymentNetwork and PodName.
-	//We want to know the interface name in that Pod, so we need to keep the deploymentNetwork object.
-	type portData struct {
-		podName string
-		entity.DeploymentNetwork
-	}
-	interfaces := map[string]*portData{}
-	for _, v := range pods {
-		name, ok := v.Labels["vortex"]
+	owners := map[string]portOwner{}
+	for _, v := range kubePods {
+		deploymentName := ""
+		ifNames, ok := podMap[v.Namespace+"/"+v.Name]
 		if !ok {
-			continue
+			deploymentName = v.Labels["vortex"]
+			ifNames, ok = deployMap[v.Namespace+"/"+deploymentName]
 		}
-		deploy, ok := deployMap[name]
 		if !ok {
 			continue
 		}
 
-		//3. use the pod's UID and the interfae of each entity.DeploymentNetowrk to geneate the vtxXXXXXXXXX
-		//for each deploymentNetwork, we get the vethname via veth+sha256(podUID + interfaceName in container)[0:8]
-		uid := v.ObjectMeta.UID
-		for _, k := range deploy.Networks {
-			vethName := utils.GenerateVethName(string(uid), k.IfName)
-			//Use the veth name as the key and the PodName/InterfaceName in the value, we will add those inforamtion
-			//to OVSPortInfo later.
-			interfaces[vethName] = &portData{
-				v.Name,
-				k,
+		//3. use the pod's UID and the interfae of each network to geneate the vtxXXXXXXXXX
+		//for each network, we get the vethname via veth+sha256(podUID + interfaceName in container)[0:8]
+		for _, ifName := range ifNames {
+			vethName := utils.GenerateVethName(string(v.UID), ifName)
+			owners[vethName] = portOwner{
+				podName:        v.Name,
+				namespace:      v.Namespace,
+				deploymentName: deploymentName,
+				ifName:         ifName,
 			}
 		}
 	}
+	return owners, nil
+}
+
+// dumpNodePorts will dump the ports of the bridge on the node and fill the pods of the veths
+func dumpNodePorts(sp *serviceprovider.Container, nodeName string, bridgeName string, owners map[string]portOwner) ([]entity.OVSPortInfo, error) {
+	nodeIP, err := sp.KubeCtl.GetNodeInternalIP(nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeAddr := net.JoinHostPort(nodeIP, networkcontroller.DEFAULT_CONTROLLER_PORT)
+	nc, err := networkcontroller.New(nodeAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+
+	//Get the information of OVS ports
+	retPorts, err := nc.DumpOVSPorts(bridgeName)
+	if err != nil {
+		return nil, err
+	}
 
-	//4. use the vethxxx as the key to combine the podName/interfaace and the OVSPorts
+	//use the vethxxx as the key to combine the podName/interfaace and the OVSPorts
 	ports := []entity.OVSPortInfo{}
 	for _, v := range retPorts {
 		port := entity.OVSPortInfo{
@@ -103,11 +175,14 @@ func DumpPorts(sp *serviceprovider.Container, nodeName string, bridgeName string
 				Dropped: v.Transmitted.Dropped,
 				Errors:  v.Transmitted.Errors,
 			},
+			NodeName: nodeName,
 		}
 
-		if i, ok := interfaces[port.Name]; ok {
-			port.InterfaceName = i.IfName
-			port.PodName = i.podName
+		if owner, ok := owners[port.Name]; ok {
+			port.InterfaceName = owner.ifName
+			port.PodName = owner.podName
+			port.Namespace = owner.namespace
+			port.DeploymentName = owner.deploymentName
 		}
 		ports = append(ports, port)
 
@@ -11,12 +11,15 @@ import (
 	"time"
 
 	"github.com/hwchiu/vortex/src/config"
-	_ "github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/entity"
 	kc "github.com/hwchiu/vortex/src/kubernetes"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 
+	"github.com/linkernetworks/network-controller/utils"
 	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -98,3 +101,65 @@ func (suite *OVSControllerTestSuite) TestDumpOVSPorts() {
 	suite.NoError(err)
 	suite.Equal(1, len(portStats))
 }
+
+func (suite *OVSControllerTestSuite) TestDumpNetworkPorts() {
+	network := entity.Network{
+		BridgeName: suite.bridgeName,
+		Nodes: []entity.Node{
+			{Name: suite.nodeName},
+			{Name: namesgenerator.GetRandomName(0)},
+		},
+	}
+	infos, err := DumpNetworkPorts(suite.sp, network)
+	suite.NoError(err)
+	suite.Equal(1, len(infos.Ports))
+	suite.Equal(suite.nodeName, infos.Ports[0].NodeName)
+
+	// the unknown node is reported instead of failing the whole dump
+	suite.Equal(2, len(infos.Nodes))
+	suite.Equal(entity.NodeSucceeded, infos.Nodes[0].Status)
+	suite.Equal(entity.NodeFailed, infos.Nodes[1].Status)
+	suite.NotEmpty(infos.Nodes[1].Error)
+}
+
+func TestPortOwners(t *testing.T) {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	bridgeName := namesgenerator.GetRandomName(0)
+	deploy := entity.Deployment{
+		ID:        bson.NewObjectId(),
+		Name:      namesgenerator.GetRandomName(0),
+		Namespace: "default",
+		Networks:  []entity.DeploymentNetwork{{Name: "network", IfName: "eth1", BridgeName: bridgeName}},
+	}
+	assert.NoError(t, session.Insert(entity.DeploymentCollectionName, &deploy))
+	defer session.Remove(entity.DeploymentCollectionName, "_id", deploy.ID)
+	pod := entity.Pod{
+		ID:        bson.NewObjectId(),
+		Name:      namesgenerator.GetRandomName(0),
+		Namespace: "default",
+		Networks:  []entity.PodNetwork{{Name: "network", IfName: "eth2", BridgeName: bridgeName}},
+	}
+	assert.NoError(t, session.Insert(entity.PodCollectionName, &pod))
+	defer session.Remove(entity.PodCollectionName, "_id", pod.ID)
+
+	kubePods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: deploy.Name + "-abcde", Namespace: "default", UID: "uid-a", Labels: map[string]string{"vortex": deploy.Name}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: "default", UID: "uid-b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: "uid-c"}},
+	}
+	for i := range kubePods {
+		_, err := sp.KubeCtl.Clientset.CoreV1().Pods("default").Create(&kubePods[i])
+		assert.NoError(t, err)
+	}
+
+	owners, err := portOwners(sp, bridgeName)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]portOwner{
+		utils.GenerateVethName("uid-a", "eth1"): {podName: deploy.Name + "-abcde", namespace: "default", deploymentName: deploy.Name, ifName: "eth1"},
+		utils.GenerateVethName("uid-b", "eth2"): {podName: pod.Name, namespace: "default", ifName: "eth2"},
+	}, owners)
+}
@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/linkernetworks/logger"
 	"github.com/linkernetworks/mongo"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/hwchiu/vortex/src/utils"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -35,13 +39,21 @@ func CheckPodParameter(sp *serviceprovider.Container, pod *entity.Pod) error {
 		}
 	}
 
-	//Check the network
+	//Check the network, the pending networks are not created on the nodes yet
 	for _, v := range pod.Networks {
-		count, err := session.Count(entity.NetworkCollectionName, bson.M{"name": v.Name})
-		if err != nil {
+		network := entity.Network{}
+		if err := session.FindOne(entity.NetworkCollectionName, bson.M{"name": v.Name, "pending": bson.M{"$ne": true}}, &network); err != nil {
+			if err == mgo.ErrNotFound {
+				return fmt.Errorf("the network named %s doesn't exist", v.Name)
+			}
 			return fmt.Errorf("check the network name error:%v", err)
-		} else if count == 0 {
-			return fmt.Errorf("the network named %s doesn't exist", v.Name)
+		}
+		// the IP address is allocated from the subnets of the network when it's empty
+		if v.IPAddress == "" && len(network.Subnets) == 0 {
+			return fmt.Errorf("the IP address of the network %s is required since the network has no subnets", v.Name)
+		}
+		if v.IPAddress != "" && len(network.Subnets) == 0 && v.Netmask == "" {
+			return fmt.Errorf("the netmask of the network %s is required", v.Name)
 		}
 	}
 
@@ -173,11 +185,14 @@ func generateNetwork(session *mongo.Session, pod *entity.Pod) ([]string, []corev
 	networks := []entity.Network{}
 	for i, v := range pod.Networks {
 		network := entity.Network{}
-		if err := session.FindOne(entity.NetworkCollectionName, bson.M{"name": v.Name}, &network); err != nil {
+		if err := session.FindOne(entity.NetworkCollectionName, bson.M{"name": v.Name, "pending": bson.M{"$ne": true}}, &network); err != nil {
 			return nil, nil, err
 		}
 		networks = append(networks, network)
 		pod.Networks[i].BridgeName = network.BridgeName
+		if err := allocateAddress(session, network, pod, i); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	nodes := generateNodeLabels(networks)
@@ -185,6 +200,33 @@ func generateNetwork(session *mongo.Session, pod *entity.Pod) ([]string, []corev
 	return nodes, containers, err
 }
 
+// allocateAddress will allocate the IP address of the pod network from the subnets of the network
+// The static IP address is reserved, so it can not be used by the other pods
+func allocateAddress(session *mongo.Session, network entity.Network, pod *entity.Pod, i int) error {
+	// the static IP address is required without the subnets, it's reserved so the other pods can not use it
+	if len(network.Subnets) == 0 {
+		if pod.Networks[i].IPAddress == "" {
+			return nil
+		}
+		_, err := ipam.ReserveStatic(session, network, pod.Networks[i].IPAddress, pod.Networks[i].Netmask, pod.ID, 0)
+		return err
+	}
+
+	var allocation entity.IPAllocation
+	var err error
+	if pod.Networks[i].IPAddress == "" {
+		allocation, err = ipam.Allocate(session, network, pod.ID, 0)
+	} else {
+		allocation, err = ipam.Reserve(session, network, pod.Networks[i].IPAddress, pod.ID, 0)
+	}
+	if err != nil {
+		return err
+	}
+	pod.Networks[i].IPAddress = allocation.Address
+	pod.Networks[i].Netmask = allocation.Netmask
+	return nil
+}
+
 func generateContainerSecurity(pod *entity.Pod) *corev1.SecurityContext {
 	if !pod.Capability {
 		return &corev1.SecurityContext{}
@@ -263,6 +305,9 @@ func CreatePod(sp *serviceprovider.Container, pod *entity.Pod) error {
 	}
 
 	if err != nil {
+		if releaseErr := ipam.Release(session, pod.ID); releaseErr != nil {
+			logger.Warnf("fail to release the IP addresses of the pod %s: %v", pod.Name, releaseErr)
+		}
 		return err
 	}
 
@@ -310,11 +355,28 @@ func CreatePod(sp *serviceprovider.Container, pod *entity.Pod) error {
 	if pod.Namespace == "" {
 		pod.Namespace = "default"
 	}
-	_, err = sp.KubeCtl.CreatePod(&p, pod.Namespace)
-	return err
+	if _, err := sp.KubeCtl.CreatePod(&p, pod.Namespace); err != nil {
+		if releaseErr := ipam.Release(session, pod.ID); releaseErr != nil {
+			logger.Warnf("fail to release the IP addresses of the pod %s: %v", pod.Name, releaseErr)
+		}
+		return err
+	}
+	return nil
 }
 
-// DeletePod will delete pod
+// DeletePod will delete pod and release its IP addresses
+// The pod which is already gone is deleted, and its IP addresses are always released
 func DeletePod(sp *serviceprovider.Container, pod *entity.Pod) error {
-	return sp.KubeCtl.DeletePod(pod.Name, pod.Namespace)
+	deleteErr := sp.KubeCtl.DeletePod(pod.Name, pod.Namespace)
+	if errors.IsNotFound(deleteErr) {
+		deleteErr = nil
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+	releaseErr := ipam.Release(session, pod.ID)
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return releaseErr
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/stretchr/testify/suite"
@@ -311,6 +312,7 @@ func (suite *PodTestSuite) TestGenerateNetwork() {
 
 	session.Insert(entity.NetworkCollectionName, network)
 	defer session.Remove(entity.NetworkCollectionName, "name", network.Name)
+	defer ipam.ReleaseNetwork(session, network.ID)
 
 	podName := namesgenerator.GetRandomName(0)
 	ifName := namesgenerator.GetRandomName(0)
@@ -331,6 +333,15 @@ func (suite *PodTestSuite) TestGenerateNetwork() {
 	suite.NoError(err)
 	suite.Equal(1, len(containers))
 	suite.Equal(0, len(nodes))
+
+	// the static IP address is reserved in the network without subnets
+	other := &entity.Pod{
+		ID:       bson.NewObjectId(),
+		Name:     namesgenerator.GetRandomName(0),
+		Networks: []entity.PodNetwork{pod.Networks[0]},
+	}
+	_, _, err = generateNetwork(session, other)
+	suite.True(ipam.IsConflict(err))
 }
 
 func (suite *PodTestSuite) TestGenerateNetworkFail() {
@@ -469,3 +480,119 @@ func (suite *PodTestSuite) TestCreatePodWithNetworkTypes() {
 		})
 	}
 }
+
+func (suite *PodTestSuite) TestCheckPodParameterWithoutIPAddress() {
+	network := entity.Network{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+	}
+	session := suite.sp.Mongo.NewSession()
+	defer session.Close()
+
+	session.Insert(entity.NetworkCollectionName, network)
+	defer session.Remove(entity.NetworkCollectionName, "name", network.Name)
+
+	pod := &entity.Pod{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Networks: []entity.PodNetwork{
+			{Name: network.Name},
+		},
+	}
+
+	// the network has no subnets to allocate the IP address
+	err := CheckPodParameter(suite.sp, pod)
+	suite.Error(err)
+
+	session.C(entity.NetworkCollectionName).UpdateId(network.ID, bson.M{
+		"$set": bson.M{"subnets": []entity.Subnet{{CIDR: "10.0.0.0/24"}}},
+	})
+	err = CheckPodParameter(suite.sp, pod)
+	suite.NoError(err)
+}
+
+func (suite *PodTestSuite) TestGenerateNetworkWithSubnets() {
+	network := entity.Network{
+		ID:         bson.NewObjectId(),
+		Name:       namesgenerator.GetRandomName(0),
+		BridgeName: namesgenerator.GetRandomName(0),
+		Subnets: []entity.Subnet{
+			{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1"},
+		},
+	}
+	session := suite.sp.Mongo.NewSession()
+	defer session.Close()
+
+	session.Insert(entity.NetworkCollectionName, network)
+	defer session.Remove(entity.NetworkCollectionName, "name", network.Name)
+	defer ipam.ReleaseNetwork(session, network.ID)
+
+	pods := []*entity.Pod{}
+	for i := 0; i < 2; i++ {
+		pod := &entity.Pod{
+			ID:   bson.NewObjectId(),
+			Name: namesgenerator.GetRandomName(0),
+			Networks: []entity.PodNetwork{
+				{
+					Name:   network.Name,
+					IfName: "eth1",
+				},
+			},
+		}
+		_, containers, err := generateNetwork(session, pod)
+		suite.NoError(err)
+		suite.Equal(1, len(containers))
+		pods = append(pods, pod)
+	}
+	suite.Equal("10.0.0.2", pods[0].Networks[0].IPAddress)
+	suite.Equal("10.0.0.3", pods[1].Networks[0].IPAddress)
+	suite.Equal("255.255.255.0", pods[1].Networks[0].Netmask)
+
+	// the static IP address is used by the other pod
+	pod := &entity.Pod{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Networks: []entity.PodNetwork{
+			{
+				Name:      network.Name,
+				IfName:    "eth1",
+				IPAddress: "10.0.0.3",
+			},
+		},
+	}
+	_, _, err := generateNetwork(session, pod)
+	suite.True(ipam.IsConflict(err))
+
+	// the released IP address can be used again
+	err = ipam.Release(session, pods[1].ID)
+	suite.NoError(err)
+	_, _, err = generateNetwork(session, pod)
+	suite.NoError(err)
+	suite.Equal("10.0.0.3", pod.Networks[0].IPAddress)
+	suite.Equal("255.255.255.0", pod.Networks[0].Netmask)
+}
+
+func (suite *PodTestSuite) TestDeletePodNotFound() {
+	network := entity.Network{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+	}
+	session := suite.sp.Mongo.NewSession()
+	defer session.Close()
+	defer ipam.ReleaseNetwork(session, network.ID)
+
+	pod := &entity.Pod{
+		ID:        bson.NewObjectId(),
+		Name:      namesgenerator.GetRandomName(0),
+		Namespace: "default",
+	}
+	_, err := ipam.ReserveStatic(session, network, "1.2.3.4", "255.255.255.0", pod.ID, 0)
+	suite.NoError(err)
+
+	// the pod is not in kubernetes, but its IP addresses are still released
+	err = DeletePod(suite.sp, pod)
+	suite.NoError(err)
+	count, err := session.Count(entity.IPAllocationCollectionName, bson.M{"resourceID": pod.ID})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/linkernetworks/logger"
 	"github.com/hwchiu/vortex/src/config"
+	np "github.com/hwchiu/vortex/src/networkprovider"
+	"github.com/hwchiu/vortex/src/server/backend"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 )
 
@@ -40,5 +42,39 @@ func (a *App) Start(host, port string) error {
 func (a *App) InitilizeService() {
 	logger.Setup(a.Config.Logger)
 
+	if err := backend.SetupKeySet(a.Config.JWT); err != nil {
+		log.Fatalf("Load the JWT keys fail: %v", err)
+	}
+
+	if err := backend.SetupAuthenticator(a.Config); err != nil {
+		log.Fatalf("Setup the authenticators fail: %v", err)
+	}
+
+	if err := backend.SetupOIDCProvider(a.Config.OIDC); err != nil {
+		log.Fatalf("Setup the OIDC provider fail: %v", err)
+	}
+
+	if err := backend.SetupLockout(a.Config.Lockout); err != nil {
+		log.Fatalf("Load the lockout config fail: %v", err)
+	}
+
+	if err := backend.SetupSignup(a.Config.Signup); err != nil {
+		log.Fatalf("Load the signup config fail: %v", err)
+	}
+
+	backend.SetupSharedNamespaces(a.Config.Team)
+
 	a.ServiceProvider = serviceprovider.New(a.Config)
+
+	if err := backend.SetupServiceAccountAuthenticator(a.ServiceProvider.KubeCtl, a.Config.ServiceAccount); err != nil {
+		log.Fatalf("Load the ServiceAccount config fail: %v", err)
+	}
+
+	if a.Config.NetworkReconciler != nil {
+		reconciler, err := np.NewReconciler(a.ServiceProvider, a.Config.NetworkReconciler)
+		if err != nil {
+			log.Fatalf("Load the network reconciler config fail: %v", err)
+		}
+		reconciler.Start()
+	}
 }
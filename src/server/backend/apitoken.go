@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// APITokenPrefix is the prefix of the personal API tokens to tell them from the JWT
+const APITokenPrefix = "vtx_"
+
+// the byte length of the secret part of an API token
+const apiTokenSecretLength = 32
+
+// the last used time of an API token is updated at most once in this interval, so the requests don't write the token every time
+const apiTokenLastUsedInterval = 5 * time.Minute
+
+var roleLevels = map[string]int{
+	entity.GuestRole: 1,
+	entity.UserRole:  2,
+	entity.RootRole:  3,
+}
+
+// IsRoleWithin will check whether the scope is a valid role and not higher than the role
+func IsRoleWithin(scope, role string) bool {
+	scopeLevel, ok := roleLevels[scope]
+	if !ok {
+		return false
+	}
+	return scopeLevel <= roleLevels[role]
+}
+
+// LowerRole will return the lower one of the two roles
+func LowerRole(a, b string) string {
+	if roleLevels[a] <= roleLevels[b] {
+		return a
+	}
+	return b
+}
+
+// IsAPIToken will check whether the bearer token is a personal API token
+func IsAPIToken(tokenString string) bool {
+	return strings.HasPrefix(tokenString, APITokenPrefix)
+}
+
+// CreateAPIToken will store the hashed token and return the plain token
+// The plain token is "vtx_<token ID>_<secret>" so it can be found without the hash
+// The secret is random enough, so the SHA256 hash is used instead of the slow password hash which runs on every request
+func CreateAPIToken(session *mongo.Session, token entity.APIToken) (entity.APIToken, string, error) {
+	secret, err := utils.RandomToken(apiTokenSecretLength)
+	if err != nil {
+		return entity.APIToken{}, "", err
+	}
+
+	// the name of a token is unique for the user
+	session.C(entity.APITokenCollectionName).EnsureIndex(mgo.Index{
+		Key:    []string{"userID", "name"},
+		Unique: true,
+	})
+
+	token.ID = bson.NewObjectId()
+	token.HashedToken = utils.SHA256String(secret)
+	token.CreatedAt = timeutils.Now()
+	if err := session.Insert(entity.APITokenCollectionName, &token); err != nil {
+		return entity.APIToken{}, "", err
+	}
+	return token, APITokenPrefix + token.ID.Hex() + "_" + secret, nil
+}
+
+// AuthenticateAPIToken will find the unexpired API token and its user by the plain token
+// It returns mgo.ErrNotFound when the token is malformed, unknown, expired or mismatched
+func AuthenticateAPIToken(session *mongo.Session, tokenString string) (entity.APIToken, entity.User, error) {
+	parts := strings.SplitN(strings.TrimPrefix(tokenString, APITokenPrefix), "_", 2)
+	if !IsAPIToken(tokenString) || len(parts) != 2 || !bson.IsObjectIdHex(parts[0]) {
+		return entity.APIToken{}, entity.User{}, mgo.ErrNotFound
+	}
+
+	token := entity.APIToken{}
+	if err := session.FindOne(
+		entity.APITokenCollectionName,
+		bson.M{"_id": bson.ObjectIdHex(parts[0])},
+		&token,
+	); err != nil {
+		return entity.APIToken{}, entity.User{}, err
+	}
+
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return entity.APIToken{}, entity.User{}, mgo.ErrNotFound
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.SHA256String(parts[1])), []byte(token.HashedToken)) != 1 {
+		return entity.APIToken{}, entity.User{}, mgo.ErrNotFound
+	}
+
+	user, err := FindUserByID(session, token.UserID)
+	if err != nil {
+		return entity.APIToken{}, entity.User{}, err
+	}
+
+	if isLastUsedStale(token.LastUsedAt, time.Now()) {
+		if err := session.C(entity.APITokenCollectionName).UpdateId(token.ID, bson.M{
+			"$set": bson.M{"lastUsedAt": timeutils.Now()},
+		}); err != nil {
+			return entity.APIToken{}, entity.User{}, err
+		}
+	}
+	return token, user, nil
+}
+
+// isLastUsedStale will check whether the last used time of the API token should be updated
+func isLastUsedStale(lastUsedAt *time.Time, now time.Time) bool {
+	return lastUsedAt == nil || now.Sub(*lastUsedAt) >= apiTokenLastUsedInterval
+}
+
+// RemoveUserAPITokens will remove all API tokens of the user
+func RemoveUserAPITokens(session *mongo.Session, userID bson.ObjectId) error {
+	_, err := session.C(entity.APITokenCollectionName).RemoveAll(bson.M{"userID": userID})
+	return err
+}
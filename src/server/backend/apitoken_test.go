@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/stretchr/testify/assert"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestIsRoleWithin(t *testing.T) {
+	testCases := []struct {
+		scope  string
+		role   string
+		expect bool
+	}{
+		{entity.GuestRole, entity.GuestRole, true},
+		{entity.GuestRole, entity.RootRole, true},
+		{entity.UserRole, entity.RootRole, true},
+		{entity.UserRole, entity.GuestRole, false},
+		{entity.RootRole, entity.UserRole, false},
+		{"admin", entity.RootRole, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.scope+"-"+tc.role, func(t *testing.T) {
+			assert.Equal(t, tc.expect, IsRoleWithin(tc.scope, tc.role))
+		})
+	}
+}
+
+func TestLowerRole(t *testing.T) {
+	assert.Equal(t, entity.GuestRole, LowerRole(entity.GuestRole, entity.RootRole))
+	assert.Equal(t, entity.UserRole, LowerRole(entity.RootRole, entity.UserRole))
+	assert.Equal(t, entity.RootRole, LowerRole(entity.RootRole, entity.RootRole))
+}
+
+func TestAuthenticateMalformedAPIToken(t *testing.T) {
+	testCases := []string{
+		"",
+		"vtx_",
+		"vtx_invalid_secret",
+		bson.NewObjectId().Hex(),
+		"vtx_" + bson.NewObjectId().Hex(),
+	}
+
+	for _, tc := range testCases {
+		_, _, err := AuthenticateAPIToken(nil, tc)
+		assert.Equal(t, mgo.ErrNotFound, err)
+	}
+}
+
+func TestIsLastUsedStale(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	old := now.Add(-apiTokenLastUsedInterval)
+
+	assert.True(t, isLastUsedStale(nil, now))
+	assert.False(t, isLastUsedStale(&recent, now))
+	assert.True(t, isLastUsedStale(&old, now))
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"strings"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/linkernetworks/mongo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// AuditAction will return the action of the audit log for the HTTP method, it's empty for the read-only methods
+func AuditAction(method string) string {
+	switch method {
+	case "POST":
+		return entity.CreateAction
+	case "PUT", "PATCH":
+		return entity.UpdateAction
+	case "DELETE":
+		return entity.DeleteAction
+	}
+	return ""
+}
+
+// AuditResource will return the resource type and ID of the API path
+// e.g. /v1/networks/5b5b418c760aab15e771bde2 is the networks 5b5b418c760aab15e771bde2
+// The ID is empty when the path does not contain an object ID, e.g. /v1/users/signin
+func AuditResource(path string) (string, string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 {
+		return "", ""
+	}
+	// skip the version prefix
+	segments = segments[1:]
+
+	for _, segment := range segments[1:] {
+		if bson.IsObjectIdHex(segment) {
+			return segments[0], segment
+		}
+	}
+	return segments[0], ""
+}
+
+// CreateAuditLog will insert the audit log
+func CreateAuditLog(session *mongo.Session, auditLog entity.AuditLog) error {
+	session.C(entity.AuditCollectionName).EnsureIndex(mgo.Index{
+		Key: []string{"userID", "-createdAt"},
+	})
+	session.C(entity.AuditCollectionName).EnsureIndex(mgo.Index{
+		Key: []string{"resourceType", "resourceID", "-createdAt"},
+	})
+
+	if auditLog.ID == "" {
+		auditLog.ID = bson.NewObjectId()
+	}
+	return session.Insert(entity.AuditCollectionName, &auditLog)
+}
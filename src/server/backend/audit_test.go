@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditAction(t *testing.T) {
+	testCases := []struct {
+		method string
+		action string
+	}{
+		{"POST", entity.CreateAction},
+		{"PUT", entity.UpdateAction},
+		{"PATCH", entity.UpdateAction},
+		{"DELETE", entity.DeleteAction},
+		{"GET", ""},
+		{"OPTIONS", ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.action, AuditAction(tc.method), "method: %s", tc.method)
+	}
+}
+
+func TestAuditResource(t *testing.T) {
+	testCases := []struct {
+		path         string
+		resourceType string
+		resourceID   string
+	}{
+		{"/v1/networks/", "networks", ""},
+		{"/v1/networks/5b5b418c760aab15e771bde2", "networks", "5b5b418c760aab15e771bde2"},
+		{"/v1/users/5b5b418c760aab15e771bde2/tokens/5b5b418c760aab15e771bde3", "users", "5b5b418c760aab15e771bde2"},
+		{"/v1/users/signin", "users", ""},
+		{"/v1", "", ""},
+	}
+
+	for _, tc := range testCases {
+		resourceType, resourceID := AuditResource(tc.path)
+		assert.Equal(t, tc.resourceType, resourceType, "path: %s", tc.path)
+		assert.Equal(t, tc.resourceID, resourceID, "path: %s", tc.path)
+	}
+}
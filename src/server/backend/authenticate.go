@@ -2,8 +2,10 @@ package backend
 
 import (
 	"github.com/linkernetworks/mongo"
+	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
 	"github.com/hwchiu/vortex/src/utils"
+	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -12,8 +14,17 @@ var (
 	SecretKey = "linkernetworks"
 )
 
-// Authenticate is a user authenticate function
-func Authenticate(session *mongo.Session, credential entity.LoginCredential) (entity.User, bool, error) {
+// Authenticator is the interface to authenticate the login credential of a user
+// It returns false without error when the credential is incorrect
+type Authenticator interface {
+	Authenticate(session *mongo.Session, credential entity.LoginCredential) (entity.User, bool, error)
+}
+
+// MongoAuthenticator authenticates the user by the password hash stored in mongo
+type MongoAuthenticator struct{}
+
+// Authenticate will check the password with the hashed password of the user
+func (MongoAuthenticator) Authenticate(session *mongo.Session, credential entity.LoginCredential) (entity.User, bool, error) {
 	authenticatedUser := entity.User{}
 	if err := session.FindOne(
 		entity.UserCollectionName,
@@ -28,3 +39,47 @@ func Authenticate(session *mongo.Session, credential entity.LoginCredential) (en
 	}
 	return entity.User{}, false, nil
 }
+
+// Authenticators tries the authenticators in order and uses the first passed one
+type Authenticators []Authenticator
+
+// Authenticate will authenticate the credential by the authenticators in order
+func (a Authenticators) Authenticate(session *mongo.Session, credential entity.LoginCredential) (entity.User, bool, error) {
+	for _, authenticator := range a {
+		user, passed, err := authenticator.Authenticate(session, credential)
+		if err != nil && err != mgo.ErrNotFound {
+			return entity.User{}, false, err
+		}
+		if passed {
+			return user, true, nil
+		}
+	}
+	return entity.User{}, false, nil
+}
+
+var authenticator Authenticator = MongoAuthenticator{}
+
+// SetupAuthenticator will set up the authenticators from the config
+// The users in mongo are always authenticated first, then the users of the LDAP when it's configured
+func SetupAuthenticator(cf config.Config) error {
+	authenticators := Authenticators{MongoAuthenticator{}}
+	if cf.LDAP != nil {
+		ldapAuthenticator, err := NewLDAPAuthenticator(cf.LDAP)
+		if err != nil {
+			return err
+		}
+		authenticators = append(authenticators, ldapAuthenticator)
+	}
+
+	if len(authenticators) == 1 {
+		authenticator = authenticators[0]
+	} else {
+		authenticator = authenticators
+	}
+	return nil
+}
+
+// Authenticate is a user authenticate function
+func Authenticate(session *mongo.Session, credential entity.LoginCredential) (entity.User, bool, error) {
+	return authenticator.Authenticate(session, credential)
+}
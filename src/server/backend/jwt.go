@@ -7,14 +7,26 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/linkernetworks/logger"
 	"github.com/hwchiu/vortex/src/entity"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // GenerateToken is for generating token
 func GenerateToken(userID string, user entity.User) (string, error) {
+	return generateToken(userID, bson.NewObjectId().Hex(), user)
+}
+
+// GenerateSessionToken is for generating the access token of the session
+// The token ID is the session ID, so revoking the session revokes all of its access tokens
+func GenerateSessionToken(s entity.Session, user entity.User) (string, error) {
+	return generateToken(s.UserID.Hex(), s.ID.Hex(), user)
+}
+
+func generateToken(userID string, tokenID string, user entity.User) (string, error) {
+	ks := GetKeySet()
 	token := jwt.New(jwt.SigningMethodHS256)
 	token.Claims = jwt.MapClaims{
 		// issuer of the claim
-		"exp": time.Now().Add(time.Hour * time.Duration(12)).Unix(),
+		"exp": time.Now().Add(ks.Expiration).Unix(),
 		// issued-at time
 		"iat": time.Now().Unix(),
 		// user role
@@ -25,8 +37,10 @@ func GenerateToken(userID string, user entity.User) (string, error) {
 		"displayName": user.DisplayName,
 		// the subject of this token. This is the user associated with the relevant action
 		"sub": userID,
+		// the ID of this token, it's used to revoke the token
+		"jti": tokenID,
 	}
-	return token.SignedString([]byte(SecretKey))
+	return ks.Sign(token)
 }
 
 // VerifyToken is for verifing the JWT
@@ -35,9 +49,7 @@ func VerifyToken(tokenData []byte) bool {
 	tokenData = regexp.MustCompile(`\s*$`).ReplaceAll(tokenData, []byte{})
 
 	// Parse the token
-	token, err := jwt.Parse(string(tokenData), func(t *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
-	})
+	token, err := jwt.Parse(string(tokenData), GetKeySet().Keyfunc)
 	// Print an error if we can't parse for some reason
 	if err != nil {
 		logger.Infof("Couldn't parse token: %v", err)
@@ -0,0 +1,225 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hwchiu/vortex/src/config"
+)
+
+// The const for the default key set
+const (
+	DefaultKeyID             = "default"
+	DefaultExpiration        = 12 * time.Hour
+	DefaultRefreshExpiration = 7 * 24 * time.Hour
+)
+
+// SigningKey is the structure for a key to sign and verify the JWT
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// KeySet is the structure for the keys to sign and verify the JWT
+// All keys can verify a token by its kid header, only the signing key signs the new tokens
+type KeySet struct {
+	SigningKeyID      string
+	Expiration        time.Duration
+	RefreshExpiration time.Duration
+	Keys              map[string]SigningKey
+}
+
+var keySet *KeySet
+
+// SetupKeySet will load the key set from the config and use it to sign and verify the JWT
+// The HS256 key from SecretKey is used when the config is empty
+func SetupKeySet(cf *config.JWTConfig) error {
+	if cf == nil {
+		keySet = nil
+		return nil
+	}
+	ks, err := NewKeySet(cf)
+	if err != nil {
+		return err
+	}
+	keySet = ks
+	return nil
+}
+
+// GetKeySet will return the current key set
+func GetKeySet() *KeySet {
+	if keySet != nil {
+		return keySet
+	}
+	return &KeySet{
+		SigningKeyID:      DefaultKeyID,
+		Expiration:        DefaultExpiration,
+		RefreshExpiration: DefaultRefreshExpiration,
+		Keys: map[string]SigningKey{
+			DefaultKeyID: {
+				ID:        DefaultKeyID,
+				Method:    jwt.SigningMethodHS256,
+				SignKey:   []byte(SecretKey),
+				VerifyKey: []byte(SecretKey),
+			},
+		},
+	}
+}
+
+// NewKeySet will create a key set from the config
+func NewKeySet(cf *config.JWTConfig) (*KeySet, error) {
+	ks := &KeySet{
+		SigningKeyID:      cf.SigningKeyID,
+		Expiration:        DefaultExpiration,
+		RefreshExpiration: DefaultRefreshExpiration,
+		Keys:              map[string]SigningKey{},
+	}
+
+	if cf.Expiration != "" {
+		expiration, err := time.ParseDuration(cf.Expiration)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JWT expiration %s: %v", cf.Expiration, err)
+		}
+		ks.Expiration = expiration
+	}
+
+	if cf.RefreshExpiration != "" {
+		expiration, err := time.ParseDuration(cf.RefreshExpiration)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid JWT refresh expiration %s: %v", cf.RefreshExpiration, err)
+		}
+		ks.RefreshExpiration = expiration
+	}
+
+	for _, keyConfig := range cf.Keys {
+		if keyConfig.ID == "" {
+			return nil, fmt.Errorf("The ID of the JWT key is required")
+		}
+		if _, ok := ks.Keys[keyConfig.ID]; ok {
+			return nil, fmt.Errorf("Duplicate JWT key ID: %s", keyConfig.ID)
+		}
+		key, err := loadSigningKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("Load the JWT key %s fail: %v", keyConfig.ID, err)
+		}
+		ks.Keys[key.ID] = key
+	}
+
+	signingKey, ok := ks.Keys[ks.SigningKeyID]
+	if !ok {
+		return nil, fmt.Errorf("The signing key %s is not found", ks.SigningKeyID)
+	}
+	if signingKey.SignKey == nil {
+		return nil, fmt.Errorf("The signing key %s has no private key", ks.SigningKeyID)
+	}
+	return ks, nil
+}
+
+func loadSigningKey(cf config.JWTKeyConfig) (SigningKey, error) {
+	key := SigningKey{ID: cf.ID}
+
+	switch strings.ToUpper(cf.Algorithm) {
+	case "HS256", "":
+		secret := cf.Secret
+		if cf.SecretFile != "" {
+			data, err := ioutil.ReadFile(cf.SecretFile)
+			if err != nil {
+				return key, err
+			}
+			secret = strings.TrimSpace(string(data))
+		}
+		if secret == "" {
+			return key, fmt.Errorf("The secret of HS256 is empty")
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.SignKey = []byte(secret)
+		key.VerifyKey = []byte(secret)
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if cf.PrivateKeyFile != "" {
+			data, err := ioutil.ReadFile(cf.PrivateKeyFile)
+			if err != nil {
+				return key, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+			key.SignKey = privateKey
+			key.VerifyKey = &privateKey.PublicKey
+		}
+		if cf.PublicKeyFile != "" {
+			data, err := ioutil.ReadFile(cf.PublicKeyFile)
+			if err != nil {
+				return key, err
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+			key.VerifyKey = publicKey
+		}
+	case "ES256":
+		key.Method = jwt.SigningMethodES256
+		if cf.PrivateKeyFile != "" {
+			data, err := ioutil.ReadFile(cf.PrivateKeyFile)
+			if err != nil {
+				return key, err
+			}
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+			key.SignKey = privateKey
+			key.VerifyKey = &privateKey.PublicKey
+		}
+		if cf.PublicKeyFile != "" {
+			data, err := ioutil.ReadFile(cf.PublicKeyFile)
+			if err != nil {
+				return key, err
+			}
+			publicKey, err := jwt.ParseECPublicKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+			key.VerifyKey = publicKey
+		}
+	default:
+		return key, fmt.Errorf("Unsupported algorithm: %s", cf.Algorithm)
+	}
+
+	if key.VerifyKey == nil {
+		return key, fmt.Errorf("The key file of %s is required", key.Method.Alg())
+	}
+	return key, nil
+}
+
+// Sign will sign the token by the signing key and set the kid header
+func (ks *KeySet) Sign(token *jwt.Token) (string, error) {
+	key, ok := ks.Keys[ks.SigningKeyID]
+	if !ok {
+		return "", fmt.Errorf("The signing key %s is not found", ks.SigningKeyID)
+	}
+	token.Method = key.Method
+	token.Header["alg"] = key.Method.Alg()
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SignKey)
+}
+
+// Keyfunc will find the key to verify the token by its kid header
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.Keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown JWT key ID: %s", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %s", token.Method.Alg())
+	}
+	return key.VerifyKey, nil
+}
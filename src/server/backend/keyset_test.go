@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, dir, name, pemType string, data []byte) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: data}), 0600)
+	require.NoError(t, err)
+	return path
+}
+
+func generateKeyFiles(t *testing.T, dir string) (rsaPrivate, rsaPublic, ecPrivate, ecPublic string) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPrivate = writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	rsaPublicBytes, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPublic = writePEM(t, dir, "rsa.pub", "PUBLIC KEY", rsaPublicBytes)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecPrivateBytes, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPrivate = writePEM(t, dir, "ec.pem", "EC PRIVATE KEY", ecPrivateBytes)
+	ecPublicBytes, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+	ecPublic = writePEM(t, dir, "ec.pub", "PUBLIC KEY", ecPublicBytes)
+	return
+}
+
+func TestSigningAlgorithms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vortex-jwt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer SetupKeySet(nil)
+
+	rsaPrivate, _, ecPrivate, _ := generateKeyFiles(t, dir)
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("file-secret\n"), 0600))
+
+	testCases := []struct {
+		cases string
+		key   config.JWTKeyConfig
+	}{
+		{"HS256", config.JWTKeyConfig{ID: "hs", Algorithm: "HS256", Secret: "secret"}},
+		{"HS256File", config.JWTKeyConfig{ID: "hs-file", Algorithm: "HS256", SecretFile: secretFile}},
+		{"RS256", config.JWTKeyConfig{ID: "rs", Algorithm: "RS256", PrivateKeyFile: rsaPrivate}},
+		{"ES256", config.JWTKeyConfig{ID: "es", Algorithm: "ES256", PrivateKeyFile: ecPrivate}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			err := SetupKeySet(&config.JWTConfig{
+				SigningKeyID: tc.key.ID,
+				Expiration:   "1h",
+				Keys:         []config.JWTKeyConfig{tc.key},
+			})
+			require.NoError(t, err)
+
+			tokenString, err := GenerateToken("234243353535330", entity.User{Role: "user"})
+			require.NoError(t, err)
+			assert.True(t, VerifyToken([]byte(tokenString)))
+
+			token, err := jwt.Parse(tokenString, GetKeySet().Keyfunc)
+			require.NoError(t, err)
+			assert.Equal(t, tc.key.ID, token.Header["kid"])
+			assert.Equal(t, tc.key.Algorithm, token.Header["alg"])
+
+			claims := token.Claims.(jwt.MapClaims)
+			expiration := time.Unix(int64(claims["exp"].(float64)), 0)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), expiration, time.Minute)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vortex-jwt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer SetupKeySet(nil)
+
+	rsaPrivate, rsaPublic, ecPrivate, _ := generateKeyFiles(t, dir)
+
+	// sign a token with the old key
+	err = SetupKeySet(&config.JWTConfig{
+		SigningKeyID: "old",
+		Keys: []config.JWTKeyConfig{
+			{ID: "old", Algorithm: "RS256", PrivateKeyFile: rsaPrivate},
+		},
+	})
+	require.NoError(t, err)
+	oldToken, err := GenerateToken("234243353535330", entity.User{Role: "user"})
+	require.NoError(t, err)
+
+	// rotate to the new key, the old key can only verify
+	err = SetupKeySet(&config.JWTConfig{
+		SigningKeyID: "new",
+		Keys: []config.JWTKeyConfig{
+			{ID: "old", Algorithm: "RS256", PublicKeyFile: rsaPublic},
+			{ID: "new", Algorithm: "ES256", PrivateKeyFile: ecPrivate},
+		},
+	})
+	require.NoError(t, err)
+	newToken, err := GenerateToken("234243353535330", entity.User{Role: "user"})
+	require.NoError(t, err)
+	assert.True(t, VerifyToken([]byte(oldToken)))
+	assert.True(t, VerifyToken([]byte(newToken)))
+
+	// retire the old key
+	err = SetupKeySet(&config.JWTConfig{
+		SigningKeyID: "new",
+		Keys: []config.JWTKeyConfig{
+			{ID: "new", Algorithm: "ES256", PrivateKeyFile: ecPrivate},
+		},
+	})
+	require.NoError(t, err)
+	assert.False(t, VerifyToken([]byte(oldToken)))
+	assert.True(t, VerifyToken([]byte(newToken)))
+}
+
+func TestInvalidKeySet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vortex-jwt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, rsaPublic, _, _ := generateKeyFiles(t, dir)
+
+	testCases := []struct {
+		cases string
+		cf    config.JWTConfig
+	}{
+		{"noSigningKey", config.JWTConfig{SigningKeyID: "none", Keys: []config.JWTKeyConfig{{ID: "hs", Secret: "secret"}}}},
+		{"verifyOnlySigningKey", config.JWTConfig{SigningKeyID: "rs", Keys: []config.JWTKeyConfig{{ID: "rs", Algorithm: "RS256", PublicKeyFile: rsaPublic}}}},
+		{"emptySecret", config.JWTConfig{SigningKeyID: "hs", Keys: []config.JWTKeyConfig{{ID: "hs", Algorithm: "HS256"}}}},
+		{"unknownAlgorithm", config.JWTConfig{SigningKeyID: "hs", Keys: []config.JWTKeyConfig{{ID: "hs", Algorithm: "none"}}}},
+		{"duplicateID", config.JWTConfig{SigningKeyID: "hs", Keys: []config.JWTKeyConfig{{ID: "hs", Secret: "a"}, {ID: "hs", Secret: "b"}}}},
+		{"invalidExpiration", config.JWTConfig{SigningKeyID: "hs", Expiration: "1y", Keys: []config.JWTKeyConfig{{ID: "hs", Secret: "a"}}}},
+		{"missingFile", config.JWTConfig{SigningKeyID: "rs", Keys: []config.JWTKeyConfig{{ID: "rs", Algorithm: "RS256", PrivateKeyFile: filepath.Join(dir, "none")}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			_, err := NewKeySet(&tc.cf)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	"gopkg.in/ldap.v2"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The default filters to find the user and its groups
+const (
+	DefaultLDAPUserFilter  = "(mail=%s)"
+	DefaultLDAPGroupFilter = "(member=%s)"
+)
+
+// errLDAPUserConflict means the username of the LDAP user is used by a local user
+var errLDAPUserConflict = errors.New("the username is used by a local user")
+
+// LDAPAuthenticator authenticates the user by binding to the LDAP server
+// The local user is created on the first login and its role is synced from the groups on every login
+type LDAPAuthenticator struct {
+	Config *config.LDAPConfig
+}
+
+// NewLDAPAuthenticator will check the config and create the LDAP authenticator
+func NewLDAPAuthenticator(cf *config.LDAPConfig) (*LDAPAuthenticator, error) {
+	if cf.Address == "" {
+		return nil, fmt.Errorf("The address of the LDAP server is required")
+	}
+	if cf.BaseDN == "" {
+		return nil, fmt.Errorf("The base DN of the LDAP users is required")
+	}
+	if cf.DefaultRole != "" && !IsRoleWithin(cf.DefaultRole, entity.RootRole) {
+		return nil, fmt.Errorf("Invalid LDAP default role: %s", cf.DefaultRole)
+	}
+	for group, role := range cf.GroupRoles {
+		if !IsRoleWithin(role, entity.RootRole) {
+			return nil, fmt.Errorf("Invalid LDAP role %s of the group %s", role, group)
+		}
+	}
+
+	ldapConfig := *cf
+	if ldapConfig.UserFilter == "" {
+		ldapConfig.UserFilter = DefaultLDAPUserFilter
+	}
+	if ldapConfig.GroupFilter == "" {
+		ldapConfig.GroupFilter = DefaultLDAPGroupFilter
+	}
+	return &LDAPAuthenticator{Config: &ldapConfig}, nil
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	if a.Config.TLS {
+		return ldap.DialTLS("tcp", a.Config.Address, &tls.Config{
+			InsecureSkipVerify: a.Config.InsecureSkipVerify,
+		})
+	}
+	return ldap.Dial("tcp", a.Config.Address)
+}
+
+// Authenticate will find the user in the directory and bind as the user with the password
+func (a *LDAPAuthenticator) Authenticate(session *mongo.Session, credential entity.LoginCredential) (entity.User, bool, error) {
+	// the empty password is an unauthenticated bind, it always succeeds
+	if credential.Password == "" {
+		return entity.User{}, false, nil
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return entity.User{}, false, err
+	}
+	defer conn.Close()
+
+	if a.Config.BindDN != "" {
+		if err := conn.Bind(a.Config.BindDN, a.Config.BindPassword); err != nil {
+			return entity.User{}, false, err
+		}
+	}
+
+	username := strings.ToLower(credential.Username)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.Config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(a.Config.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", "cn", "displayName", "givenName", "sn", "telephoneNumber", "memberOf"},
+		nil,
+	))
+	if err != nil {
+		return entity.User{}, false, err
+	}
+	if len(result.Entries) != 1 {
+		return entity.User{}, false, nil
+	}
+	entry := result.Entries[0]
+
+	// search the groups before binding as the user, the user may have no permission to search
+	groups, err := a.searchGroups(conn, entry)
+	if err != nil {
+		return entity.User{}, false, err
+	}
+
+	if err := conn.Bind(entry.DN, credential.Password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return entity.User{}, false, nil
+		}
+		return entity.User{}, false, err
+	}
+
+	role := a.mapRole(groups)
+	if role == "" {
+		return entity.User{}, false, nil
+	}
+
+	user, err := syncLDAPUser(session, username, entry, role)
+	switch err {
+	case errLDAPUserConflict:
+		// the LDAP user can not sign in as the local user with the same username
+		return entity.User{}, false, nil
+	case errInvitationRequired:
+		return entity.User{}, false, nil
+	}
+	if err != nil {
+		return entity.User{}, false, err
+	}
+	return user, true, nil
+}
+
+// searchGroups will return the DNs of the groups of the user
+// The memberOf attribute is used when the group base DN is not configured
+func (a *LDAPAuthenticator) searchGroups(conn *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	if a.Config.GroupBaseDN == "" {
+		return entry.GetAttributeValues("memberOf"), nil
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.Config.GroupBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(a.Config.GroupFilter, ldap.EscapeFilter(entry.DN)),
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []string{}
+	for _, group := range result.Entries {
+		groups = append(groups, group.DN)
+	}
+	return groups, nil
+}
+
+// mapRole will return the highest role of the groups, or the default role without any mapped groups
+func (a *LDAPAuthenticator) mapRole(groups []string) string {
+	role := a.Config.DefaultRole
+	for _, group := range groups {
+		for groupDN, groupRole := range a.Config.GroupRoles {
+			if strings.EqualFold(groupDN, group) && !IsRoleWithin(groupRole, role) {
+				role = groupRole
+			}
+		}
+	}
+	return role
+}
+
+// syncLDAPUser will create the local user on the first login, or update the role of the user provisioned by the LDAP
+// The local users are never changed, it returns errLDAPUserConflict when the username is used by one of them
+// When the signup is invite-only, the new user requires an invitation for the username, the role is still mapped from the groups
+func syncLDAPUser(session *mongo.Session, username string, entry *ldap.Entry, role string) (entity.User, error) {
+	user := entity.User{}
+	err := session.FindOne(
+		entity.UserCollectionName,
+		bson.M{"loginCredential.username": username},
+		&user,
+	)
+	switch err {
+	case nil:
+		if user.Source != entity.LDAPUserSource {
+			return entity.User{}, errLDAPUserConflict
+		}
+		if user.Role != role {
+			if err := session.C(entity.UserCollectionName).UpdateId(user.ID, bson.M{
+				"$set": bson.M{"role": role},
+			}); err != nil {
+				return entity.User{}, err
+			}
+			user.Role = role
+		}
+		return user, nil
+	case mgo.ErrNotFound:
+		invitation, err := findSignupInvitation(session, username)
+		if err != nil {
+			return entity.User{}, err
+		}
+
+		displayName := entry.GetAttributeValue("displayName")
+		if displayName == "" {
+			displayName = entry.GetAttributeValue("cn")
+		}
+		if displayName == "" {
+			displayName = username
+		}
+		// the password is not stored, the user can only sign in by the LDAP
+		user = entity.User{
+			ID: bson.NewObjectId(),
+			LoginCredential: entity.LoginCredential{
+				Username: username,
+			},
+			DisplayName: displayName,
+			Role:        role,
+			FirstName:   entry.GetAttributeValue("givenName"),
+			LastName:    entry.GetAttributeValue("sn"),
+			PhoneNumber: entry.GetAttributeValue("telephoneNumber"),
+			CreatedAt:   timeutils.Now(),
+			Source:      entity.LDAPUserSource,
+		}
+		if err := session.Insert(entity.UserCollectionName, &user); err != nil {
+			return entity.User{}, err
+		}
+		if err := redeemInvitation(session, invitation); err != nil {
+			return entity.User{}, err
+		}
+		return user, nil
+	default:
+		return entity.User{}, err
+	}
+}
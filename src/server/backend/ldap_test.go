@@ -0,0 +1,481 @@
+package backend
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/stretchr/testify/suite"
+	ber "gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type ldapStubEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// ldapStub is an in-process LDAP server which only supports the simple bind and the search
+// with the equality, presence and and filters
+type ldapStub struct {
+	sync.Mutex
+	listener net.Listener
+	entries  []ldapStubEntry
+}
+
+func newLDAPStub(entries []ldapStubEntry) (*ldapStub, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	stub := &ldapStub{listener: listener, entries: entries}
+	go stub.serve()
+	return stub, nil
+}
+
+func (s *ldapStub) setEntries(entries []ldapStubEntry) {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = entries
+}
+
+func (s *ldapStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ldapStub) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		s.Lock()
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			var code uint8 = ldap.LDAPResultInvalidCredentials
+			for _, entry := range s.entries {
+				if entry.dn == dn && entry.password != "" && entry.password == password {
+					code = ldap.LDAPResultSuccess
+				}
+			}
+			conn.Write(ldapStubMessage(messageID, ldapStubResult(ldap.ApplicationBindResponse, code)).Bytes())
+		case ldap.ApplicationSearchRequest:
+			baseDN := op.Children[0].Value.(string)
+			for _, entry := range s.entries {
+				if strings.HasSuffix(entry.dn, baseDN) && entry.match(op.Children[6]) {
+					conn.Write(ldapStubMessage(messageID, entry.packet()).Bytes())
+				}
+			}
+			conn.Write(ldapStubMessage(messageID, ldapStubResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			s.Unlock()
+			return
+		}
+		s.Unlock()
+	}
+}
+
+func (s *ldapStub) Close() {
+	s.listener.Close()
+}
+
+func (e ldapStubEntry) match(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !e.match(child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterEqualityMatch:
+		name := filter.Children[0].Value.(string)
+		value := filter.Children[1].Value.(string)
+		for _, v := range e.attributes[name] {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		_, ok := e.attributes[filter.Data.String()]
+		return ok
+	}
+	return false
+}
+
+func (e ldapStubEntry) packet() *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range e.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	packet.AppendChild(attributes)
+	return packet
+}
+
+func ldapStubMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func ldapStubResult(tag ber.Tag, code uint8) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return packet
+}
+
+const (
+	ldapAdminsDN = "cn=admins,ou=groups,dc=example,dc=com"
+	ldapDevsDN   = "cn=devs,ou=groups,dc=example,dc=com"
+)
+
+var ldapStubEntries = []ldapStubEntry{
+	{
+		dn:       "cn=search,dc=example,dc=com",
+		password: "search",
+	},
+	{
+		dn:       "uid=alice,ou=people,dc=example,dc=com",
+		password: "alice",
+		attributes: map[string][]string{
+			"mail":            {"alice@example.com"},
+			"displayName":     {"Alice"},
+			"givenName":       {"Alice"},
+			"sn":              {"Liddell"},
+			"telephoneNumber": {"0911111111"},
+			"memberOf":        {ldapAdminsDN, ldapDevsDN},
+		},
+	},
+	{
+		dn:       "uid=bob,ou=people,dc=example,dc=com",
+		password: "bob",
+		attributes: map[string][]string{
+			"mail":     {"bob@example.com"},
+			"cn":       {"Bob"},
+			"memberOf": {ldapDevsDN},
+		},
+	},
+	{
+		dn:       "uid=carol,ou=people,dc=example,dc=com",
+		password: "carol",
+		attributes: map[string][]string{
+			"mail": {"carol@example.com"},
+		},
+	},
+	{
+		dn: ldapAdminsDN,
+		attributes: map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"member":      {"uid=alice,ou=people,dc=example,dc=com"},
+		},
+	},
+	{
+		dn: ldapDevsDN,
+		attributes: map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"member":      {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"},
+		},
+	},
+}
+
+type LDAPTestSuite struct {
+	suite.Suite
+	session *mongo.Session
+	stub    *ldapStub
+	config  config.LDAPConfig
+}
+
+func (suite *LDAPTestSuite) SetupSuite() {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	suite.session = sp.Mongo.NewSession()
+
+	stub, err := newLDAPStub(ldapStubEntries)
+	suite.Require().NoError(err)
+	suite.stub = stub
+
+	suite.config = config.LDAPConfig{
+		Address:      stub.listener.Addr().String(),
+		BindDN:       "cn=search,dc=example,dc=com",
+		BindPassword: "search",
+		BaseDN:       "ou=people,dc=example,dc=com",
+		GroupRoles: map[string]string{
+			ldapAdminsDN: entity.RootRole,
+			ldapDevsDN:   entity.UserRole,
+		},
+	}
+}
+
+func (suite *LDAPTestSuite) TearDownTest() {
+	suite.stub.setEntries(ldapStubEntries)
+	for _, username := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		suite.session.Remove(entity.UserCollectionName, "loginCredential.username", username)
+	}
+}
+
+func (suite *LDAPTestSuite) TearDownSuite() {
+	suite.stub.Close()
+	suite.session.Close()
+}
+
+func TestLDAPSuite(t *testing.T) {
+	suite.Run(t, new(LDAPTestSuite))
+}
+
+func (suite *LDAPTestSuite) TestAuthenticate() {
+	authenticator, err := NewLDAPAuthenticator(&suite.config)
+	suite.Require().NoError(err)
+
+	// the local user is created on the first login
+	user, passed, err := authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "Alice@example.com",
+		Password: "alice",
+	})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal("alice@example.com", user.LoginCredential.Username)
+	suite.Equal(entity.RootRole, user.Role)
+	suite.Equal("Alice", user.DisplayName)
+	suite.Equal("Liddell", user.LastName)
+	suite.Empty(user.LoginCredential.Password)
+	suite.Equal(entity.LDAPUserSource, user.Source)
+
+	localUser, err := FindUserByID(suite.session, user.ID)
+	suite.NoError(err)
+	suite.Equal(user.Role, localUser.Role)
+
+	// the same local user is used on the next login
+	again, passed, err := authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "alice@example.com",
+		Password: "alice",
+	})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(user.ID, again.ID)
+
+	user, passed, err = authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "bob@example.com",
+		Password: "bob",
+	})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(entity.UserRole, user.Role)
+	suite.Equal("Bob", user.DisplayName)
+}
+
+func (suite *LDAPTestSuite) TestFailedAuthenticate() {
+	authenticator, err := NewLDAPAuthenticator(&suite.config)
+	suite.Require().NoError(err)
+
+	testCases := []struct {
+		cases      string
+		credential entity.LoginCredential
+	}{
+		{"wrongPassword", entity.LoginCredential{Username: "alice@example.com", Password: "wrong"}},
+		{"emptyPassword", entity.LoginCredential{Username: "alice@example.com", Password: ""}},
+		{"unknownUser", entity.LoginCredential{Username: "nobody@example.com", Password: "nobody"}},
+		{"noMappedGroup", entity.LoginCredential{Username: "carol@example.com", Password: "carol"}},
+	}
+
+	for _, tc := range testCases {
+		_, passed, err := authenticator.Authenticate(suite.session, tc.credential)
+		suite.NoError(err, tc.cases)
+		suite.False(passed, tc.cases)
+	}
+
+	count, err := suite.session.Count(entity.UserCollectionName, bson.M{"loginCredential.username": "carol@example.com"})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
+
+func (suite *LDAPTestSuite) TestLocalUserConflict() {
+	authenticator, err := NewLDAPAuthenticator(&suite.config)
+	suite.Require().NoError(err)
+
+	// the local user with the same username is not taken over by the LDAP user
+	local := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: "bob@example.com",
+			Password: "hashed",
+		},
+		DisplayName: "Local Bob",
+		Role:        entity.GuestRole,
+	}
+	suite.Require().NoError(suite.session.Insert(entity.UserCollectionName, &local))
+
+	_, passed, err := authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "bob@example.com",
+		Password: "bob",
+	})
+	suite.NoError(err)
+	suite.False(passed)
+
+	user, err := FindUserByID(suite.session, local.ID)
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, user.Role)
+	suite.Equal("", user.Source)
+}
+
+func (suite *LDAPTestSuite) TestInviteOnly() {
+	suite.Require().NoError(SetupSignup(&config.SignupConfig{InviteOnly: true}))
+	defer SetupSignup(nil)
+
+	authenticator, err := NewLDAPAuthenticator(&suite.config)
+	suite.Require().NoError(err)
+
+	// the new user is not created without an invitation
+	credential := entity.LoginCredential{Username: "alice@example.com", Password: "alice"}
+	_, passed, err := authenticator.Authenticate(suite.session, credential)
+	suite.NoError(err)
+	suite.False(passed)
+
+	invitation, err := CreateInvitation(suite.session, entity.Invitation{
+		Email: "alice@example.com",
+		Role:  entity.GuestRole,
+	})
+	suite.Require().NoError(err)
+	defer suite.session.Remove(entity.InvitationCollectionName, "_id", invitation.ID)
+
+	// the role is still mapped from the groups, and the invitation is redeemed
+	user, passed, err := authenticator.Authenticate(suite.session, credential)
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(entity.RootRole, user.Role)
+
+	count, err := suite.session.Count(entity.InvitationCollectionName, bson.M{"_id": invitation.ID})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
+
+func (suite *LDAPTestSuite) TestDefaultRole() {
+	cf := suite.config
+	cf.DefaultRole = entity.GuestRole
+	authenticator, err := NewLDAPAuthenticator(&cf)
+	suite.Require().NoError(err)
+
+	user, passed, err := authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "carol@example.com",
+		Password: "carol",
+	})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(entity.GuestRole, user.Role)
+}
+
+func (suite *LDAPTestSuite) TestGroupSearch() {
+	cf := suite.config
+	cf.GroupBaseDN = "ou=groups,dc=example,dc=com"
+	cf.GroupFilter = "(&(objectClass=groupOfNames)(member=%s))"
+	authenticator, err := NewLDAPAuthenticator(&cf)
+	suite.Require().NoError(err)
+
+	user, passed, err := authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "alice@example.com",
+		Password: "alice",
+	})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(entity.RootRole, user.Role)
+
+	// the role is synced from the directory on every login
+	entries := []ldapStubEntry{}
+	for _, entry := range ldapStubEntries {
+		if entry.dn == ldapAdminsDN {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	suite.stub.setEntries(entries)
+
+	user, passed, err = authenticator.Authenticate(suite.session, entity.LoginCredential{
+		Username: "alice@example.com",
+		Password: "alice",
+	})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(entity.UserRole, user.Role)
+
+	localUser, err := FindUserByID(suite.session, user.ID)
+	suite.NoError(err)
+	suite.Equal(entity.UserRole, localUser.Role)
+}
+
+func (suite *LDAPTestSuite) TestSetupAuthenticator() {
+	defer SetupAuthenticator(config.Config{})
+
+	hashedPassword, err := utils.HashPassword("local")
+	suite.NoError(err)
+	localUser := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: "local@example.com",
+			Password: hashedPassword,
+		},
+		Role: entity.UserRole,
+	}
+	err = suite.session.Insert(entity.UserCollectionName, &localUser)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.UserCollectionName, "_id", localUser.ID)
+
+	err = SetupAuthenticator(config.Config{LDAP: &suite.config})
+	suite.Require().NoError(err)
+
+	// both the local and the LDAP users can sign in
+	user, passed, err := Authenticate(suite.session, entity.LoginCredential{Username: "local@example.com", Password: "local"})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(localUser.ID, user.ID)
+
+	user, passed, err = Authenticate(suite.session, entity.LoginCredential{Username: "bob@example.com", Password: "bob"})
+	suite.NoError(err)
+	suite.True(passed)
+	suite.Equal(entity.UserRole, user.Role)
+
+	_, passed, err = Authenticate(suite.session, entity.LoginCredential{Username: "bob@example.com", Password: "local"})
+	suite.NoError(err)
+	suite.False(passed)
+
+	// the invalid config is rejected
+	err = SetupAuthenticator(config.Config{LDAP: &config.LDAPConfig{Address: "localhost:389"}})
+	suite.Error(err)
+	err = SetupAuthenticator(config.Config{LDAP: &config.LDAPConfig{
+		Address:    "localhost:389",
+		BaseDN:     "dc=example,dc=com",
+		GroupRoles: map[string]string{ldapAdminsDN: "admin"},
+	}})
+	suite.Error(err)
+}
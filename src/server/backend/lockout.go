@@ -0,0 +1,225 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/linkernetworks/mongo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The defaults of the brute-force protection
+const (
+	DefaultFreeAttempts   = 3
+	DefaultIPFreeAttempts = 20
+	DefaultBaseDelay      = time.Second
+	DefaultMaxDelay       = 5 * time.Minute
+	DefaultLockThreshold  = 10
+	DefaultLockDuration   = time.Hour
+	DefaultResetAfter     = time.Hour
+)
+
+// Lockout is the brute-force protection of signing in
+// The failed attempts are tracked per username and per client IP with the exponential backoff,
+// and the account is locked after the threshold of failures
+type Lockout struct {
+	FreeAttempts   int
+	IPFreeAttempts int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	Threshold      int
+	LockDuration   time.Duration
+	ResetAfter     time.Duration
+}
+
+var lockout *Lockout
+
+// SetupLockout will load the brute-force protection from the config
+// The defaults are used when the config is empty
+func SetupLockout(cf *config.LockoutConfig) error {
+	if cf == nil {
+		lockout = nil
+		return nil
+	}
+	l, err := NewLockout(cf)
+	if err != nil {
+		return err
+	}
+	lockout = l
+	return nil
+}
+
+// GetLockout will return the current brute-force protection
+func GetLockout() *Lockout {
+	if lockout != nil {
+		return lockout
+	}
+	return defaultLockout()
+}
+
+func defaultLockout() *Lockout {
+	return &Lockout{
+		FreeAttempts:   DefaultFreeAttempts,
+		IPFreeAttempts: DefaultIPFreeAttempts,
+		BaseDelay:      DefaultBaseDelay,
+		MaxDelay:       DefaultMaxDelay,
+		Threshold:      DefaultLockThreshold,
+		LockDuration:   DefaultLockDuration,
+		ResetAfter:     DefaultResetAfter,
+	}
+}
+
+// NewLockout will create the brute-force protection from the config, the zero values use the defaults
+func NewLockout(cf *config.LockoutConfig) (*Lockout, error) {
+	l := defaultLockout()
+
+	for _, n := range []struct {
+		value  int
+		target *int
+	}{
+		{cf.FreeAttempts, &l.FreeAttempts},
+		{cf.IPFreeAttempts, &l.IPFreeAttempts},
+		{cf.Threshold, &l.Threshold},
+	} {
+		if n.value < 0 {
+			return nil, fmt.Errorf("The attempts of the lockout can not be negative: %d", n.value)
+		}
+		if n.value > 0 {
+			*n.target = n.value
+		}
+	}
+
+	for _, d := range []struct {
+		value  string
+		target *time.Duration
+	}{
+		{cf.BaseDelay, &l.BaseDelay},
+		{cf.MaxDelay, &l.MaxDelay},
+		{cf.LockDuration, &l.LockDuration},
+		{cf.ResetAfter, &l.ResetAfter},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid duration of the lockout %s: %v", d.value, err)
+		}
+		*d.target = duration
+	}
+	return l, nil
+}
+
+func usernameAttemptID(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func ipAttemptID(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff will return the delay after the failures, it's doubled for every failure over the free attempts
+func (l *Lockout) backoff(failures, freeAttempts int) time.Duration {
+	if failures < freeAttempts {
+		return 0
+	}
+	delay := l.BaseDelay
+	for i := freeAttempts; i < failures && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.MaxDelay {
+		return l.MaxDelay
+	}
+	return delay
+}
+
+// Check will return how long the client has to wait before signing in as the username
+// The username and password are not checked when the wait is positive
+func (l *Lockout) Check(session *mongo.Session, username, ip string) (time.Duration, error) {
+	attempts := []entity.LoginAttempt{}
+	if err := session.C(entity.LoginAttemptCollectionName).Find(bson.M{
+		"_id": bson.M{"$in": []string{usernameAttemptID(username), ipAttemptID(ip)}},
+	}).All(&attempts); err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	now := time.Now()
+	for _, attempt := range attempts {
+		for _, until := range []*time.Time{attempt.BlockedUntil, attempt.LockedUntil} {
+			if until != nil && until.Sub(now) > wait {
+				wait = until.Sub(now)
+			}
+		}
+	}
+	return wait, nil
+}
+
+// RecordFailure will count the failed attempt of the username and client IP,
+// and block the next attempts by the backoff or lock the account
+func (l *Lockout) RecordFailure(session *mongo.Session, username, ip string) error {
+	session.C(entity.LoginAttemptCollectionName).EnsureIndex(mgo.Index{
+		Key:         []string{"expiresAt"},
+		ExpireAfter: time.Second,
+	})
+
+	for _, id := range []string{usernameAttemptID(username), ipAttemptID(ip)} {
+		now := time.Now()
+		expiresAt := now.Add(l.ResetAfter)
+
+		attempt := entity.LoginAttempt{}
+		if _, err := session.C(entity.LoginAttemptCollectionName).FindId(id).Apply(mgo.Change{
+			Update: bson.M{
+				"$inc": bson.M{"failures": 1},
+				"$max": bson.M{"expiresAt": expiresAt},
+			},
+			Upsert:    true,
+			ReturnNew: true,
+		}, &attempt); err != nil {
+			return err
+		}
+
+		freeAttempts := l.IPFreeAttempts
+		update := bson.M{}
+		if id == usernameAttemptID(username) {
+			freeAttempts = l.FreeAttempts
+			if attempt.Failures >= l.Threshold {
+				lockedUntil := now.Add(l.LockDuration)
+				update["lockedUntil"] = lockedUntil
+				// keep the attempt until the account is unlocked
+				if lockedUntil.After(expiresAt) {
+					update["expiresAt"] = lockedUntil
+				}
+			}
+		}
+		if delay := l.backoff(attempt.Failures, freeAttempts); delay > 0 {
+			update["blockedUntil"] = now.Add(delay)
+		}
+		if len(update) == 0 {
+			continue
+		}
+		if err := session.C(entity.LoginAttemptCollectionName).UpdateId(id, bson.M{"$set": update}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset will forget the failed attempts of the username after signing in
+// The failures of the client IP are kept, so an attacker can not reset them by its own account
+func (l *Lockout) Reset(session *mongo.Session, username string) error {
+	err := session.C(entity.LoginAttemptCollectionName).RemoveId(usernameAttemptID(username))
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// UnlockUser will unlock the account of the username and forget its failed attempts
+func UnlockUser(session *mongo.Session, username string) error {
+	return GetLockout().Reset(session, username)
+}
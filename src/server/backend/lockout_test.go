@@ -0,0 +1,176 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestBackoff(t *testing.T) {
+	l := &Lockout{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	testCases := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.delay, l.backoff(tc.failures, 3), "failures: %d", tc.failures)
+	}
+}
+
+func TestNewLockout(t *testing.T) {
+	l, err := NewLockout(&config.LockoutConfig{
+		FreeAttempts: 5,
+		MaxDelay:     "1m",
+		LockDuration: "30m",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, l.FreeAttempts)
+	assert.Equal(t, DefaultIPFreeAttempts, l.IPFreeAttempts)
+	assert.Equal(t, DefaultBaseDelay, l.BaseDelay)
+	assert.Equal(t, time.Minute, l.MaxDelay)
+	assert.Equal(t, 30*time.Minute, l.LockDuration)
+
+	_, err = NewLockout(&config.LockoutConfig{ResetAfter: "1y"})
+	assert.Error(t, err)
+	_, err = NewLockout(&config.LockoutConfig{Threshold: -1})
+	assert.Error(t, err)
+}
+
+type LockoutTestSuite struct {
+	suite.Suite
+	session *mongo.Session
+	lockout *Lockout
+}
+
+func (suite *LockoutTestSuite) SetupSuite() {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	suite.session = sp.Mongo.NewSession()
+	suite.lockout = &Lockout{
+		FreeAttempts:   2,
+		IPFreeAttempts: 3,
+		BaseDelay:      time.Minute,
+		MaxDelay:       time.Hour,
+		Threshold:      4,
+		LockDuration:   2 * time.Hour,
+		ResetAfter:     time.Hour,
+	}
+}
+
+func (suite *LockoutTestSuite) TearDownSuite() {
+	suite.session.Close()
+}
+
+func TestLockoutSuite(t *testing.T) {
+	suite.Run(t, new(LockoutTestSuite))
+}
+
+func (suite *LockoutTestSuite) TestUsernameBackoff() {
+	username := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	ip := bson.NewObjectId().Hex()
+	defer UnlockUser(suite.session, username)
+
+	err := suite.lockout.RecordFailure(suite.session, username, ip)
+	suite.NoError(err)
+	wait, err := suite.lockout.Check(suite.session, username, ip)
+	suite.NoError(err)
+	suite.Equal(time.Duration(0), wait)
+
+	// the backoff starts after the free attempts
+	err = suite.lockout.RecordFailure(suite.session, username, ip)
+	suite.NoError(err)
+	wait, err = suite.lockout.Check(suite.session, username, ip)
+	suite.NoError(err)
+	suite.InDelta(float64(time.Minute), float64(wait), float64(time.Second))
+
+	// the username is blocked from other IPs
+	wait, err = suite.lockout.Check(suite.session, username, bson.NewObjectId().Hex())
+	suite.NoError(err)
+	suite.True(wait > 0)
+
+	// the username is case insensitive
+	wait, err = suite.lockout.Check(suite.session, strings.ToUpper(username), ip)
+	suite.NoError(err)
+	suite.True(wait > 0)
+
+	// signing in resets the failures of the username
+	err = suite.lockout.Reset(suite.session, username)
+	suite.NoError(err)
+	wait, err = suite.lockout.Check(suite.session, username, ip)
+	suite.NoError(err)
+	suite.Equal(time.Duration(0), wait)
+}
+
+func (suite *LockoutTestSuite) TestLockAccount() {
+	username := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	defer UnlockUser(suite.session, username)
+
+	// the failures from different IPs are counted for the username
+	for i := 0; i < 4; i++ {
+		err := suite.lockout.RecordFailure(suite.session, username, bson.NewObjectId().Hex())
+		suite.NoError(err)
+	}
+
+	attempt := entity.LoginAttempt{}
+	err := suite.session.FindOne(entity.LoginAttemptCollectionName, bson.M{"_id": usernameAttemptID(username)}, &attempt)
+	suite.NoError(err)
+	suite.Equal(4, attempt.Failures)
+	suite.NotNil(attempt.LockedUntil)
+
+	wait, err := suite.lockout.Check(suite.session, username, bson.NewObjectId().Hex())
+	suite.NoError(err)
+	suite.InDelta(float64(2*time.Hour), float64(wait), float64(time.Second))
+
+	err = UnlockUser(suite.session, username)
+	suite.NoError(err)
+	wait, err = suite.lockout.Check(suite.session, username, bson.NewObjectId().Hex())
+	suite.NoError(err)
+	suite.Equal(time.Duration(0), wait)
+}
+
+func (suite *LockoutTestSuite) TestIPBackoff() {
+	ip := bson.NewObjectId().Hex()
+	defer suite.session.Remove(entity.LoginAttemptCollectionName, "_id", ipAttemptID(ip))
+
+	// the failures of different usernames are counted for the IP
+	usernames := []string{}
+	for i := 0; i < 3; i++ {
+		username := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+		usernames = append(usernames, username)
+		defer UnlockUser(suite.session, username)
+
+		err := suite.lockout.RecordFailure(suite.session, username, ip)
+		suite.NoError(err)
+	}
+
+	wait, err := suite.lockout.Check(suite.session, namesgenerator.GetRandomName(0)+"@linkernetworks.com", ip)
+	suite.NoError(err)
+	suite.True(wait > 0)
+
+	// resetting the username does not reset the IP
+	err = suite.lockout.Reset(suite.session, usernames[0])
+	suite.NoError(err)
+	wait, err = suite.lockout.Check(suite.session, usernames[0], ip)
+	suite.NoError(err)
+	suite.True(wait > 0)
+}
@@ -0,0 +1,490 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the lifetime of the state of an OpenID Connect login
+const oidcStateExpiration = 10 * time.Minute
+
+// OIDCProvider is the client of an OpenID Connect provider for the authorization code flow
+type OIDCProvider struct {
+	Config *config.OIDCConfig
+	Client *http.Client
+
+	mutex     sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var oidcProvider *OIDCProvider
+
+// SetupOIDCProvider will set up the OpenID Connect provider from the config
+// The OpenID Connect login is disabled when the config is empty
+func SetupOIDCProvider(cf *config.OIDCConfig) error {
+	if cf == nil {
+		oidcProvider = nil
+		return nil
+	}
+	provider, err := NewOIDCProvider(cf)
+	if err != nil {
+		return err
+	}
+	oidcProvider = provider
+	return nil
+}
+
+// GetOIDCProvider will return the OpenID Connect provider, it's nil when the login is disabled
+func GetOIDCProvider() *OIDCProvider {
+	return oidcProvider
+}
+
+// NewOIDCProvider will check the config and create the provider
+// The provider is discovered on the first login, so the server can start without the provider
+func NewOIDCProvider(cf *config.OIDCConfig) (*OIDCProvider, error) {
+	if cf.Issuer == "" || cf.ClientID == "" || cf.RedirectURL == "" {
+		return nil, fmt.Errorf("The issuer, client ID and redirect URL of the OIDC provider are required")
+	}
+
+	oidcConfig := *cf
+	if len(oidcConfig.Scopes) == 0 {
+		oidcConfig.Scopes = []string{"openid", "email", "profile"}
+	}
+	if oidcConfig.DefaultRole == "" {
+		oidcConfig.DefaultRole = entity.UserRole
+	}
+	if !IsRoleWithin(oidcConfig.DefaultRole, entity.RootRole) {
+		return nil, fmt.Errorf("Invalid OIDC default role: %s", oidcConfig.DefaultRole)
+	}
+	return &OIDCProvider{
+		Config: &oidcConfig,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *OIDCProvider) getJSON(url string, v interface{}) error {
+	resp, err := p.Client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Get %s fail: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discovery := &oidcDiscovery{}
+	if err := p.getJSON(strings.TrimSuffix(p.Config.Issuer, "/")+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("The issuer %s of the OIDC provider is not %s", discovery.Issuer, p.Config.Issuer)
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// AuthCodeURL will return the URL of the provider to start the login
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.Config.ClientID)
+	query.Set("redirect_uri", p.Config.RedirectURL)
+	query.Set("scope", strings.Join(p.Config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange will exchange the authorization code for the raw ID token
+func (p *OIDCProvider) Exchange(code string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	token := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("Decode the token response fail: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Exchange the authorization code fail: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", fmt.Errorf("The token response has no ID token")
+	}
+	return token.IDToken, nil
+}
+
+// VerifyIDToken will verify the signature, issuer, audience, expiration and nonce of the ID token
+func (p *OIDCProvider) VerifyIDToken(rawIDToken, nonce string) (entity.OIDCClaims, error) {
+	token, err := jwt.Parse(rawIDToken, p.keyfunc)
+	if err != nil {
+		return entity.OIDCClaims{}, err
+	}
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return entity.OIDCClaims{}, fmt.Errorf("The ID token is invalid")
+	}
+	if _, ok := mapClaims["exp"]; !ok {
+		return entity.OIDCClaims{}, fmt.Errorf("The ID token has no expiration")
+	}
+
+	// the audience is a string or an array of strings
+	audienceMatched := false
+	switch audience := mapClaims["aud"].(type) {
+	case string:
+		audienceMatched = audience == p.Config.ClientID
+	case []interface{}:
+		for _, aud := range audience {
+			if aud == p.Config.ClientID {
+				audienceMatched = true
+			}
+		}
+	}
+	if !audienceMatched {
+		return entity.OIDCClaims{}, fmt.Errorf("The audience of the ID token is not %s", p.Config.ClientID)
+	}
+
+	claims := entity.OIDCClaims{}
+	data, err := json.Marshal(mapClaims)
+	if err != nil {
+		return entity.OIDCClaims{}, err
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return entity.OIDCClaims{}, err
+	}
+
+	if claims.Issuer != p.Config.Issuer {
+		return entity.OIDCClaims{}, fmt.Errorf("The issuer %s of the ID token is not %s", claims.Issuer, p.Config.Issuer)
+	}
+	if claims.Nonce != nonce {
+		return entity.OIDCClaims{}, fmt.Errorf("The nonce of the ID token is mismatched")
+	}
+	if claims.Subject == "" {
+		return entity.OIDCClaims{}, fmt.Errorf("The ID token has no subject")
+	}
+	return claims, nil
+}
+
+// keyfunc will find the key by the kid header, the keys are fetched again for an unknown kid
+func (p *OIDCProvider) keyfunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("Unexpected signing method: %s", token.Method.Alg())
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	p.mutex.Lock()
+	key, ok := p.keys[kid]
+	p.mutex.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := p.fetchKeys(); err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unknown key ID of the ID token: %s", kid)
+}
+
+func (p *OIDCProvider) fetchKeys() error {
+	discovery, err := p.discover()
+	if err != nil {
+		return err
+	}
+
+	jwks := struct {
+		Keys []oidcJWK `json:"keys"`
+	}{}
+	if err := p.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			// skip the keys which are not used to sign the ID token
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.mutex.Lock()
+	p.keys = keys
+	p.mutex.Unlock()
+	return nil
+}
+
+func (k oidcJWK) publicKey() (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("Unsupported curve: %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("Unsupported key type: %s", k.Kty)
+}
+
+// CreateOIDCState will create the state and nonce of a login
+// The userID is the signed-in user linking the account, it's empty for a login
+func CreateOIDCState(session *mongo.Session, userID bson.ObjectId) (entity.OIDCState, error) {
+	state, err := utils.RandomToken(32)
+	if err != nil {
+		return entity.OIDCState{}, err
+	}
+	nonce, err := utils.RandomToken(32)
+	if err != nil {
+		return entity.OIDCState{}, err
+	}
+
+	session.C(entity.OIDCStateCollectionName).EnsureIndex(mgo.Index{
+		Key:         []string{"expiresAt"},
+		ExpireAfter: time.Second,
+	})
+
+	expiresAt := time.Now().Add(oidcStateExpiration)
+	s := entity.OIDCState{
+		State:     state,
+		Nonce:     nonce,
+		ExpiresAt: &expiresAt,
+		UserID:    userID,
+	}
+	if err := session.Insert(entity.OIDCStateCollectionName, &s); err != nil {
+		return entity.OIDCState{}, err
+	}
+	return s, nil
+}
+
+// ConsumeOIDCState will find and remove the unexpired state, so a state can only be used once
+func ConsumeOIDCState(session *mongo.Session, state string) (entity.OIDCState, error) {
+	s := entity.OIDCState{}
+	if _, err := session.C(entity.OIDCStateCollectionName).Find(bson.M{
+		"_id":       state,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Apply(mgo.Change{Remove: true}, &s); err != nil {
+		return entity.OIDCState{}, err
+	}
+	return s, nil
+}
+
+// findOIDCUser will find the user linked to the OpenID Connect account
+func findOIDCUser(session *mongo.Session, claims entity.OIDCClaims) (entity.User, error) {
+	user := entity.User{}
+	if err := session.FindOne(
+		entity.UserCollectionName,
+		bson.M{"oidcIssuer": claims.Issuer, "oidcSubject": claims.Subject},
+		&user,
+	); err != nil {
+		return entity.User{}, err
+	}
+	return user, nil
+}
+
+// ProvisionOIDCUser will find the user linked to the OpenID Connect account, or create a new user with the default role
+// The existing user with the same email is never linked here, it has to sign in and link the account by LinkOIDCUser
+// When the signup is invite-only, the new user requires an invitation for the email and gets the role of the invitation
+func ProvisionOIDCUser(session *mongo.Session, claims entity.OIDCClaims, defaultRole string) (entity.User, error) {
+	user, err := findOIDCUser(session, claims)
+	if err == nil {
+		return user, nil
+	}
+	if err != mgo.ErrNotFound {
+		return entity.User{}, err
+	}
+
+	// the email is the username, it must be verified to create the user
+	if claims.Email == "" || claims.EmailVerified == nil || !*claims.EmailVerified {
+		return entity.User{}, fmt.Errorf("The email of the OIDC account is not verified")
+	}
+	username := strings.ToLower(claims.Email)
+
+	err = session.FindOne(
+		entity.UserCollectionName,
+		bson.M{"loginCredential.username": username},
+		&user,
+	)
+	switch err {
+	case nil:
+		return entity.User{}, fmt.Errorf("The user %s already exists, sign in and link the OIDC account first", username)
+	case mgo.ErrNotFound:
+		invitation, err := findSignupInvitation(session, username)
+		if err != nil {
+			return entity.User{}, err
+		}
+		role := defaultRole
+		if invitation.Role != "" {
+			role = invitation.Role
+		}
+
+		displayName := claims.Name
+		if displayName == "" {
+			displayName = username
+		}
+		// the password is not stored, the user can only sign in by the provider
+		user = entity.User{
+			ID: bson.NewObjectId(),
+			LoginCredential: entity.LoginCredential{
+				Username: username,
+			},
+			DisplayName: displayName,
+			Role:        role,
+			FirstName:   claims.GivenName,
+			LastName:    claims.FamilyName,
+			PhoneNumber: claims.PhoneNumber,
+			CreatedAt:   timeutils.Now(),
+			OIDCIssuer:  claims.Issuer,
+			OIDCSubject: claims.Subject,
+		}
+		if err := session.Insert(entity.UserCollectionName, &user); err != nil {
+			return entity.User{}, err
+		}
+		if err := redeemInvitation(session, invitation); err != nil {
+			return entity.User{}, err
+		}
+		return user, nil
+	default:
+		return entity.User{}, err
+	}
+}
+
+// LinkOIDCUser will link the OpenID Connect account to the signed-in user
+func LinkOIDCUser(session *mongo.Session, userID bson.ObjectId, claims entity.OIDCClaims) (entity.User, error) {
+	linked, err := findOIDCUser(session, claims)
+	switch err {
+	case nil:
+		if linked.ID != userID {
+			return entity.User{}, fmt.Errorf("The OIDC account is linked to another user")
+		}
+		return linked, nil
+	case mgo.ErrNotFound:
+	default:
+		return entity.User{}, err
+	}
+
+	user, err := FindUserByID(session, userID)
+	if err != nil {
+		return entity.User{}, err
+	}
+	if user.OIDCSubject != "" {
+		return entity.User{}, fmt.Errorf("The user %s is linked to another OIDC account", user.LoginCredential.Username)
+	}
+	if err := session.C(entity.UserCollectionName).UpdateId(user.ID, bson.M{
+		"$set": bson.M{"oidcIssuer": claims.Issuer, "oidcSubject": claims.Subject},
+	}); err != nil {
+		return entity.User{}, err
+	}
+	user.OIDCIssuer = claims.Issuer
+	user.OIDCSubject = claims.Subject
+	return user, nil
+}
@@ -0,0 +1,249 @@
+package backend
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/server/backend/oidctest"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type OIDCTestSuite struct {
+	suite.Suite
+	session  *mongo.Session
+	server   *oidctest.Server
+	provider *OIDCProvider
+}
+
+func (suite *OIDCTestSuite) SetupSuite() {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	suite.session = sp.Mongo.NewSession()
+
+	server, err := oidctest.NewServer("vortex", "secret")
+	suite.Require().NoError(err)
+	suite.server = server
+
+	provider, err := NewOIDCProvider(&config.OIDCConfig{
+		Issuer:       server.URL,
+		ClientID:     "vortex",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:7890/v1/users/oidc/callback",
+	})
+	suite.Require().NoError(err)
+	suite.provider = provider
+}
+
+func (suite *OIDCTestSuite) TearDownSuite() {
+	suite.server.Close()
+	suite.session.Close()
+}
+
+func TestOIDCSuite(t *testing.T) {
+	suite.Run(t, new(OIDCTestSuite))
+}
+
+func (suite *OIDCTestSuite) TestAuthCodeURL() {
+	authURL, err := suite.provider.AuthCodeURL("state", "nonce")
+	suite.NoError(err)
+
+	u, err := url.Parse(authURL)
+	suite.NoError(err)
+	suite.Equal(suite.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	suite.Equal("code", u.Query().Get("response_type"))
+	suite.Equal("vortex", u.Query().Get("client_id"))
+	suite.Equal("http://localhost:7890/v1/users/oidc/callback", u.Query().Get("redirect_uri"))
+	suite.Equal("openid email profile", u.Query().Get("scope"))
+	suite.Equal("state", u.Query().Get("state"))
+	suite.Equal("nonce", u.Query().Get("nonce"))
+}
+
+func (suite *OIDCTestSuite) TestVerifyIDToken() {
+	code := suite.server.IssueCode(suite.server.Claims("subject", "oidc@example.com", "nonce"))
+	rawIDToken, err := suite.provider.Exchange(code)
+	suite.NoError(err)
+
+	claims, err := suite.provider.VerifyIDToken(rawIDToken, "nonce")
+	suite.NoError(err)
+	suite.Equal("subject", claims.Subject)
+	suite.Equal("oidc@example.com", claims.Email)
+	suite.True(*claims.EmailVerified)
+
+	// the code can only be exchanged once
+	_, err = suite.provider.Exchange(code)
+	suite.Error(err)
+}
+
+func (suite *OIDCTestSuite) TestInvalidIDToken() {
+	testCases := []struct {
+		cases  string
+		modify func(jwt.MapClaims)
+		nonce  string
+	}{
+		{"wrongNonce", func(claims jwt.MapClaims) {}, "other"},
+		{"wrongAudience", func(claims jwt.MapClaims) { claims["aud"] = "other" }, "nonce"},
+		{"wrongIssuer", func(claims jwt.MapClaims) { claims["iss"] = "https://other.example.com" }, "nonce"},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }, "nonce"},
+		{"noExpiration", func(claims jwt.MapClaims) { delete(claims, "exp") }, "nonce"},
+		{"noSubject", func(claims jwt.MapClaims) { delete(claims, "sub") }, "nonce"},
+	}
+
+	for _, tc := range testCases {
+		claims := suite.server.Claims("subject", "oidc@example.com", "nonce")
+		tc.modify(claims)
+		rawIDToken, err := suite.provider.Exchange(suite.server.IssueCode(claims))
+		suite.NoError(err, tc.cases)
+
+		_, err = suite.provider.VerifyIDToken(rawIDToken, tc.nonce)
+		suite.Error(err, tc.cases)
+	}
+
+	// the audience can be an array
+	claims := suite.server.Claims("subject", "oidc@example.com", "nonce")
+	claims["aud"] = []string{"other", "vortex"}
+	rawIDToken, err := suite.provider.Exchange(suite.server.IssueCode(claims))
+	suite.NoError(err)
+	_, err = suite.provider.VerifyIDToken(rawIDToken, "nonce")
+	suite.NoError(err)
+
+	// the token signed by an unknown key is rejected
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, suite.server.Claims("subject", "oidc@example.com", "nonce"))
+	rawIDToken, err = token.SignedString([]byte("secret"))
+	suite.NoError(err)
+	_, err = suite.provider.VerifyIDToken(rawIDToken, "nonce")
+	suite.Error(err)
+}
+
+func (suite *OIDCTestSuite) TestConsumeOIDCState() {
+	state, err := CreateOIDCState(suite.session, "")
+	suite.NoError(err)
+	suite.NotEmpty(state.Nonce)
+
+	consumed, err := ConsumeOIDCState(suite.session, state.State)
+	suite.NoError(err)
+	suite.Equal(state.Nonce, consumed.Nonce)
+
+	// the state can only be used once
+	_, err = ConsumeOIDCState(suite.session, state.State)
+	suite.Error(err)
+}
+
+func (suite *OIDCTestSuite) TestProvisionOIDCUser() {
+	verified := true
+	claims := entity.OIDCClaims{
+		Issuer:        suite.server.URL,
+		Subject:       bson.NewObjectId().Hex(),
+		Email:         "Provision@example.com",
+		EmailVerified: &verified,
+		Name:          "John Doe",
+	}
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", "provision@example.com")
+
+	// the user is created on the first login
+	user, err := ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.NoError(err)
+	suite.Equal("provision@example.com", user.LoginCredential.Username)
+	suite.Equal(entity.GuestRole, user.Role)
+	suite.Equal(claims.Subject, user.OIDCSubject)
+
+	// the linked user is found by the subject even the email is changed
+	claims.Email = "changed@example.com"
+	linked, err := ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.NoError(err)
+	suite.Equal(user.ID, linked.ID)
+
+	// another account with the same email can not take over the user
+	claims.Subject = bson.NewObjectId().Hex()
+	claims.Email = "provision@example.com"
+	_, err = ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.Error(err)
+}
+
+func (suite *OIDCTestSuite) TestProvisionInvitedOIDCUser() {
+	suite.Require().NoError(SetupSignup(&config.SignupConfig{InviteOnly: true}))
+	defer SetupSignup(nil)
+
+	verified := true
+	claims := entity.OIDCClaims{
+		Issuer:        suite.server.URL,
+		Subject:       bson.NewObjectId().Hex(),
+		Email:         "invited@example.com",
+		EmailVerified: &verified,
+	}
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", "invited@example.com")
+
+	// the new user is not created without an invitation
+	_, err := ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.Error(err)
+
+	invitation, err := CreateInvitation(suite.session, entity.Invitation{
+		Email: "invited@example.com",
+		Role:  entity.UserRole,
+	})
+	suite.Require().NoError(err)
+	defer suite.session.Remove(entity.InvitationCollectionName, "_id", invitation.ID)
+
+	// the user gets the role of the invitation, and the invitation is redeemed
+	user, err := ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.NoError(err)
+	suite.Equal(entity.UserRole, user.Role)
+
+	count, err := suite.session.Count(entity.InvitationCollectionName, bson.M{"_id": invitation.ID})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
+
+func (suite *OIDCTestSuite) TestLinkOIDCUser() {
+	user := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: "link@example.com",
+		},
+		Role: entity.RootRole,
+	}
+	err := suite.session.Insert(entity.UserCollectionName, &user)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	verified := true
+	claims := entity.OIDCClaims{
+		Issuer:        suite.server.URL,
+		Subject:       bson.NewObjectId().Hex(),
+		Email:         "link@example.com",
+		EmailVerified: &verified,
+	}
+
+	// the existing user is not linked by the verified email
+	_, err = ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.Error(err)
+
+	found, err := FindUserByID(suite.session, user.ID)
+	suite.NoError(err)
+	suite.Empty(found.OIDCSubject)
+
+	// the signed-in user links the account
+	linked, err := LinkOIDCUser(suite.session, user.ID, claims)
+	suite.NoError(err)
+	suite.Equal(user.ID, linked.ID)
+	suite.Equal(entity.RootRole, linked.Role)
+
+	linked, err = ProvisionOIDCUser(suite.session, claims, entity.GuestRole)
+	suite.NoError(err)
+	suite.Equal(user.ID, linked.ID)
+
+	// the linked account can not be linked to another user
+	_, err = LinkOIDCUser(suite.session, bson.NewObjectId(), claims)
+	suite.Error(err)
+
+	// the user can not link another account
+	claims.Subject = bson.NewObjectId().Hex()
+	_, err = LinkOIDCUser(suite.session, user.ID, claims)
+	suite.Error(err)
+}
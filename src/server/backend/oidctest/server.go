@@ -0,0 +1,137 @@
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hwchiu/vortex/src/utils"
+)
+
+// Server is a mock OpenID Connect provider built on httptest for testing
+// It signs the ID tokens by RS256 and exchanges the codes given by IssueCode
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key   *rsa.PrivateKey
+	mutex sync.Mutex
+	codes map[string]jwt.MapClaims
+}
+
+// NewServer will start the mock provider for the client
+func NewServer(clientID, clientSecret string) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        map[string]jwt.MapClaims{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discoveryHandler)
+	mux.HandleFunc("/keys", s.keysHandler)
+	mux.HandleFunc("/token", s.tokenHandler)
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+// Claims will return the valid claims of the ID token for the account
+func (s *Server) Claims(subject, email, nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            subject,
+		"aud":            s.ClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          email,
+		"email_verified": true,
+		"name":           "John Doe",
+		"given_name":     "John",
+		"family_name":    "Doe",
+	}
+}
+
+// IssueCode will return an authorization code, it's exchanged for the ID token with the claims once
+func (s *Server) IssueCode(claims jwt.MapClaims) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	code, _ := utils.RandomToken(16)
+	s.codes[code] = claims
+	return code
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/keys",
+	})
+}
+
+func (s *Server) keysHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "fake",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(s.key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.PublicKey.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func (s *Server) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if r.Method != "POST" || !ok || clientID != s.ClientID || clientSecret != s.ClientSecret {
+		s.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	s.mutex.Lock()
+	claims, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mutex.Unlock()
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" {
+		s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "fake"
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		s.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "fake",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
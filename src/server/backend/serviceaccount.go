@@ -0,0 +1,212 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	kubeCtl "github.com/hwchiu/vortex/src/kubernetes"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The const for the ServiceAccount tokens
+const (
+	// the prefix of the usernames of the ServiceAccounts in the kubernetes
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+	// the authenticated tokens are cached, so a revoked token is still accepted in this period
+	serviceAccountReviewTTL = time.Minute
+)
+
+// ServiceAccountAuthenticator authenticates the Kubernetes ServiceAccount tokens by the TokenReview API
+// Only the ServiceAccounts with the configured roles are accepted
+type ServiceAccountAuthenticator struct {
+	KubeCtl *kubeCtl.KubeCtl
+	// the roles of the ServiceAccounts, the key is "<namespace>/<name>"
+	Roles map[string]string
+
+	// the usernames of the authenticated tokens, the key is the SHA256 hash of the token
+	reviews      map[string]serviceAccountReview
+	reviewsMutex sync.Mutex
+}
+
+type serviceAccountReview struct {
+	username  string
+	expiresAt time.Time
+}
+
+var serviceAccountAuthenticator *ServiceAccountAuthenticator
+
+// SetupServiceAccountAuthenticator will enable the ServiceAccount tokens by the config
+// The ServiceAccount tokens are rejected when the config is empty
+func SetupServiceAccountAuthenticator(kc *kubeCtl.KubeCtl, cf *config.ServiceAccountConfig) error {
+	if cf == nil {
+		serviceAccountAuthenticator = nil
+		return nil
+	}
+	a, err := NewServiceAccountAuthenticator(kc, cf)
+	if err != nil {
+		return err
+	}
+	serviceAccountAuthenticator = a
+	return nil
+}
+
+// GetServiceAccountAuthenticator will return the current ServiceAccount authenticator, it's nil when disabled
+func GetServiceAccountAuthenticator() *ServiceAccountAuthenticator {
+	return serviceAccountAuthenticator
+}
+
+// NewServiceAccountAuthenticator will create the ServiceAccount authenticator from the config
+func NewServiceAccountAuthenticator(kc *kubeCtl.KubeCtl, cf *config.ServiceAccountConfig) (*ServiceAccountAuthenticator, error) {
+	if kc == nil {
+		return nil, fmt.Errorf("The kubernetes client is required to review the ServiceAccount tokens")
+	}
+	for serviceAccount, role := range cf.Roles {
+		parts := strings.Split(serviceAccount, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid ServiceAccount %s, it should be <namespace>/<name>", serviceAccount)
+		}
+		if _, ok := roleLevels[role]; !ok {
+			return nil, fmt.Errorf("Invalid role %s of the ServiceAccount %s", role, serviceAccount)
+		}
+	}
+	return &ServiceAccountAuthenticator{
+		KubeCtl: kc,
+		Roles:   cf.Roles,
+		reviews: map[string]serviceAccountReview{},
+	}, nil
+}
+
+// IsServiceAccountToken will check whether the bearer token looks like a ServiceAccount token
+// The signature is not verified here, only these tokens are sent to the TokenReview API
+func IsServiceAccountToken(tokenString string) bool {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return false
+	}
+	claims := struct {
+		Subject string `json:"sub"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return false
+	}
+	return strings.HasPrefix(claims.Subject, serviceAccountUsernamePrefix)
+}
+
+// reviewToken will return the username of the authenticated token, it's empty when the token is invalid
+// Only the authenticated tokens are cached, the invalid ones are reviewed again
+func (a *ServiceAccountAuthenticator) reviewToken(token string) (string, error) {
+	key := utils.SHA256String(token)
+	now := time.Now()
+
+	a.reviewsMutex.Lock()
+	review, ok := a.reviews[key]
+	a.reviewsMutex.Unlock()
+	if ok && now.Before(review.expiresAt) {
+		return review.username, nil
+	}
+
+	status, err := a.KubeCtl.ReviewToken(token)
+	if err != nil {
+		return "", err
+	}
+	if !status.Authenticated {
+		return "", nil
+	}
+
+	a.reviewsMutex.Lock()
+	defer a.reviewsMutex.Unlock()
+	for k, r := range a.reviews {
+		if !now.Before(r.expiresAt) {
+			delete(a.reviews, k)
+		}
+	}
+	a.reviews[key] = serviceAccountReview{
+		username:  status.User.Username,
+		expiresAt: now.Add(serviceAccountReviewTTL),
+	}
+	return status.User.Username, nil
+}
+
+// Authenticate will review the token and return the user of the ServiceAccount
+// It returns mgo.ErrNotFound when the token is invalid, the ServiceAccount has no configured role, or its new user is not allowed by the invite-only signup
+func (a *ServiceAccountAuthenticator) Authenticate(session *mongo.Session, token string) (entity.User, error) {
+	username, err := a.reviewToken(token)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	// the username is "system:serviceaccount:<namespace>:<name>"
+	if !strings.HasPrefix(username, serviceAccountUsernamePrefix) {
+		return entity.User{}, mgo.ErrNotFound
+	}
+	parts := strings.Split(strings.TrimPrefix(username, serviceAccountUsernamePrefix), ":")
+	if len(parts) != 2 {
+		return entity.User{}, mgo.ErrNotFound
+	}
+	role, ok := a.Roles[parts[0]+"/"+parts[1]]
+	if !ok {
+		return entity.User{}, mgo.ErrNotFound
+	}
+	user, err := syncServiceAccountUser(session, username, parts[1], role)
+	if err == errInvitationRequired {
+		return entity.User{}, mgo.ErrNotFound
+	}
+	return user, err
+}
+
+// syncServiceAccountUser creates the user of the ServiceAccount on the first call, and updates its role by the config
+// The password is not stored, so the user can not sign in
+// The ServiceAccount has no email to be invited, so the new user is not created when the signup is invite-only
+func syncServiceAccountUser(session *mongo.Session, username, name, role string) (entity.User, error) {
+	user := entity.User{}
+	err := session.FindOne(
+		entity.UserCollectionName,
+		bson.M{"loginCredential.username": username},
+		&user,
+	)
+	switch err {
+	case nil:
+		if user.Role != role {
+			if err := session.C(entity.UserCollectionName).UpdateId(user.ID, bson.M{
+				"$set": bson.M{"role": role},
+			}); err != nil {
+				return entity.User{}, err
+			}
+			user.Role = role
+		}
+		return user, nil
+	case mgo.ErrNotFound:
+		if GetSignup().InviteOnly {
+			return entity.User{}, errInvitationRequired
+		}
+		user = entity.User{
+			ID: bson.NewObjectId(),
+			LoginCredential: entity.LoginCredential{
+				Username: username,
+			},
+			DisplayName: name,
+			Role:        role,
+			CreatedAt:   timeutils.Now(),
+		}
+		if err := session.Insert(entity.UserCollectionName, &user); err != nil {
+			return entity.User{}, err
+		}
+		return user, nil
+	default:
+		return entity.User{}, err
+	}
+}
@@ -0,0 +1,262 @@
+package backend
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	kubeCtl "github.com/hwchiu/vortex/src/kubernetes"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// the usernames of the tokens which are authenticated by the fake TokenReview API
+var serviceAccountTokens = map[string]string{
+	"operator-token": "system:serviceaccount:default:operator",
+	"viewer-token":   "system:serviceaccount:monitoring:viewer",
+	"unknown-token":  "system:serviceaccount:default:unknown",
+	"user-token":     "alice",
+}
+
+type ServiceAccountTestSuite struct {
+	suite.Suite
+	session *mongo.Session
+	kubectl *kubeCtl.KubeCtl
+	config  config.ServiceAccountConfig
+	// the number of the TokenReview calls
+	reviews int
+}
+
+func (suite *ServiceAccountTestSuite) SetupSuite() {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	suite.session = sp.Mongo.NewSession()
+
+	fakeclient := fakeclientset.NewSimpleClientset()
+	fakeclient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		suite.reviews++
+		if review.Spec.Token == "error-token" {
+			return true, nil, fmt.Errorf("the TokenReview API is unavailable")
+		}
+		if username, ok := serviceAccountTokens[review.Spec.Token]; ok {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: username,
+				},
+			}
+		}
+		return true, review, nil
+	})
+	suite.kubectl = kubeCtl.New(fakeclient)
+
+	suite.config = config.ServiceAccountConfig{
+		Roles: map[string]string{
+			"default/operator":  entity.RootRole,
+			"monitoring/viewer": entity.GuestRole,
+		},
+	}
+}
+
+func (suite *ServiceAccountTestSuite) TearDownTest() {
+	for _, username := range serviceAccountTokens {
+		suite.session.Remove(entity.UserCollectionName, "loginCredential.username", username)
+	}
+}
+
+func (suite *ServiceAccountTestSuite) TearDownSuite() {
+	suite.session.Close()
+}
+
+func TestServiceAccountSuite(t *testing.T) {
+	suite.Run(t, new(ServiceAccountTestSuite))
+}
+
+func (suite *ServiceAccountTestSuite) TestAuthenticate() {
+	authenticator, err := NewServiceAccountAuthenticator(suite.kubectl, &suite.config)
+	suite.Require().NoError(err)
+
+	// the local user is created on the first call
+	user, err := authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+	suite.Equal("system:serviceaccount:default:operator", user.LoginCredential.Username)
+	suite.Equal("operator", user.DisplayName)
+	suite.Equal(entity.RootRole, user.Role)
+	suite.Empty(user.LoginCredential.Password)
+
+	localUser, err := FindUserByID(suite.session, user.ID)
+	suite.NoError(err)
+	suite.Equal(user.Role, localUser.Role)
+
+	// the same local user is used on the next call
+	again, err := authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+	suite.Equal(user.ID, again.ID)
+
+	user, err = authenticator.Authenticate(suite.session, "viewer-token")
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, user.Role)
+}
+
+func (suite *ServiceAccountTestSuite) TestSyncRole() {
+	authenticator, err := NewServiceAccountAuthenticator(suite.kubectl, &suite.config)
+	suite.Require().NoError(err)
+
+	user, err := authenticator.Authenticate(suite.session, "viewer-token")
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, user.Role)
+
+	// the role follows the config
+	authenticator.Roles = map[string]string{"monitoring/viewer": entity.UserRole}
+	again, err := authenticator.Authenticate(suite.session, "viewer-token")
+	suite.NoError(err)
+	suite.Equal(user.ID, again.ID)
+	suite.Equal(entity.UserRole, again.Role)
+
+	localUser, err := FindUserByID(suite.session, user.ID)
+	suite.NoError(err)
+	suite.Equal(entity.UserRole, localUser.Role)
+}
+
+func (suite *ServiceAccountTestSuite) TestReviewCache() {
+	authenticator, err := NewServiceAccountAuthenticator(suite.kubectl, &suite.config)
+	suite.Require().NoError(err)
+
+	// the authenticated token is only reviewed once in the TTL
+	reviews := suite.reviews
+	_, err = authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+	_, err = authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+	suite.Equal(reviews+1, suite.reviews)
+
+	// the invalid token is not cached
+	reviews = suite.reviews
+	_, err = authenticator.Authenticate(suite.session, "invalid-token")
+	suite.Equal(mgo.ErrNotFound, err)
+	_, err = authenticator.Authenticate(suite.session, "invalid-token")
+	suite.Equal(mgo.ErrNotFound, err)
+	suite.Equal(reviews+2, suite.reviews)
+
+	// the expired review is done again
+	for key, review := range authenticator.reviews {
+		review.expiresAt = time.Now().Add(-time.Second)
+		authenticator.reviews[key] = review
+	}
+	reviews = suite.reviews
+	_, err = authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+	suite.Equal(reviews+1, suite.reviews)
+}
+
+func (suite *ServiceAccountTestSuite) TestInviteOnly() {
+	authenticator, err := NewServiceAccountAuthenticator(suite.kubectl, &suite.config)
+	suite.Require().NoError(err)
+
+	user, err := authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+
+	suite.Require().NoError(SetupSignup(&config.SignupConfig{InviteOnly: true}))
+	defer SetupSignup(nil)
+
+	// the existing user is still used
+	again, err := authenticator.Authenticate(suite.session, "operator-token")
+	suite.NoError(err)
+	suite.Equal(user.ID, again.ID)
+
+	// the new user is not created
+	_, err = authenticator.Authenticate(suite.session, "viewer-token")
+	suite.Equal(mgo.ErrNotFound, err)
+
+	count, err := suite.session.Count(entity.UserCollectionName, bson.M{"loginCredential.username": "system:serviceaccount:monitoring:viewer"})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
+
+func (suite *ServiceAccountTestSuite) TestFailedAuthenticate() {
+	authenticator, err := NewServiceAccountAuthenticator(suite.kubectl, &suite.config)
+	suite.Require().NoError(err)
+
+	testCases := []struct {
+		cases string
+		token string
+	}{
+		{"unauthenticated", "invalid-token"},
+		{"unmapped ServiceAccount", "unknown-token"},
+		{"not a ServiceAccount", "user-token"},
+	}
+	for _, tc := range testCases {
+		_, err := authenticator.Authenticate(suite.session, tc.token)
+		suite.Equal(mgo.ErrNotFound, err, tc.cases)
+	}
+
+	count, err := suite.session.Count(entity.UserCollectionName, bson.M{"loginCredential.username": "system:serviceaccount:default:unknown"})
+	suite.NoError(err)
+	suite.Equal(0, count)
+
+	_, err = authenticator.Authenticate(suite.session, "error-token")
+	suite.Error(err)
+	suite.NotEqual(mgo.ErrNotFound, err)
+}
+
+func (suite *ServiceAccountTestSuite) TestSetupServiceAccountAuthenticator() {
+	defer SetupServiceAccountAuthenticator(nil, nil)
+
+	suite.NoError(SetupServiceAccountAuthenticator(nil, nil))
+	suite.Nil(GetServiceAccountAuthenticator())
+
+	suite.NoError(SetupServiceAccountAuthenticator(suite.kubectl, &suite.config))
+	suite.NotNil(GetServiceAccountAuthenticator())
+
+	testCases := []struct {
+		cases string
+		roles map[string]string
+	}{
+		{"no namespace", map[string]string{"operator": entity.RootRole}},
+		{"empty name", map[string]string{"default/": entity.RootRole}},
+		{"invalid role", map[string]string{"default/operator": "admin"}},
+	}
+	for _, tc := range testCases {
+		err := SetupServiceAccountAuthenticator(suite.kubectl, &config.ServiceAccountConfig{Roles: tc.roles})
+		suite.Error(err, tc.cases)
+	}
+
+	err := SetupServiceAccountAuthenticator(nil, &suite.config)
+	suite.Error(err)
+}
+
+func TestIsServiceAccountToken(t *testing.T) {
+	encode := func(payload string) string {
+		return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+	}
+
+	testCases := []struct {
+		cases  string
+		token  string
+		expect bool
+	}{
+		{"serviceAccount", encode(`{"iss":"kubernetes/serviceaccount","sub":"system:serviceaccount:default:operator"}`), true},
+		{"userJWT", encode(`{"sub":"5b1f0fb1b0b4b2b3b4b5b6b7","role":"user"}`), false},
+		{"invalidPayload", encode("invalid"), false},
+		{"notJWT", "operator-token", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			assert.Equal(t, tc.expect, IsServiceAccountToken(tc.token))
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The const for the refresh tokens
+const (
+	// the byte length of a refresh token
+	refreshTokenLength = 32
+	// the number of the rotated refresh tokens which are kept to detect the reuse
+	maxUsedRefreshTokens = 20
+)
+
+// CreateSession will create a session for the user and return the plain refresh token of it
+func CreateSession(session *mongo.Session, user entity.User) (entity.Session, string, error) {
+	refreshToken, err := utils.RandomToken(refreshTokenLength)
+	if err != nil {
+		return entity.Session{}, "", err
+	}
+
+	session.C(entity.SessionCollectionName).EnsureIndex(mgo.Index{
+		Key:         []string{"expiresAt"},
+		ExpireAfter: time.Second,
+	})
+
+	expiresAt := time.Now().Add(GetKeySet().RefreshExpiration)
+	s := entity.Session{
+		ID:           bson.NewObjectId(),
+		UserID:       user.ID,
+		RefreshToken: utils.SHA256String(refreshToken),
+		ExpiresAt:    &expiresAt,
+		CreatedAt:    timeutils.Now(),
+	}
+	if err := session.Insert(entity.SessionCollectionName, &s); err != nil {
+		return entity.Session{}, "", err
+	}
+	return s, refreshToken, nil
+}
+
+// RefreshSession will find the unexpired session by the refresh token and rotate its refresh token
+// The rotation is atomic, so only one of the concurrent refreshes with the same token succeeds
+// A rotated refresh token which is used again may be leaked, so the session is revoked and mgo.ErrNotFound is returned
+func RefreshSession(session *mongo.Session, refreshToken string) (entity.Session, string, error) {
+	hashedToken := utils.SHA256String(refreshToken)
+	s := entity.Session{}
+	if err := session.FindOne(
+		entity.SessionCollectionName,
+		bson.M{
+			"$or":       []bson.M{{"refreshToken": hashedToken}, {"usedRefreshTokens": hashedToken}},
+			"expiresAt": bson.M{"$gt": time.Now()},
+		},
+		&s,
+	); err != nil {
+		return entity.Session{}, "", err
+	}
+	if s.RefreshToken != hashedToken {
+		return entity.Session{}, "", revokeReusedSession(session, s)
+	}
+
+	newRefreshToken, err := utils.RandomToken(refreshTokenLength)
+	if err != nil {
+		return entity.Session{}, "", err
+	}
+	expiresAt := time.Now().Add(GetKeySet().RefreshExpiration)
+
+	refreshed := entity.Session{}
+	_, err = session.C(entity.SessionCollectionName).Find(bson.M{
+		"_id":          s.ID,
+		"refreshToken": hashedToken,
+	}).Apply(mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"refreshToken": utils.SHA256String(newRefreshToken),
+				"expiresAt":    &expiresAt,
+			},
+			"$push": bson.M{
+				"usedRefreshTokens": bson.M{"$each": []string{hashedToken}, "$slice": -maxUsedRefreshTokens},
+			},
+		},
+		ReturnNew: true,
+	}, &refreshed)
+	switch err {
+	case nil:
+		return refreshed, newRefreshToken, nil
+	case mgo.ErrNotFound:
+		// the token is rotated by another refresh at the same time
+		return entity.Session{}, "", revokeReusedSession(session, s)
+	default:
+		return entity.Session{}, "", err
+	}
+}
+
+// revokeReusedSession will revoke the session whose rotated refresh token is used again
+// It returns mgo.ErrNotFound after revoking, so the refresh token is rejected as an invalid one
+func revokeReusedSession(session *mongo.Session, s entity.Session) error {
+	if err := RevokeSession(session, s.ID.Hex(), s.UserID); err != nil {
+		return err
+	}
+	return mgo.ErrNotFound
+}
+
+// RevokeSession will remove the session and revoke the access tokens issued from it
+func RevokeSession(session *mongo.Session, sessionID string, userID bson.ObjectId) error {
+	if bson.IsObjectIdHex(sessionID) {
+		if err := session.C(entity.SessionCollectionName).RemoveId(bson.ObjectIdHex(sessionID)); err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+
+	session.C(entity.RevokedTokenCollectionName).EnsureIndex(mgo.Index{
+		Key:         []string{"expiresAt"},
+		ExpireAfter: time.Second,
+	})
+
+	// the access tokens are expired after the expiration, so the revoked token ID can be removed after that
+	expiresAt := time.Now().Add(GetKeySet().Expiration)
+	_, err := session.C(entity.RevokedTokenCollectionName).UpsertId(sessionID, entity.RevokedToken{
+		ID:        sessionID,
+		UserID:    userID,
+		ExpiresAt: &expiresAt,
+	})
+	return err
+}
+
+// RevokeUserSessions will revoke all sessions of the user
+func RevokeUserSessions(session *mongo.Session, userID bson.ObjectId) error {
+	sessions := []entity.Session{}
+	if err := session.C(entity.SessionCollectionName).Find(bson.M{"userID": userID}).All(&sessions); err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if err := RevokeSession(session, s.ID.Hex(), userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTokenRevoked will check whether the token ID (jti) is revoked
+func IsTokenRevoked(session *mongo.Session, tokenID string) (bool, error) {
+	count, err := session.Count(entity.RevokedTokenCollectionName, bson.M{"_id": tokenID})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
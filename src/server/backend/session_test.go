@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/stretchr/testify/suite"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type SessionTestSuite struct {
+	suite.Suite
+	session *mongo.Session
+	user    entity.User
+}
+
+func (suite *SessionTestSuite) SetupSuite() {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+
+	suite.session = sp.Mongo.NewSession()
+	suite.user = entity.User{
+		ID:   bson.NewObjectId(),
+		Role: "user",
+	}
+}
+
+func (suite *SessionTestSuite) TearDownSuite() {
+	suite.session.C(entity.SessionCollectionName).RemoveAll(bson.M{"userID": suite.user.ID})
+	suite.session.C(entity.RevokedTokenCollectionName).RemoveAll(bson.M{"userID": suite.user.ID})
+	suite.session.Close()
+}
+
+func TestSessionSuite(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
+
+func (suite *SessionTestSuite) TestRefreshSession() {
+	s, refreshToken, err := CreateSession(suite.session, suite.user)
+	suite.NoError(err)
+	suite.NotEmpty(refreshToken)
+	suite.NotEqual(refreshToken, s.RefreshToken)
+
+	refreshed, newRefreshToken, err := RefreshSession(suite.session, refreshToken)
+	suite.NoError(err)
+	suite.Equal(s.ID, refreshed.ID)
+	suite.NotEqual(refreshToken, newRefreshToken)
+
+	refreshed, newerRefreshToken, err := RefreshSession(suite.session, newRefreshToken)
+	suite.NoError(err)
+	suite.Equal(s.ID, refreshed.ID)
+
+	_, _, err = RefreshSession(suite.session, newerRefreshToken)
+	suite.NoError(err)
+}
+
+func (suite *SessionTestSuite) TestRefreshSessionReuse() {
+	s, refreshToken, err := CreateSession(suite.session, suite.user)
+	suite.NoError(err)
+
+	_, newRefreshToken, err := RefreshSession(suite.session, refreshToken)
+	suite.NoError(err)
+
+	// the rotated refresh token is used again, so the whole session is revoked
+	_, _, err = RefreshSession(suite.session, refreshToken)
+	suite.Equal(mgo.ErrNotFound, err)
+
+	revoked, err := IsTokenRevoked(suite.session, s.ID.Hex())
+	suite.NoError(err)
+	suite.True(revoked)
+
+	_, _, err = RefreshSession(suite.session, newRefreshToken)
+	suite.Equal(mgo.ErrNotFound, err)
+}
+
+func (suite *SessionTestSuite) TestConcurrentRefreshSession() {
+	s, refreshToken, err := CreateSession(suite.session, suite.user)
+	suite.NoError(err)
+
+	// only one of the concurrent refreshes with the same token succeeds
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _, err := RefreshSession(suite.session, refreshToken)
+			results <- err
+		}()
+	}
+	succeeded := 0
+	for i := 0; i < 2; i++ {
+		if err := <-results; err == nil {
+			succeeded++
+		} else {
+			suite.Equal(mgo.ErrNotFound, err)
+		}
+	}
+	suite.Equal(1, succeeded)
+
+	revoked, err := IsTokenRevoked(suite.session, s.ID.Hex())
+	suite.NoError(err)
+	suite.True(revoked)
+}
+
+func (suite *SessionTestSuite) TestRevokeSession() {
+	s, refreshToken, err := CreateSession(suite.session, suite.user)
+	suite.NoError(err)
+
+	revoked, err := IsTokenRevoked(suite.session, s.ID.Hex())
+	suite.NoError(err)
+	suite.False(revoked)
+
+	err = RevokeSession(suite.session, s.ID.Hex(), suite.user.ID)
+	suite.NoError(err)
+
+	revoked, err = IsTokenRevoked(suite.session, s.ID.Hex())
+	suite.NoError(err)
+	suite.True(revoked)
+
+	_, _, err = RefreshSession(suite.session, refreshToken)
+	suite.Error(err)
+}
+
+func (suite *SessionTestSuite) TestRevokeUserSessions() {
+	sessions := []entity.Session{}
+	for i := 0; i < 3; i++ {
+		s, _, err := CreateSession(suite.session, suite.user)
+		suite.NoError(err)
+		sessions = append(sessions, s)
+	}
+
+	err := RevokeUserSessions(suite.session, suite.user.ID)
+	suite.NoError(err)
+
+	for _, s := range sessions {
+		revoked, err := IsTokenRevoked(suite.session, s.ID.Hex())
+		suite.NoError(err)
+		suite.True(revoked)
+	}
+
+	count, err := suite.session.Count(entity.SessionCollectionName, bson.M{"userID": suite.user.ID})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The const for the signup
+const (
+	DefaultInviteExpiration = 72 * time.Hour
+	// the byte length of an invite token
+	inviteTokenLength = 32
+)
+
+// Signup is the settings of the registration of the users
+type Signup struct {
+	InviteOnly       bool
+	InviteExpiration time.Duration
+}
+
+var signup *Signup
+
+// SetupSignup will load the settings of the signup from the config
+// The open signup is enabled when the config is empty
+func SetupSignup(cf *config.SignupConfig) error {
+	if cf == nil {
+		signup = nil
+		return nil
+	}
+	s, err := NewSignup(cf)
+	if err != nil {
+		return err
+	}
+	signup = s
+	return nil
+}
+
+// GetSignup will return the current settings of the signup
+func GetSignup() *Signup {
+	if signup != nil {
+		return signup
+	}
+	return &Signup{
+		InviteExpiration: DefaultInviteExpiration,
+	}
+}
+
+// NewSignup will create the settings of the signup from the config
+func NewSignup(cf *config.SignupConfig) (*Signup, error) {
+	s := &Signup{
+		InviteOnly:       cf.InviteOnly,
+		InviteExpiration: DefaultInviteExpiration,
+	}
+	if cf.InviteExpiration != "" {
+		expiration, err := time.ParseDuration(cf.InviteExpiration)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid invite expiration %s: %v", cf.InviteExpiration, err)
+		}
+		if expiration <= 0 {
+			return nil, fmt.Errorf("The invite expiration should be positive: %s", cf.InviteExpiration)
+		}
+		s.InviteExpiration = expiration
+	}
+	return s, nil
+}
+
+// CreateInvitation will create the invitation and return it with the plain invite token
+func CreateInvitation(session *mongo.Session, invitation entity.Invitation) (entity.Invitation, error) {
+	token, err := utils.RandomToken(inviteTokenLength)
+	if err != nil {
+		return entity.Invitation{}, err
+	}
+
+	// the expired invitations are removed by mongo
+	session.C(entity.InvitationCollectionName).EnsureIndex(mgo.Index{
+		Key:         []string{"expiresAt"},
+		ExpireAfter: time.Second,
+	})
+
+	expiresAt := time.Now().Add(GetSignup().InviteExpiration)
+	invitation.ID = bson.NewObjectId()
+	invitation.Email = strings.ToLower(invitation.Email)
+	invitation.HashedToken = utils.SHA256String(token)
+	invitation.ExpiresAt = &expiresAt
+	invitation.CreatedAt = timeutils.Now()
+	if err := session.Insert(entity.InvitationCollectionName, &invitation); err != nil {
+		return entity.Invitation{}, err
+	}
+	invitation.Token = token
+	return invitation, nil
+}
+
+// FindInvitation will find the unexpired invitation by the plain invite token
+func FindInvitation(session *mongo.Session, token string) (entity.Invitation, error) {
+	invitation := entity.Invitation{}
+	if err := session.FindOne(
+		entity.InvitationCollectionName,
+		bson.M{
+			"hashedToken": utils.SHA256String(token),
+			"expiresAt":   bson.M{"$gt": time.Now()},
+		},
+		&invitation,
+	); err != nil {
+		return entity.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// errInvitationRequired is returned when a new user is provisioned without an invitation and the signup is invite-only
+var errInvitationRequired = fmt.Errorf("The signup requires an invitation")
+
+// findSignupInvitation will find the unexpired invitation for the email of a new user provisioned by LDAP, OIDC or ServiceAccount
+// It returns an empty invitation when the signup is open, and errInvitationRequired when the email is not invited
+func findSignupInvitation(session *mongo.Session, email string) (entity.Invitation, error) {
+	if !GetSignup().InviteOnly {
+		return entity.Invitation{}, nil
+	}
+	invitation := entity.Invitation{}
+	err := session.FindOne(
+		entity.InvitationCollectionName,
+		bson.M{
+			"email":     strings.ToLower(email),
+			"expiresAt": bson.M{"$gt": time.Now()},
+		},
+		&invitation,
+	)
+	switch err {
+	case nil:
+		return invitation, nil
+	case mgo.ErrNotFound:
+		return entity.Invitation{}, errInvitationRequired
+	default:
+		return entity.Invitation{}, err
+	}
+}
+
+// redeemInvitation will remove the invitation after the user is created, the invitation can only be redeemed once
+func redeemInvitation(session *mongo.Session, invitation entity.Invitation) error {
+	if invitation.ID == "" {
+		return nil
+	}
+	if err := session.Remove(entity.InvitationCollectionName, "_id", invitation.ID); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestNewSignup(t *testing.T) {
+	s, err := NewSignup(&config.SignupConfig{InviteOnly: true})
+	require.NoError(t, err)
+	assert.True(t, s.InviteOnly)
+	assert.Equal(t, DefaultInviteExpiration, s.InviteExpiration)
+
+	s, err = NewSignup(&config.SignupConfig{InviteExpiration: "24h"})
+	require.NoError(t, err)
+	assert.False(t, s.InviteOnly)
+	assert.Equal(t, 24*time.Hour, s.InviteExpiration)
+
+	_, err = NewSignup(&config.SignupConfig{InviteExpiration: "1d"})
+	assert.Error(t, err)
+	_, err = NewSignup(&config.SignupConfig{InviteExpiration: "-1h"})
+	assert.Error(t, err)
+}
+
+func TestInvitation(t *testing.T) {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	invitation, err := CreateInvitation(session, entity.Invitation{
+		Email: "Invitee@linkernetworks.com",
+		Role:  entity.GuestRole,
+	})
+	require.NoError(t, err)
+	defer session.Remove(entity.InvitationCollectionName, "_id", invitation.ID)
+	assert.NotEmpty(t, invitation.Token)
+	assert.Equal(t, "invitee@linkernetworks.com", invitation.Email)
+	assert.WithinDuration(t, time.Now().Add(DefaultInviteExpiration), *invitation.ExpiresAt, time.Minute)
+
+	found, err := FindInvitation(session, invitation.Token)
+	require.NoError(t, err)
+	assert.Equal(t, invitation.ID, found.ID)
+	assert.Equal(t, entity.GuestRole, found.Role)
+
+	_, err = FindInvitation(session, "invalid")
+	assert.Equal(t, mgo.ErrNotFound, err)
+
+	// the expired invitation can not be found before mongo removes it
+	err = session.C(entity.InvitationCollectionName).UpdateId(invitation.ID, bson.M{
+		"$set": bson.M{"expiresAt": time.Now().Add(-time.Minute)},
+	})
+	require.NoError(t, err)
+	_, err = FindInvitation(session, invitation.Token)
+	assert.Equal(t, mgo.ErrNotFound, err)
+}
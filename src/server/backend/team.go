@@ -0,0 +1,161 @@
+package backend
+
+import (
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/linkernetworks/mongo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// the level of the roles in a team, the higher role includes the permissions of the lower roles
+var teamRoleLevels = map[string]int{
+	entity.TeamViewerRole: 1,
+	entity.TeamMemberRole: 2,
+	entity.TeamAdminRole:  3,
+}
+
+// the namespaces not created by vortex which all users can use
+var sharedNamespaces = map[string]bool{}
+
+// SetupSharedNamespaces will load the namespaces which all users can use from the config
+// No namespace is shared when the config is empty
+func SetupSharedNamespaces(cf *config.TeamConfig) {
+	namespaces := map[string]bool{}
+	if cf != nil {
+		for _, name := range cf.SharedNamespaces {
+			namespaces[name] = true
+		}
+	}
+	sharedNamespaces = namespaces
+}
+
+// IsTeamRoleAtLeast will check whether the team role includes the permissions of the min role
+func IsTeamRoleAtLeast(role, minRole string) bool {
+	level, ok := teamRoleLevels[role]
+	return ok && level >= teamRoleLevels[minRole]
+}
+
+// TeamMemberRole will return the role of the user in the team, it's empty when the user is not a member
+func TeamMemberRole(team entity.Team, userID bson.ObjectId) string {
+	for _, member := range team.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// FindTeamByID will find the team by its ID
+func FindTeamByID(session *mongo.Session, ID bson.ObjectId) (entity.Team, error) {
+	var team entity.Team
+	if err := session.FindOne(
+		entity.TeamCollectionName,
+		bson.M{"_id": ID},
+		&team,
+	); err != nil {
+		return entity.Team{}, err
+	}
+	return team, nil
+}
+
+// FindUserTeamIDs will return the IDs of the teams which the user belongs to
+func FindUserTeamIDs(session *mongo.Session, userID bson.ObjectId) ([]bson.ObjectId, error) {
+	teams := []entity.Team{}
+	if err := session.C(entity.TeamCollectionName).Find(bson.M{"members.userID": userID}).Select(bson.M{"_id": 1}).All(&teams); err != nil {
+		return nil, err
+	}
+	teamIDs := []bson.ObjectId{}
+	for _, team := range teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+	return teamIDs, nil
+}
+
+// CanAccessNamespace will check whether the user can use the namespace with at least the team role
+// The owner of the namespace can always use it, the others need the role in the team which owns the namespace
+// The namespaces which are not created by vortex, e.g. default, are not owned by any user or team,
+// so only the shared namespaces in the config can be used by all users
+func CanAccessNamespace(session *mongo.Session, name string, userID bson.ObjectId, minRole string) (bool, error) {
+	namespace := entity.Namespace{}
+	if err := session.FindOne(entity.NamespaceCollectionName, bson.M{"name": name}, &namespace); err != nil {
+		if err == mgo.ErrNotFound {
+			return sharedNamespaces[name], nil
+		}
+		return false, err
+	}
+	return hasNamespaceRole(session, namespace, userID, minRole)
+}
+
+// HasNamespaceRole will check whether the user owns the namespace or has at least the role in the team which owns it
+// It's false for the namespaces which are not created by vortex, so their objects are only accessed by the owners of the objects
+func HasNamespaceRole(session *mongo.Session, name string, userID bson.ObjectId, minRole string) (bool, error) {
+	namespace := entity.Namespace{}
+	if err := session.FindOne(entity.NamespaceCollectionName, bson.M{"name": name}, &namespace); err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return hasNamespaceRole(session, namespace, userID, minRole)
+}
+
+func hasNamespaceRole(session *mongo.Session, namespace entity.Namespace, userID bson.ObjectId, minRole string) (bool, error) {
+	if namespace.OwnerID == userID {
+		return true, nil
+	}
+	if namespace.TeamID == "" {
+		return false, nil
+	}
+
+	team, err := FindTeamByID(session, namespace.TeamID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return IsTeamRoleAtLeast(TeamMemberRole(team, userID), minRole), nil
+}
+
+// FindRoleNamespaces will return the names of the namespaces which the user owns or has at least the role in the teams which own them
+func FindRoleNamespaces(session *mongo.Session, userID bson.ObjectId, minRole string) ([]string, error) {
+	roles := []string{}
+	for role := range teamRoleLevels {
+		if IsTeamRoleAtLeast(role, minRole) {
+			roles = append(roles, role)
+		}
+	}
+
+	teams := []entity.Team{}
+	if err := session.C(entity.TeamCollectionName).Find(bson.M{
+		"members": bson.M{"$elemMatch": bson.M{"userID": userID, "role": bson.M{"$in": roles}}},
+	}).Select(bson.M{"_id": 1}).All(&teams); err != nil {
+		return nil, err
+	}
+	teamIDs := []bson.ObjectId{}
+	for _, team := range teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	namespaces := []entity.Namespace{}
+	if err := session.C(entity.NamespaceCollectionName).Find(bson.M{
+		"$or": []bson.M{{"ownerID": userID}, {"teamID": bson.M{"$in": teamIDs}}},
+	}).Select(bson.M{"name": 1}).All(&namespaces); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, namespace := range namespaces {
+		names = append(names, namespace.Name)
+	}
+	return names, nil
+}
+
+// RemoveUserFromTeams will remove the user from all teams
+func RemoveUserFromTeams(session *mongo.Session, userID bson.ObjectId) error {
+	_, err := session.C(entity.TeamCollectionName).UpdateAll(
+		bson.M{"members.userID": userID},
+		bson.M{"$pull": bson.M{"members": bson.M{"userID": userID}}},
+	)
+	return err
+}
@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestIsTeamRoleAtLeast(t *testing.T) {
+	testCases := []struct {
+		role    string
+		minRole string
+		expect  bool
+	}{
+		{entity.TeamAdminRole, entity.TeamMemberRole, true},
+		{entity.TeamMemberRole, entity.TeamMemberRole, true},
+		{entity.TeamViewerRole, entity.TeamMemberRole, false},
+		{entity.TeamViewerRole, entity.TeamViewerRole, true},
+		{"", entity.TeamViewerRole, false},
+		{"owner", entity.TeamViewerRole, false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expect, IsTeamRoleAtLeast(tc.role, tc.minRole), "%s >= %s", tc.role, tc.minRole)
+	}
+}
+
+func TestCanAccessNamespace(t *testing.T) {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	ownerID := bson.NewObjectId()
+	memberID := bson.NewObjectId()
+	viewerID := bson.NewObjectId()
+	team := entity.Team{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Members: []entity.TeamMember{
+			{UserID: memberID, Role: entity.TeamMemberRole},
+			{UserID: viewerID, Role: entity.TeamViewerRole},
+		},
+	}
+	require.NoError(t, session.Insert(entity.TeamCollectionName, &team))
+	defer session.Remove(entity.TeamCollectionName, "_id", team.ID)
+
+	teamNamespace := entity.Namespace{
+		ID:      bson.NewObjectId(),
+		Name:    namesgenerator.GetRandomName(0),
+		OwnerID: ownerID,
+		TeamID:  team.ID,
+	}
+	require.NoError(t, session.Insert(entity.NamespaceCollectionName, &teamNamespace))
+	defer session.Remove(entity.NamespaceCollectionName, "_id", teamNamespace.ID)
+
+	privateNamespace := entity.Namespace{
+		ID:      bson.NewObjectId(),
+		Name:    namesgenerator.GetRandomName(0),
+		OwnerID: ownerID,
+	}
+	require.NoError(t, session.Insert(entity.NamespaceCollectionName, &privateNamespace))
+	defer session.Remove(entity.NamespaceCollectionName, "_id", privateNamespace.ID)
+
+	testCases := []struct {
+		cases     string
+		namespace string
+		userID    bson.ObjectId
+		minRole   string
+		expect    bool
+	}{
+		{"owner", teamNamespace.Name, ownerID, entity.TeamAdminRole, true},
+		{"member", teamNamespace.Name, memberID, entity.TeamMemberRole, true},
+		{"memberNotAdmin", teamNamespace.Name, memberID, entity.TeamAdminRole, false},
+		{"viewer", teamNamespace.Name, viewerID, entity.TeamViewerRole, true},
+		{"viewerNotMember", teamNamespace.Name, viewerID, entity.TeamMemberRole, false},
+		{"stranger", teamNamespace.Name, bson.NewObjectId(), entity.TeamViewerRole, false},
+		{"privateOwner", privateNamespace.Name, ownerID, entity.TeamMemberRole, true},
+		{"privateMember", privateNamespace.Name, memberID, entity.TeamViewerRole, false},
+		{"unmanagedNamespace", "kube-system", bson.NewObjectId(), entity.TeamMemberRole, false},
+		{"sharedNamespace", "default", bson.NewObjectId(), entity.TeamMemberRole, true},
+	}
+
+	SetupSharedNamespaces(&config.TeamConfig{SharedNamespaces: []string{"default"}})
+	defer SetupSharedNamespaces(nil)
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			allowed, err := CanAccessNamespace(session, tc.namespace, tc.userID, tc.minRole)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, allowed)
+		})
+	}
+
+	// the user is removed from the team
+	require.NoError(t, RemoveUserFromTeams(session, memberID))
+	allowed, err := CanAccessNamespace(session, teamNamespace.Name, memberID, entity.TeamViewerRole)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestFindRoleNamespaces(t *testing.T) {
+	cf := config.MustRead("../../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	ownerID := bson.NewObjectId()
+	viewerID := bson.NewObjectId()
+	team := entity.Team{
+		ID:      bson.NewObjectId(),
+		Name:    namesgenerator.GetRandomName(0),
+		Members: []entity.TeamMember{{UserID: viewerID, Role: entity.TeamViewerRole}},
+	}
+	require.NoError(t, session.Insert(entity.TeamCollectionName, &team))
+	defer session.Remove(entity.TeamCollectionName, "_id", team.ID)
+
+	teamNamespace := entity.Namespace{
+		ID:      bson.NewObjectId(),
+		Name:    namesgenerator.GetRandomName(0),
+		OwnerID: ownerID,
+		TeamID:  team.ID,
+	}
+	require.NoError(t, session.Insert(entity.NamespaceCollectionName, &teamNamespace))
+	defer session.Remove(entity.NamespaceCollectionName, "_id", teamNamespace.ID)
+
+	names, err := FindRoleNamespaces(session, viewerID, entity.TeamViewerRole)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{teamNamespace.Name}, names)
+
+	names, err = FindRoleNamespaces(session, viewerID, entity.TeamAdminRole)
+	assert.NoError(t, err)
+	assert.Len(t, names, 0)
+
+	names, err = FindRoleNamespaces(session, ownerID, entity.TeamAdminRole)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{teamNamespace.Name}, names)
+
+	// the namespaces not created by vortex are not shared with the teams
+	allowed, err := HasNamespaceRole(session, "default", viewerID, entity.TeamViewerRole)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	allowed, err = HasNamespaceRole(session, teamNamespace.Name, viewerID, entity.TeamViewerRole)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
@@ -3,6 +3,7 @@ package backend
 import (
 	"github.com/linkernetworks/mongo"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/utils"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -17,3 +18,25 @@ func FindUserByID(session *mongo.Session, ID bson.ObjectId) (entity.User, error)
 	}
 	return user, nil
 }
+
+// UpdatePassword will hash and update the password of the user, and revoke all tokens of the user
+func UpdatePassword(session *mongo.Session, ID bson.ObjectId, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := session.C(entity.UserCollectionName).UpdateId(ID, bson.M{
+		"$set": bson.M{"loginCredential.password": hashedPassword},
+	}); err != nil {
+		return err
+	}
+	return RevokeUserTokens(session, ID)
+}
+
+// RevokeUserTokens will revoke all sessions and remove all API tokens of the user
+func RevokeUserTokens(session *mongo.Session, ID bson.ObjectId) error {
+	if err := RevokeUserSessions(session, ID); err != nil {
+		return err
+	}
+	return RemoveUserAPITokens(session, ID)
+}
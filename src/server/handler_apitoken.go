@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/web"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func createAPITokenHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+	if !isOwner(req, bson.ObjectIdHex(id)) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the tokens are not owned by the user"))
+		return
+	}
+	// a leaked API token could mint the tokens which never expire, so the tokens are only created by the signed in users
+	if _, ok := req.Attribute("APITokenID").(string); ok {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the API tokens can not create other API tokens"))
+		return
+	}
+
+	token := entity.APIToken{}
+	if err := req.ReadEntity(&token); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	user, err := backend.FindUserByID(session, bson.ObjectIdHex(id))
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	// the token has the role of the user by default
+	if token.Role == "" {
+		token.Role = user.Role
+	}
+
+	if err := sp.Validator.Struct(token); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	// the token can not exceed the role of the user, nor the role of the requester
+	role, _ := req.Attribute("Role").(string)
+	if !backend.IsRoleWithin(token.Role, backend.LowerRole(user.Role, role)) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the role %s of the token is higher than the role of the user", token.Role))
+		return
+	}
+
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(time.Now()) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("The expiration time of the token has passed"))
+		return
+	}
+
+	token.UserID = user.ID
+	createdToken, plainToken, err := backend.CreateAPIToken(session, token)
+	if err != nil {
+		if mgo.IsDup(err) {
+			response.Conflict(req.Request, resp.ResponseWriter, fmt.Errorf("Token: %s already existed", token.Name))
+		} else {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		}
+		return
+	}
+
+	// the plain token is only returned once
+	createdToken.Token = plainToken
+	resp.WriteHeaderAndEntity(http.StatusCreated, createdToken)
+}
+
+func listAPITokenHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+	if !isOwner(req, bson.ObjectIdHex(id)) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the tokens are not owned by the user"))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	tokens := []entity.APIToken{}
+	if err := session.C(entity.APITokenCollectionName).Find(bson.M{"userID": bson.ObjectIdHex(id)}).Sort("_id").All(&tokens); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	resp.WriteEntity(tokens)
+}
+
+func deleteAPITokenHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	tokenID := req.PathParameter("tokenID")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+	if !bson.IsObjectIdHex(tokenID) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid token ID: %s", tokenID))
+		return
+	}
+	if !isOwner(req, bson.ObjectIdHex(id)) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the tokens are not owned by the user"))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	if err := session.C(entity.APITokenCollectionName).Remove(bson.M{
+		"_id":    bson.ObjectIdHex(tokenID),
+		"userID": bson.ObjectIdHex(id),
+	}); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "Token Deleted Success",
+	})
+}
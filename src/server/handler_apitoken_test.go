@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (suite *UserTestSuite) createAPIToken(userID bson.ObjectId, bearer string, token entity.APIToken) *httptest.ResponseRecorder {
+	bodyBytes, err := json.MarshalIndent(token, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/"+userID.Hex()+"/tokens", bodyReader)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", bearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter
+}
+
+func (suite *UserTestSuite) TestAPIToken() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+	defer backend.RemoveUserAPITokens(suite.session, user.ID)
+
+	signIn := suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	})
+	bearer := "Bearer " + signIn.Message
+
+	expiresAt := time.Now().Add(time.Hour)
+	httpWriter := suite.createAPIToken(user.ID, bearer, entity.APIToken{
+		Name:      "ci",
+		ExpiresAt: &expiresAt,
+	})
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+
+	token := entity.APIToken{}
+	err := json.Unmarshal(httpWriter.Body.Bytes(), &token)
+	suite.NoError(err)
+	suite.Equal("ci", token.Name)
+	suite.Equal(user.Role, token.Role)
+	suite.True(strings.HasPrefix(token.Token, backend.APITokenPrefix))
+
+	// the API token is accepted as the bearer credential
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(token.Token))
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Token+"0"))
+
+	// the name of the token is unique for the user
+	httpWriter = suite.createAPIToken(user.ID, bearer, entity.APIToken{Name: "ci"})
+	assertResponseCode(suite.T(), http.StatusConflict, httpWriter)
+
+	// list the tokens without the plain token
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/users/"+user.ID.Hex()+"/tokens", nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", "Bearer "+token.Token)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	tokens := []entity.APIToken{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &tokens)
+	suite.NoError(err)
+	suite.Len(tokens, 1)
+	suite.Equal(token.ID, tokens[0].ID)
+	suite.Empty(tokens[0].Token)
+	suite.NotNil(tokens[0].LastUsedAt)
+
+	// delete the token, it can not be used anymore
+	httpRequest, err = http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+user.ID.Hex()+"/tokens/"+token.ID.Hex(), nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", bearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Token))
+}
+
+func (suite *UserTestSuite) TestAPITokenRoleScope() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+	defer backend.RemoveUserAPITokens(suite.session, user.ID)
+
+	// the token can not be higher than the role of the user
+	httpWriter := suite.createAPIToken(user.ID, suite.JWTBearer, entity.APIToken{
+		Name: "root",
+		Role: entity.RootRole,
+	})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.createAPIToken(user.ID, suite.JWTBearer, entity.APIToken{
+		Name: "guest",
+		Role: entity.GuestRole,
+	})
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+
+	token := entity.APIToken{}
+	err := json.Unmarshal(httpWriter.Body.Bytes(), &token)
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, token.Role)
+
+	// an API token can not create other tokens, even with the same role
+	httpWriter = suite.createAPIToken(user.ID, "Bearer "+token.Token, entity.APIToken{
+		Name: "user",
+		Role: entity.UserRole,
+	})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.createAPIToken(user.ID, "Bearer "+token.Token, entity.APIToken{
+		Name: "another-guest",
+		Role: entity.GuestRole,
+	})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// the token can not manage the tokens of other users
+	httpWriter = suite.createAPIToken(bson.NewObjectId(), "Bearer "+token.Token, entity.APIToken{
+		Name: "guest",
+		Role: entity.GuestRole,
+	})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// the token in the past is invalid
+	expiresAt := time.Now().Add(-time.Hour)
+	httpWriter = suite.createAPIToken(user.ID, suite.JWTBearer, entity.APIToken{
+		Name:      "expired",
+		ExpiresAt: &expiresAt,
+	})
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+
+	// the expired token is rejected
+	_, plainToken, err := backend.CreateAPIToken(suite.session, entity.APIToken{
+		UserID:    user.ID,
+		Name:      "expired",
+		Role:      entity.GuestRole,
+		ExpiresAt: &expiresAt,
+	})
+	suite.NoError(err)
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(plainToken))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/deployment"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	response "github.com/hwchiu/vortex/src/net/http"
 	"github.com/hwchiu/vortex/src/server/backend"
 	"github.com/hwchiu/vortex/src/service"
@@ -47,6 +48,14 @@ func createAppHandler(ctx *web.Context) {
 	})
 	defer session.Close()
 
+	if allowed, err := canUseNamespace(session, req, p.Deployment.Namespace, entity.TeamMemberRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user can not use the namespace %s", p.Deployment.Namespace))
+		return
+	}
+
 	p.Deployment.ID = bson.NewObjectId()
 	p.Service.ID = bson.NewObjectId()
 
@@ -69,6 +78,8 @@ func createAppHandler(ctx *web.Context) {
 	if err := deployment.CreateDeployment(sp, &p.Deployment); err != nil {
 		if errors.IsAlreadyExists(err) {
 			response.Conflict(req.Request, resp.ResponseWriter, fmt.Errorf("Deployment Name: %s already existed", p.Deployment.Name))
+		} else if ipam.IsConflict(err) {
+			response.Conflict(req.Request, resp.ResponseWriter, err)
 		} else {
 			response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/net/http/query"
+	"github.com/hwchiu/vortex/src/web"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// auditSelector returns the mongo selector of the audit logs by the query parameters
+func auditSelector(query *query.QueryUrl) (bson.M, error) {
+	selector := bson.M{}
+
+	if user, ok := query.Str("user"); ok {
+		if !bson.IsObjectIdHex(user) {
+			return nil, fmt.Errorf("Invalid user ID: %s", user)
+		}
+		selector["userID"] = user
+	}
+	if resource, ok := query.Str("resource"); ok {
+		selector["resourceType"] = resource
+	}
+	if resourceID, ok := query.Str("resourceID"); ok {
+		selector["resourceID"] = resourceID
+	}
+	if action, ok := query.Str("action"); ok {
+		selector["action"] = action
+	}
+
+	createdAt := bson.M{}
+	for key, operator := range map[string]string{"since": "$gte", "until": "$lt"} {
+		value, ok := query.Str(key)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time of %s, it should be RFC3339: %s", key, value)
+		}
+		createdAt[operator] = t
+	}
+	if len(createdAt) > 0 {
+		selector["createdAt"] = createdAt
+	}
+	return selector, nil
+}
+
+func listAuditHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	var pageSize = 10
+	query := query.New(req.Request.URL.Query())
+
+	page, err := query.Int("page", 1)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	pageSize, err = query.Int("page_size", pageSize)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	selector, err := auditSelector(query)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	auditLogs := []entity.AuditLog{}
+	q := session.C(entity.AuditCollectionName).Find(selector).Sort("-createdAt", "-_id").Skip((page - 1) * pageSize).Limit(pageSize)
+	if err := q.All(&auditLogs); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	count, err := session.Count(entity.AuditCollectionName, selector)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	totalPages := int(math.Ceil(float64(count) / float64(pageSize)))
+	resp.AddHeader("X-Total-Count", strconv.Itoa(count))
+	resp.AddHeader("X-Total-Pages", strconv.Itoa(totalPages))
+	resp.WriteEntity(auditLogs)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	sp        *serviceprovider.Container
+	wc        *restful.Container
+	session   *mongo.Session
+	JWTBearer string
+	rootID    string
+}
+
+func (suite *AuditTestSuite) SetupSuite() {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+
+	suite.sp = sp
+	// init session
+	suite.session = sp.Mongo.NewSession()
+	// init restful container
+	suite.wc = restful.NewContainer()
+	suite.wc.Filter(auditMiddleware(suite.sp))
+	suite.wc.Add(newUserService(suite.sp))
+	suite.wc.Add(newAuditService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
+
+	root := entity.User{}
+	err := suite.session.FindOne(entity.UserCollectionName, bson.M{"loginCredential.username": "test@linkernetworks.com"}, &root)
+	suite.NoError(err)
+	suite.rootID = root.ID.Hex()
+}
+
+func (suite *AuditTestSuite) TearDownSuite() {}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) listAudit(values url.Values, bearer string) ([]entity.AuditLog, *httptest.ResponseRecorder) {
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/audit?"+values.Encode(), nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Authorization", bearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+
+	auditLogs := []entity.AuditLog{}
+	if httpWriter.Code == http.StatusOK {
+		err = json.Unmarshal(httpWriter.Body.Bytes(), &auditLogs)
+		suite.NoError(err)
+	}
+	return auditLogs, httpWriter
+}
+
+func (suite *AuditTestSuite) TestAuditUserLifecycle() {
+	since := time.Now().Add(-time.Second)
+	user := entity.User{
+		LoginCredential: entity.LoginCredential{
+			Username: namesgenerator.GetRandomName(0) + "@linkernetworks.com",
+			Password: "p@ssw0rd",
+		},
+		DisplayName: "John Doe",
+		Role:        "user",
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "0911111111",
+	}
+
+	bodyBytes, err := json.MarshalIndent(user, "", "  ")
+	suite.NoError(err)
+
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users", strings.NewReader(string(bodyBytes)))
+	suite.NoError(err)
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", user.LoginCredential.Username)
+
+	createdUser := entity.User{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &createdUser)
+	suite.NoError(err)
+
+	// the wrong password of the user is recorded without the actor
+	httpRequest, err = http.NewRequest("POST", "http://localhost:7890/v1/users/signin", strings.NewReader(`{"username":"`+user.LoginCredential.Username+`","password":"wrong"}`))
+	suite.NoError(err)
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+	defer suite.session.Remove(entity.LoginAttemptCollectionName, "_id", "username:"+user.LoginCredential.Username)
+
+	httpRequest, err = http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+createdUser.ID.Hex(), nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	auditLogs, httpWriter := suite.listAudit(url.Values{
+		"resource":   {"users"},
+		"resourceID": {createdUser.ID.Hex()},
+	}, suite.JWTBearer)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	suite.Equal("2", httpWriter.Header().Get("X-Total-Count"))
+	suite.Len(auditLogs, 2)
+
+	// the newest one is the first
+	suite.Equal(entity.DeleteAction, auditLogs[0].Action)
+	suite.Equal(entity.CreateAction, auditLogs[1].Action)
+	for _, auditLog := range auditLogs {
+		suite.Equal(suite.rootID, auditLog.UserID)
+		suite.Equal(entity.RootRole, auditLog.Role)
+		suite.Equal("users", auditLog.ResourceType)
+		suite.Equal(http.StatusOK, auditLog.Status)
+		suite.NotNil(auditLog.CreatedAt)
+	}
+	suite.Equal(utils.SHA256String(string(bodyBytes)), auditLogs[1].BodyDigest)
+	suite.Empty(auditLogs[0].BodyDigest)
+
+	auditLogs, httpWriter = suite.listAudit(url.Values{
+		"resource": {"users"},
+		"action":   {entity.CreateAction},
+		"since":    {since.Format(time.RFC3339)},
+	}, suite.JWTBearer)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	signInFound := false
+	for _, auditLog := range auditLogs {
+		if auditLog.Path == "/v1/users/signin" && auditLog.Status == http.StatusUnauthorized {
+			signInFound = true
+			suite.Empty(auditLog.UserID)
+		}
+	}
+	suite.True(signInFound)
+
+	// the reads are not recorded
+	auditLogs, httpWriter = suite.listAudit(url.Values{
+		"user":  {suite.rootID},
+		"since": {since.Format(time.RFC3339)},
+	}, suite.JWTBearer)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	for _, auditLog := range auditLogs {
+		suite.NotEqual("GET", auditLog.Method)
+	}
+
+	auditLogs, httpWriter = suite.listAudit(url.Values{
+		"resourceID": {createdUser.ID.Hex()},
+		"until":      {since.Format(time.RFC3339)},
+	}, suite.JWTBearer)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	suite.Len(auditLogs, 0)
+}
+
+func (suite *AuditTestSuite) TestAuditLargeBody() {
+	// the body is not read into the memory without a limit
+	body := strings.Repeat("a", maxAuditRequestBodySize+1)
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users", strings.NewReader(body))
+	suite.NoError(err)
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusRequestEntityTooLarge, httpWriter)
+}
+
+func (suite *AuditTestSuite) TestListAuditWithInvalidQuery() {
+	for _, values := range []url.Values{
+		{"user": {"invalid"}},
+		{"since": {"yesterday"}},
+		{"page": {"first"}},
+	} {
+		_, httpWriter := suite.listAudit(values, suite.JWTBearer)
+		assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+	}
+}
+
+func (suite *AuditTestSuite) TestListAuditWithoutRootRole() {
+	hashedPassword, err := utils.HashPassword("p@ssw0rd")
+	suite.NoError(err)
+	user := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: namesgenerator.GetRandomName(0) + "@linkernetworks.com",
+			Password: hashedPassword,
+		},
+		Role: entity.UserRole,
+	}
+	err = suite.session.Insert(entity.UserCollectionName, &user)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/signin", strings.NewReader(`{"username":"`+user.LoginCredential.Username+`","password":"p@ssw0rd"}`))
+	suite.NoError(err)
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	token := struct {
+		Message string `json:"message"`
+	}{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &token)
+	suite.NoError(err)
+
+	_, httpWriter = suite.listAudit(url.Values{}, "Bearer "+token.Message)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/deployment"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	response "github.com/hwchiu/vortex/src/net/http"
 	"github.com/hwchiu/vortex/src/net/http/query"
 	"github.com/hwchiu/vortex/src/server/backend"
@@ -45,6 +46,14 @@ func createDeploymentHandler(ctx *web.Context) {
 	})
 	defer session.Close()
 
+	if allowed, err := canUseNamespace(session, req, p.Namespace, entity.TeamMemberRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user can not use the namespace %s", p.Namespace))
+		return
+	}
+
 	// Check whether this name has been used
 	p.ID = bson.NewObjectId()
 	p.CreatedAt = timeutils.Now()
@@ -60,6 +69,8 @@ func createDeploymentHandler(ctx *web.Context) {
 			response.Conflict(req.Request, resp.ResponseWriter, fmt.Errorf("Create setting has conflict: %v", err))
 		} else if errors.IsInvalid(err) {
 			response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Create setting is invalid: %v", err))
+		} else if ipam.IsConflict(err) {
+			response.Conflict(req.Request, resp.ResponseWriter, err)
 		} else {
 			response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		}
@@ -93,12 +104,18 @@ func deleteDeploymentHandler(ctx *web.Context) {
 		return
 	}
 
+	// the admins of the team which owns the namespace can delete the deployments of the other members
+	if allowed, err := canAccessObject(session, req, p.OwnerID, p.Namespace, entity.TeamAdminRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the deployment is not owned by the user"))
+		return
+	}
+
+	// the deployment which is already gone in the kubernetes is still removed
 	if err := deployment.DeleteDeployment(sp, &p); err != nil {
-		if errors.IsNotFound(err) {
-			response.NotFound(req.Request, resp.ResponseWriter, err)
-		} else {
-			response.InternalServerError(req.Request, resp.ResponseWriter, err)
-		}
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
 	}
 
@@ -143,7 +160,11 @@ func listDeploymentHandler(ctx *web.Context) {
 	var c = session.C(entity.DeploymentCollectionName)
 	var q *mgo.Query
 
-	selector := bson.M{}
+	selector, err := namespaceRoleSelector(session, req, entity.TeamViewerRole)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
 	q = c.Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
 
 	if err := q.All(&deployments); err != nil {
@@ -162,7 +183,7 @@ func listDeploymentHandler(ctx *web.Context) {
 		// find owner in user entity
 		deployment.CreatedBy, _ = backend.FindUserByID(session, deployment.OwnerID)
 	}
-	count, err := session.Count(entity.DeploymentCollectionName, bson.M{})
+	count, err := session.Count(entity.DeploymentCollectionName, selector)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
@@ -193,6 +214,15 @@ func getDeploymentHandler(ctx *web.Context) {
 			return
 		}
 	}
+
+	// the members of the team which owns the namespace can read the deployments of the namespace
+	if allowed, err := canAccessObject(session, req, deployment.OwnerID, deployment.Namespace, entity.TeamViewerRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the deployment is not owned by the user"))
+		return
+	}
 	deployment.CreatedBy, _ = backend.FindUserByID(session, deployment.OwnerID)
 	resp.WriteEntity(deployment)
 }
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/net/http/query"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/web"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func createInvitationHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+	userID, ok := req.Attribute("UserID").(string)
+	if !ok || !bson.IsObjectIdHex(userID) {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: User ID is empty"))
+		return
+	}
+
+	invitation := entity.Invitation{}
+	if err := req.ReadEntity(&invitation); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(invitation); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	invitation.CreatedBy = bson.ObjectIdHex(userID)
+	invitation, err := backend.CreateInvitation(session, invitation)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	resp.WriteHeaderAndEntity(http.StatusCreated, invitation)
+}
+
+func listInvitationHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	var pageSize = 10
+	query := query.New(req.Request.URL.Query())
+
+	page, err := query.Int("page", 1)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	pageSize, err = query.Int("page_size", pageSize)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	invitations := []entity.Invitation{}
+	selector := bson.M{}
+	if email, ok := query.Str("email"); ok {
+		// the emails of the invitations are stored in lower case
+		selector["email"] = strings.ToLower(email)
+	}
+	q := session.C(entity.InvitationCollectionName).Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
+	if err := q.All(&invitations); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	count, err := session.Count(entity.InvitationCollectionName, selector)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	totalPages := int(math.Ceil(float64(count) / float64(pageSize)))
+	resp.AddHeader("X-Total-Count", strconv.Itoa(count))
+	resp.AddHeader("X-Total-Pages", strconv.Itoa(totalPages))
+	resp.WriteEntity(invitations)
+}
+
+func deleteInvitationHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid invitation ID: %s", id))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	if err := session.Remove(entity.InvitationCollectionName, "_id", bson.ObjectIdHex(id)); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "Invitation Revoked Success",
+	})
+}
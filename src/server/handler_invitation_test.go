@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type InvitationTestSuite struct {
+	suite.Suite
+	sp        *serviceprovider.Container
+	wc        *restful.Container
+	session   *mongo.Session
+	JWTBearer string
+}
+
+func (suite *InvitationTestSuite) SetupSuite() {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+
+	suite.sp = sp
+	// init session
+	suite.session = sp.Mongo.NewSession()
+	// init restful container
+	suite.wc = restful.NewContainer()
+	suite.wc.Add(newUserService(suite.sp))
+	suite.wc.Add(newInvitationService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
+}
+
+func (suite *InvitationTestSuite) TearDownSuite() {}
+
+func TestInvitationSuite(t *testing.T) {
+	suite.Run(t, new(InvitationTestSuite))
+}
+
+func (suite *InvitationTestSuite) request(method, path, bearer string, body interface{}) *httptest.ResponseRecorder {
+	bodyBytes, err := json.MarshalIndent(body, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest(method, "http://localhost:7890"+path, bodyReader)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	if bearer != "" {
+		httpRequest.Header.Add("Authorization", bearer)
+	}
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter
+}
+
+func (suite *InvitationTestSuite) createInvitation(email, role string) entity.Invitation {
+	httpWriter := suite.request("POST", "/v1/invitations", suite.JWTBearer, entity.Invitation{
+		Email: email,
+		Role:  role,
+	})
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+
+	invitation := entity.Invitation{}
+	err := json.Unmarshal(httpWriter.Body.Bytes(), &invitation)
+	suite.NoError(err)
+	suite.NotEmpty(invitation.Token)
+	return invitation
+}
+
+func newSignupRequest(email, inviteToken string) entity.SignupRequest {
+	return entity.SignupRequest{
+		User: entity.User{
+			LoginCredential: entity.LoginCredential{
+				Username: email,
+				Password: "p@ssw0rd",
+			},
+			DisplayName: "John Doe",
+			FirstName:   "John",
+			LastName:    "Doe",
+			PhoneNumber: "0911111111",
+		},
+		InviteToken: inviteToken,
+	}
+}
+
+func (suite *InvitationTestSuite) TestInviteOnlySignup() {
+	err := backend.SetupSignup(&config.SignupConfig{InviteOnly: true})
+	suite.NoError(err)
+	defer backend.SetupSignup(nil)
+
+	email := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	invitation := suite.createInvitation(email, entity.GuestRole)
+	defer suite.session.Remove(entity.InvitationCollectionName, "_id", invitation.ID)
+
+	// the token is only returned when creating
+	invitations := []entity.Invitation{}
+	httpWriter := suite.request("GET", "/v1/invitations?email="+email, suite.JWTBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &invitations)
+	suite.NoError(err)
+	suite.Len(invitations, 1)
+	suite.Equal(invitation.ID, invitations[0].ID)
+	suite.Empty(invitations[0].Token)
+
+	// the email is matched case-insensitively
+	httpWriter = suite.request("GET", "/v1/invitations?email="+strings.ToUpper(email), suite.JWTBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &invitations)
+	suite.NoError(err)
+	suite.Len(invitations, 1)
+
+	httpWriter = suite.request("POST", "/v1/users/signup", "", newSignupRequest(email, ""))
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+	httpWriter = suite.request("POST", "/v1/users/signup", "", newSignupRequest(namesgenerator.GetRandomName(0)+"@linkernetworks.com", invitation.Token))
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.request("POST", "/v1/users/signup", "", newSignupRequest(email, invitation.Token))
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", email)
+
+	user := entity.User{}
+	err = suite.session.FindOne(entity.UserCollectionName, bson.M{"loginCredential.username": email}, &user)
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, user.Role)
+
+	// the invitation can only be redeemed once
+	suite.session.Remove(entity.UserCollectionName, "loginCredential.username", email)
+	httpWriter = suite.request("POST", "/v1/users/signup", "", newSignupRequest(email, invitation.Token))
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+}
+
+func (suite *InvitationTestSuite) TestOpenSignupWithInvitation() {
+	// the invitation decides the role even if the signup is open
+	email := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	invitation := suite.createInvitation(email, entity.RootRole)
+	defer suite.session.Remove(entity.InvitationCollectionName, "_id", invitation.ID)
+
+	httpWriter := suite.request("POST", "/v1/users/signup", "", newSignupRequest(strings.ToUpper(email), invitation.Token))
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", email)
+
+	user := entity.User{}
+	err := suite.session.FindOne(entity.UserCollectionName, bson.M{"loginCredential.username": email}, &user)
+	suite.NoError(err)
+	suite.Equal(entity.RootRole, user.Role)
+
+	// the signup without the invitation is still the user role
+	otherEmail := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	httpWriter = suite.request("POST", "/v1/users/signup", "", newSignupRequest(otherEmail, ""))
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", otherEmail)
+	err = suite.session.FindOne(entity.UserCollectionName, bson.M{"loginCredential.username": otherEmail}, &user)
+	suite.NoError(err)
+	suite.Equal(entity.UserRole, user.Role)
+}
+
+func (suite *InvitationTestSuite) TestRevokeInvitation() {
+	err := backend.SetupSignup(&config.SignupConfig{InviteOnly: true})
+	suite.NoError(err)
+	defer backend.SetupSignup(nil)
+
+	email := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	invitation := suite.createInvitation(email, entity.UserRole)
+
+	httpWriter := suite.request("DELETE", "/v1/invitations/"+invitation.ID.Hex(), suite.JWTBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	httpWriter = suite.request("DELETE", "/v1/invitations/"+invitation.ID.Hex(), suite.JWTBearer, nil)
+	assertResponseCode(suite.T(), http.StatusNotFound, httpWriter)
+
+	httpWriter = suite.request("POST", "/v1/users/signup", "", newSignupRequest(email, invitation.Token))
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+}
+
+func (suite *InvitationTestSuite) TestCreateInvalidInvitation() {
+	for _, invitation := range []entity.Invitation{
+		{Email: "invalid", Role: entity.UserRole},
+		{Email: "invitee@linkernetworks.com", Role: "admin"},
+	} {
+		httpWriter := suite.request("POST", "/v1/invitations", suite.JWTBearer, invitation)
+		assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+	}
+}
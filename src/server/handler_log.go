@@ -4,18 +4,78 @@ import (
 	"fmt"
 	"net/http"
 
+	restful "github.com/emicklei/go-restful"
 	"github.com/hwchiu/vortex/src/container"
+	"github.com/hwchiu/vortex/src/deployment"
+	"github.com/hwchiu/vortex/src/entity"
 	response "github.com/hwchiu/vortex/src/net/http"
 	"github.com/hwchiu/vortex/src/net/http/query"
+	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/hwchiu/vortex/src/web"
+	"github.com/linkernetworks/mongo"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"k8s.io/apimachinery/pkg/api/errors"
 )
 
+// canReadContainerLogs checks whether the requester can read the logs of the kubernetes pod
+// The pods created by vortex are read like their pods or deployments, the others need at least the viewer role of the namespace
+func canReadContainerLogs(sp *serviceprovider.Container, session *mongo.Session, req *restful.Request, namespace, podName string) (bool, error) {
+	if role, _ := req.Attribute("Role").(string); role == entity.RootRole {
+		return true, nil
+	}
+
+	p := entity.Pod{}
+	err := session.FindOne(entity.PodCollectionName, bson.M{"name": podName, "namespace": namespace}, &p)
+	if err == nil {
+		return canAccessObject(session, req, p.OwnerID, namespace, entity.TeamViewerRole)
+	} else if err != mgo.ErrNotFound {
+		return false, err
+	}
+
+	// the pods of a deployment have the label of the deployment name
+	kubePod, err := sp.KubeCtl.GetPod(podName, namespace)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+	} else if name, ok := kubePod.Labels[deployment.DefaultLabel]; ok {
+		d := entity.Deployment{}
+		err := session.FindOne(entity.DeploymentCollectionName, bson.M{"name": name, "namespace": namespace}, &d)
+		if err == nil {
+			return canAccessObject(session, req, d.OwnerID, namespace, entity.TeamViewerRole)
+		} else if err != mgo.ErrNotFound {
+			return false, err
+		}
+	}
+	return canAccessObject(session, req, "", namespace, entity.TeamViewerRole)
+}
+
+// checkContainerLogsAccess writes the error response and returns false when the requester can not read the logs
+func checkContainerLogsAccess(sp *serviceprovider.Container, req *restful.Request, resp *restful.Response, namespace, podName string) bool {
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	if allowed, err := canReadContainerLogs(sp, session, req, namespace, podName); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return false
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the pod %s is not owned by the user", podName))
+		return false
+	}
+	return true
+}
+
 func getContainerLogsHandler(ctx *web.Context) {
 	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
 
 	namespace := req.PathParameter("namespace")
 	podID := req.PathParameter("pod")
 	containerID := req.PathParameter("container")
+	if !checkContainerLogsAccess(sp, req, resp, namespace, podID) {
+		return
+	}
 
 	refTimestamp := req.QueryParameter("referenceTimestamp")
 	if refTimestamp == "" {
@@ -74,6 +134,9 @@ func getContainerLogFileHandler(ctx *web.Context) {
 	podID := req.PathParameter("pod")
 	containerID := req.PathParameter("container")
 	usePreviousLogs := req.QueryParameter("previous") == "true"
+	if !checkContainerLogsAccess(sp, req, resp, namespace, podID) {
+		return
+	}
 
 	logStream, err := container.GetLogFile(sp, namespace, podID, containerID, usePreviousLogs)
 	if err != nil {
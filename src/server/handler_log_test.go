@@ -9,8 +9,11 @@ import (
 
 	restful "github.com/emicklei/go-restful"
 	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/server/backend"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func init() {
@@ -19,8 +22,9 @@ func init() {
 
 type LogTestSuite struct {
 	suite.Suite
-	sp *serviceprovider.Container
-	wc *restful.Container
+	sp        *serviceprovider.Container
+	wc        *restful.Container
+	JWTBearer string
 }
 
 func (suite *LogTestSuite) SetupSuite() {
@@ -32,6 +36,11 @@ func (suite *LogTestSuite) SetupSuite() {
 	suite.wc = restful.NewContainer()
 	service := newContainerService(suite.sp)
 	suite.wc.Add(service)
+	suite.wc.Add(newUserService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
 }
 
 func (suite *LogTestSuite) TearDownSuite() {}
@@ -49,6 +58,7 @@ func (suite *LogTestSuite) TestGetContainerLogs() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/containers/logs/"+namespace+"/"+podName+"/"+containerName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -64,8 +74,31 @@ func (suite *LogTestSuite) TestGetContainerLogFile() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/containers/logs/file/"+namespace+"/"+podName+"/"+containerName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
 	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
 }
+
+func (suite *LogTestSuite) TestGetContainerLogsForbidden() {
+	namespace := "vortex"
+	pods, err := suite.sp.KubeCtl.GetPods(namespace)
+	suite.NoError(err)
+	podName := pods[0].Name
+	containerName := pods[0].Status.ContainerStatuses[0].Name
+
+	// the user has no role in the namespace which is not created by vortex
+	token, err := backend.GenerateToken(bson.NewObjectId().Hex(), entity.User{Role: entity.UserRole})
+	suite.NoError(err)
+
+	for _, path := range []string{"/v1/containers/logs/", "/v1/containers/logs/file/"} {
+		httpRequest, err := http.NewRequest("GET", "http://localhost:7890"+path+namespace+"/"+podName+"/"+containerName, nil)
+		suite.NoError(err)
+		httpRequest.Header.Add("Authorization", "Bearer "+token)
+
+		httpWriter := httptest.NewRecorder()
+		suite.wc.Dispatch(httpWriter, httpRequest)
+		assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+	}
+}
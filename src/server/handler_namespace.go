@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strconv"
 
+	restful "github.com/emicklei/go-restful"
+	"github.com/linkernetworks/mongo"
 	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/entity"
 	"github.com/hwchiu/vortex/src/namespace"
@@ -19,6 +21,24 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+// namespaceSelector returns the mongo selector of the namespaces owned by the requester or the teams of the requester
+func namespaceSelector(session *mongo.Session, req *restful.Request) (bson.M, error) {
+	selector, err := ownerSelector(req)
+	if err != nil {
+		return nil, err
+	}
+	role, _ := req.Attribute("Role").(string)
+	if role == entity.RootRole {
+		return selector, nil
+	}
+
+	teamIDs, err := backend.FindUserTeamIDs(session, selector["ownerID"].(bson.ObjectId))
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$or": []bson.M{selector, {"teamID": bson.M{"$in": teamIDs}}}}, nil
+}
+
 func createNamespaceHandler(ctx *web.Context) {
 	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
 	userID, ok := req.Attribute("UserID").(string)
@@ -45,6 +65,27 @@ func createNamespaceHandler(ctx *web.Context) {
 	})
 	defer session.Close()
 
+	// only the admins of the team can add the namespace to the team
+	if n.TeamID != "" {
+		if _, err := backend.FindTeamByID(session, n.TeamID); err != nil {
+			switch err {
+			case mgo.ErrNotFound:
+				response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Team: %s not found", n.TeamID.Hex()))
+				return
+			default:
+				response.InternalServerError(req.Request, resp.ResponseWriter, err)
+				return
+			}
+		}
+		if allowed, err := hasTeamRoleByID(session, req, n.TeamID, entity.TeamAdminRole); err != nil {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		} else if !allowed {
+			response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user is not the admin of the team"))
+			return
+		}
+	}
+
 	// Check whether this name has been used
 	n.ID = bson.NewObjectId()
 	n.CreatedAt = timeutils.Now()
@@ -85,6 +126,17 @@ func deleteNamespaceHandler(ctx *web.Context) {
 		return
 	}
 
+	if !isOwner(req, n.OwnerID) {
+		// the admins of the team can delete the namespace of the team
+		if allowed, err := hasTeamRoleByID(session, req, n.TeamID, entity.TeamAdminRole); err != nil {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		} else if !allowed {
+			response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the namespace is not owned by the user"))
+			return
+		}
+	}
+
 	if err := namespace.DeleteNamespace(sp, &n); err != nil {
 		if errors.IsNotFound(err) {
 			response.NotFound(req.Request, resp.ResponseWriter, err)
@@ -135,7 +187,11 @@ func listNamespaceHandler(ctx *web.Context) {
 	var c = session.C(entity.NamespaceCollectionName)
 	var q *mgo.Query
 
-	selector := bson.M{}
+	selector, err := namespaceSelector(session, req)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
 	q = c.Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
 
 	if err := q.All(&namespaces); err != nil {
@@ -154,7 +210,7 @@ func listNamespaceHandler(ctx *web.Context) {
 		// find owner in user entity
 		namespace.CreatedBy, _ = backend.FindUserByID(session, namespace.OwnerID)
 	}
-	count, err := session.Count(entity.NamespaceCollectionName, bson.M{})
+	count, err := session.Count(entity.NamespaceCollectionName, selector)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
@@ -185,6 +241,17 @@ func getNamespaceHandler(ctx *web.Context) {
 			return
 		}
 	}
+
+	if !isOwner(req, namespace.OwnerID) {
+		// the members of the team can read the namespace of the team
+		if allowed, err := hasTeamRoleByID(session, req, namespace.TeamID, entity.TeamViewerRole); err != nil {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		} else if !allowed {
+			response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the namespace is not owned by the user"))
+			return
+		}
+	}
 	// find owner in user entity
 	namespace.CreatedBy, _ = backend.FindUserByID(session, namespace.OwnerID)
 	resp.WriteEntity(namespace)
@@ -14,6 +14,7 @@ import (
 	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
 	ns "github.com/hwchiu/vortex/src/namespace"
+	"github.com/hwchiu/vortex/src/server/backend"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/stretchr/testify/suite"
@@ -175,6 +176,70 @@ func (suite *NamespaceTestSuite) TestGetNamespace() {
 	suite.Equal(nsName, namespace.Name)
 }
 
+func (suite *NamespaceTestSuite) TestNamespaceOwnership() {
+	ownerID := bson.NewObjectId()
+	namespace := entity.Namespace{
+		ID:      bson.NewObjectId(),
+		OwnerID: ownerID,
+		Name:    namesgenerator.GetRandomName(0),
+	}
+
+	// Create data into mongo manually
+	suite.session.C(entity.NamespaceCollectionName).Insert(namespace)
+	defer suite.session.Remove(entity.NamespaceCollectionName, "name", namespace.Name)
+
+	otherToken, err := backend.GenerateToken(bson.NewObjectId().Hex(), entity.User{Role: entity.UserRole})
+	suite.NoError(err)
+	ownerToken, err := backend.GenerateToken(ownerID.Hex(), entity.User{Role: entity.UserRole})
+	suite.NoError(err)
+
+	testCases := []struct {
+		cases   string
+		token   string
+		expect  int
+		listLen int
+	}{
+		{"owner", "Bearer " + ownerToken, http.StatusOK, 1},
+		{"other", "Bearer " + otherToken, http.StatusForbidden, 0},
+		{"root", suite.JWTBearer, http.StatusOK, 1},
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.cases, func(t *testing.T) {
+			httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/namespaces/"+namespace.ID.Hex(), nil)
+			suite.NoError(err)
+
+			httpRequest.Header.Add("Authorization", tc.token)
+			httpWriter := httptest.NewRecorder()
+			suite.wc.Dispatch(httpWriter, httpRequest)
+			assertResponseCode(t, tc.expect, httpWriter)
+
+			// root narrows down the list by the owner query
+			httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/namespaces?owner="+ownerID.Hex(), nil)
+			suite.NoError(err)
+
+			httpRequest.Header.Add("Authorization", tc.token)
+			httpWriter = httptest.NewRecorder()
+			suite.wc.Dispatch(httpWriter, httpRequest)
+			assertResponseCode(t, http.StatusOK, httpWriter)
+
+			namespaces := []entity.Namespace{}
+			err = json.Unmarshal(httpWriter.Body.Bytes(), &namespaces)
+			suite.NoError(err)
+			suite.Equal(tc.listLen, len(namespaces))
+		})
+	}
+
+	// other users can not delete it
+	httpRequest, err := http.NewRequest("DELETE", "http://localhost:7890/v1/namespaces/"+namespace.ID.Hex(), nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Authorization", "Bearer "+otherToken)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+}
+
 func (suite *NamespaceTestSuite) TestGetNamespaceWithInvalidID() {
 	// Get data with non-exits ID
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/namespaces/"+bson.NewObjectId().Hex(), nil)
@@ -2,17 +2,23 @@ package server
 
 import (
 	"fmt"
+	"log"
 	"math"
 	"net/http"
 	"strconv"
 
+	restful "github.com/emicklei/go-restful"
+	"github.com/linkernetworks/mongo"
 	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	"github.com/hwchiu/vortex/src/kubeutils"
 	response "github.com/hwchiu/vortex/src/net/http"
 	"github.com/hwchiu/vortex/src/net/http/query"
 	np "github.com/hwchiu/vortex/src/networkprovider"
+	"github.com/hwchiu/vortex/src/ovscontroller"
 	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/hwchiu/vortex/src/web"
 
 	mgo "gopkg.in/mgo.v2"
@@ -41,6 +47,11 @@ func createNetworkHandler(ctx *web.Context) {
 		return
 	}
 
+	if err := ipam.ValidateSubnets(network.Subnets); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
 	session := sp.Mongo.NewSession()
 	defer session.Close()
 	session.C(entity.NetworkCollectionName).EnsureIndex(
@@ -55,14 +66,13 @@ func createNetworkHandler(ctx *web.Context) {
 		return
 	}
 
-	if err := networkProvider.CreateNetwork(sp); err != nil {
-		response.InternalServerError(req.Request, resp.ResponseWriter, err)
-		return
-	}
-
+	// reserve the name by the pending network before changing the nodes
+	// so the request which loses the race never touches the bridge of the other one
 	network.ID = bson.NewObjectId()
 	network.CreatedAt = timeutils.Now()
 	network.OwnerID = bson.ObjectIdHex(userID)
+	network.Pending = true
+	network.PendingAt = timeutils.Now()
 	if err := session.Insert(entity.NetworkCollectionName, &network); err != nil {
 		if mgo.IsDup(err) {
 			response.Conflict(req.Request, resp, fmt.Errorf("Network Name: %s already existed", network.Name))
@@ -72,6 +82,29 @@ func createNetworkHandler(ctx *web.Context) {
 		return
 	}
 
+	if err := networkProvider.CreateNetwork(sp); err != nil {
+		// release the name
+		if removeErr := session.Remove(entity.NetworkCollectionName, "_id", network.ID); removeErr != nil {
+			log.Printf("Failed to remove the pending network %s: %v", network.Name, removeErr)
+		}
+		writeNodesError(req, resp, err)
+		return
+	}
+
+	if err := session.C(entity.NetworkCollectionName).UpdateId(network.ID, bson.M{
+		"$unset": bson.M{"pending": "", "pendingAt": ""},
+	}); err != nil {
+		// the network is not saved, so remove it from the nodes
+		if deleteErr := networkProvider.DeleteNetwork(sp); deleteErr != nil {
+			log.Printf("Failed to roll back the network %s: %v", network.Name, deleteErr)
+		}
+		session.Remove(entity.NetworkCollectionName, "_id", network.ID)
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	network.Pending = false
+	network.PendingAt = nil
+
 	// find owner in user entity
 	network.CreatedBy, _ = backend.FindUserByID(session, network.OwnerID)
 	resp.WriteHeaderAndEntity(http.StatusCreated, network)
@@ -101,7 +134,13 @@ func listNetworkHandler(ctx *web.Context) {
 	var c = session.C(entity.NetworkCollectionName)
 	var q *mgo.Query
 
-	selector := bson.M{}
+	selector, err := ownerSelector(req)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	// the networks being created only reserve the names
+	selector = bson.M{"$and": []bson.M{selector, np.NotCreatingSelector()}}
 	q = c.Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
 
 	if err := q.All(&networks); err != nil {
@@ -121,7 +160,7 @@ func listNetworkHandler(ctx *web.Context) {
 		network.CreatedBy, _ = backend.FindUserByID(session, network.OwnerID)
 	}
 
-	count, err := session.Count(entity.NetworkCollectionName, bson.M{})
+	count, err := session.Count(entity.NetworkCollectionName, selector)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
@@ -152,11 +191,149 @@ func getNetworkHandler(ctx *web.Context) {
 		}
 	}
 
+	if !isOwner(req, network.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the network is not owned by the user"))
+		return
+	}
+
+	// the network being created only reserves the name
+	if np.IsCreating(network) {
+		response.NotFound(req.Request, resp.ResponseWriter, mgo.ErrNotFound)
+		return
+	}
+
 	// find owner in user entity
 	network.CreatedBy, _ = backend.FindUserByID(session, network.OwnerID)
 	resp.WriteEntity(network)
 }
 
+func updateNetworkHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid network ID: %s", id))
+		return
+	}
+
+	update := entity.NetworkUpdate{}
+	if err := req.ReadEntity(&update); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(update); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	// the reconciler can not repair the network with the old spec while it's being changed
+	unlock := np.LockNetwork(bson.ObjectIdHex(id))
+	defer unlock()
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	var network entity.Network
+	if err := session.FindOne(entity.NetworkCollectionName, bson.M{"_id": bson.ObjectIdHex(id)}, &network); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if !isOwner(req, network.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the network is not owned by the user"))
+		return
+	}
+
+	if np.IsCreating(network) {
+		response.Conflict(req.Request, resp, fmt.Errorf("The Network %s is being created", network.Name))
+		return
+	} else if network.Pending {
+		response.Conflict(req.Request, resp, fmt.Errorf("The Network %s failed to be created, please delete it", network.Name))
+		return
+	}
+
+	updated := network
+	updated.Nodes = update.Nodes
+	updated.VlanTags = update.VlanTags
+
+	pods, err := kubeutils.GetNonCompletedPods(sp, bson.M{"networks.name": network.Name})
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	if err := checkStrandedPods(sp, updated, pods); err != nil {
+		response.MethodNotAllow(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	networkProvider, err := np.GetNetworkProvider(&network)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := networkProvider.UpdateNetwork(sp, updated); err != nil {
+		writeNodesError(req, resp, err)
+		return
+	}
+
+	if err := session.C(entity.NetworkCollectionName).UpdateId(network.ID, bson.M{
+		"$set": bson.M{
+			"nodes":    updated.Nodes,
+			"vlanTags": updated.VlanTags,
+		},
+	}); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	// the saved drift is checked with the old network
+	session.Remove(entity.NetworkStatusCollectionName, "_id", network.ID)
+
+	// find owner in user entity
+	updated.CreatedBy, _ = backend.FindUserByID(session, updated.OwnerID)
+	resp.WriteEntity(updated)
+}
+
+// checkStrandedPods will check the running pods of the network still work with the updated network
+// The pods can not run on the removed nodes, or use the VLAN tags which are not in the trunk
+func checkStrandedPods(sp *serviceprovider.Container, updated entity.Network, pods []entity.Pod) error {
+	nodes := map[string]bool{}
+	for _, node := range updated.Nodes {
+		nodes[node.Name] = true
+	}
+	vlanTags := map[int32]bool{}
+	for _, vlanTag := range updated.VlanTags {
+		vlanTags[vlanTag] = true
+	}
+
+	for _, pod := range pods {
+		// the ports without the VLAN trunk allow all VLANs
+		if len(vlanTags) > 0 {
+			for _, podNetwork := range pod.Networks {
+				if podNetwork.Name == updated.Name && podNetwork.VlanTag != nil && !vlanTags[*podNetwork.VlanTag] {
+					return fmt.Errorf("The Pod %s still uses the VLAN %d, please close the Pod first", pod.Name, *podNetwork.VlanTag)
+				}
+			}
+		}
+
+		currentPod, err := sp.KubeCtl.GetPod(pod.Name, pod.Namespace)
+		if err != nil {
+			continue
+		}
+		if nodeName := currentPod.Spec.NodeName; nodeName != "" && !nodes[nodeName] {
+			return fmt.Errorf("The Pod %s still runs on the node %s, please close the Pod first", pod.Name, nodeName)
+		}
+	}
+	return nil
+}
+
 func getNetworkStatusHandler(ctx *web.Context) {
 	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
 
@@ -178,6 +355,11 @@ func getNetworkStatusHandler(ctx *web.Context) {
 		}
 	}
 
+	if !isOwner(req, network.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the network is not owned by the user"))
+		return
+	}
+
 	ret, err := kubeutils.GetNonCompletedPods(sp, bson.M{"networks.name": network.Name})
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
@@ -190,11 +372,96 @@ func getNetworkStatusHandler(ctx *web.Context) {
 	resp.WriteEntity(nameList)
 }
 
+func getNetworkDriftHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid network ID: %s", id))
+		return
+	}
+
+	refresh, err := query.New(req.Request.URL.Query()).Bool("refresh", false)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	var network entity.Network
+	if err := session.FindOne(entity.NetworkCollectionName, bson.M{"_id": bson.ObjectIdHex(id)}, &network); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if !isOwner(req, network.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the network is not owned by the user"))
+		return
+	}
+
+	// the status is saved by the reconciler, the network is checked now if it's not checked yet
+	status := entity.NetworkStatus{}
+	err = session.FindOne(entity.NetworkStatusCollectionName, bson.M{"_id": network.ID}, &status)
+	if err != nil && err != mgo.ErrNotFound {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	if refresh || err == mgo.ErrNotFound {
+		if network.Type != entity.OVSKernelspaceNetworkType && network.Type != entity.OVSUserspaceNetworkType {
+			response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("The network type %s can not be checked", network.Type))
+			return
+		}
+		status, err = np.ReconcileNetwork(sp, network, false)
+		if err != nil {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+	resp.WriteEntity(status)
+}
+
+func getNetworkPortsHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	network, ok := findOwnedNetwork(session, req, resp)
+	if !ok {
+		return
+	}
+
+	if network.Type != entity.OVSKernelspaceNetworkType && network.Type != entity.OVSUserspaceNetworkType {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("The ports of the %s network can not be dumped", network.Type))
+		return
+	}
+
+	// the unreachable nodes are reported in the nodes instead of failing the request
+	ports, err := ovscontroller.DumpNetworkPorts(sp, network)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	resp.WriteEntity(ports)
+}
+
 func deleteNetworkHandler(ctx *web.Context) {
 	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
 
 	id := req.PathParameter("id")
 
+	// the reconciler can not recreate the network while it's being deleted
+	unlock := np.LockNetwork(bson.ObjectIdHex(id))
+	defer unlock()
+
 	session := sp.Mongo.NewSession()
 	defer session.Close()
 	c := session.C(entity.NetworkCollectionName)
@@ -211,6 +478,16 @@ func deleteNetworkHandler(ctx *web.Context) {
 		}
 	}
 
+	if !isOwner(req, network.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the network is not owned by the user"))
+		return
+	}
+
+	if np.IsCreating(network) {
+		response.Conflict(req.Request, resp, fmt.Errorf("The Network %s is being created", network.Name))
+		return
+	}
+
 	ret, err := kubeutils.GetNonCompletedPods(sp, bson.M{"networks.name": network.Name})
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
@@ -227,8 +504,12 @@ func deleteNetworkHandler(ctx *web.Context) {
 	}
 
 	if err := networkProvider.DeleteNetwork(sp); err != nil {
-		response.InternalServerError(req.Request, resp.ResponseWriter, err)
-		return
+		// the failed request may not create the network on all nodes, so the stale pending network is removed anyway
+		if !network.Pending {
+			writeNodesError(req, resp, err)
+			return
+		}
+		log.Printf("Failed to delete the stale pending network %s from the nodes: %v", network.Name, err)
 	}
 
 	if err := session.Remove(entity.NetworkCollectionName, "_id", bson.ObjectIdHex(id)); err != nil {
@@ -241,9 +522,56 @@ func deleteNetworkHandler(ctx *web.Context) {
 			return
 		}
 	}
+	session.Remove(entity.NetworkStatusCollectionName, "_id", bson.ObjectIdHex(id))
+	ipam.ReleaseNetwork(session, bson.ObjectIdHex(id))
 
 	resp.WriteEntity(response.ActionResponse{
 		Error:   false,
 		Message: "Delete success",
 	})
 }
+
+// writeNodesError will write the per-node error report when the network fails on the nodes
+func writeNodesError(req *restful.Request, resp *restful.Response, err error) {
+	nodesErr, ok := err.(*np.NodesError)
+	if !ok {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	if nodesErr.Invalid {
+		status = http.StatusBadRequest
+	}
+	resp.WriteHeaderAndEntity(status, response.NodesErrorResponse{
+		Error:   true,
+		Message: nodesErr.Error(),
+		Nodes:   nodesErr.Nodes,
+	})
+}
+
+// findOwnedNetwork will find the network of the path and check its owner, the error response is written if it fails
+func findOwnedNetwork(session *mongo.Session, req *restful.Request, resp *restful.Response) (entity.Network, bool) {
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid network ID: %s", id))
+		return entity.Network{}, false
+	}
+
+	network := entity.Network{}
+	if err := session.FindOne(entity.NetworkCollectionName, bson.M{"_id": bson.ObjectIdHex(id)}, &network); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		}
+		return entity.Network{}, false
+	}
+
+	if !isOwner(req, network.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the network is not owned by the user"))
+		return entity.Network{}, false
+	}
+	return network, true
+}
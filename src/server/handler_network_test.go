@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -12,16 +13,21 @@ import (
 	restful "github.com/emicklei/go-restful"
 
 	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	np "github.com/hwchiu/vortex/src/networkprovider"
 	"github.com/hwchiu/vortex/src/serviceprovider"
 	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func init() {
@@ -149,6 +155,25 @@ func (suite *NetworkTestSuite) TestCreateNetworkFail() {
 				},
 			},
 			http.StatusBadRequest},
+		{"InvalidSubnets",
+			entity.Network{
+				OwnerID:    bson.NewObjectId(),
+				Type:       entity.FakeNetworkType,
+				IsDPDKPort: true,
+				Name:       namesgenerator.GetRandomName(0),
+				VlanTags:   []int32{},
+				BridgeName: namesgenerator.GetRandomName(0),
+				Nodes: []entity.Node{
+					entity.Node{
+						Name:          namesgenerator.GetRandomName(0),
+						PhyInterfaces: []entity.PhyInterface{},
+					},
+				},
+				Subnets: []entity.Subnet{
+					{CIDR: "10.0.0.0/24", Gateway: "10.0.1.1"},
+				},
+			},
+			http.StatusBadRequest},
 	}
 
 	for _, tc := range testCases {
@@ -171,6 +196,128 @@ func (suite *NetworkTestSuite) TestCreateNetworkFail() {
 
 }
 
+func (suite *NetworkTestSuite) TestCreateNetworkPending() {
+	// the network which is being created by another request
+	pending := entity.Network{
+		ID:         bson.NewObjectId(),
+		OwnerID:    bson.NewObjectId(),
+		Type:       entity.FakeNetworkType,
+		IsDPDKPort: true, //for fake network, true means success,
+		Name:       namesgenerator.GetRandomName(0),
+		VlanTags:   []int32{},
+		BridgeName: namesgenerator.GetRandomName(0),
+		Nodes:      []entity.Node{},
+		Pending:    true,
+		PendingAt:  timeutils.Now(),
+	}
+	err := suite.session.Insert(entity.NetworkCollectionName, &pending)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.NetworkCollectionName, "_id", pending.ID)
+
+	// the pending network is not found until it's created
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/networks/"+pending.ID.Hex(), nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusNotFound, httpWriter)
+
+	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/networks?page_size=1000", nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	networks := []entity.Network{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &networks)
+	suite.NoError(err)
+	for _, listed := range networks {
+		suite.NotEqual(pending.ID, listed.ID)
+	}
+
+	network := pending
+	network.ID = ""
+	network.Pending = false
+	network.PendingAt = nil
+	bodyBytes, err := json.Marshal(network)
+	suite.NoError(err)
+	httpRequest, err = http.NewRequest("POST", "http://localhost:7890/v1/networks", strings.NewReader(string(bodyBytes)))
+	suite.NoError(err)
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusConflict, httpWriter)
+
+	// the pending network can not be deleted
+	httpRequest, err = http.NewRequest("DELETE", "http://localhost:7890/v1/networks/"+pending.ID.Hex(), nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusConflict, httpWriter)
+
+	count, err := suite.session.Count(entity.NetworkCollectionName, bson.M{"name": pending.Name})
+	suite.NoError(err)
+	suite.Equal(1, count)
+
+	// the name is released when the network fails on the nodes
+	network.Name = namesgenerator.GetRandomName(0)
+	network.IsDPDKPort = false
+	bodyBytes, err = json.Marshal(network)
+	suite.NoError(err)
+	httpRequest, err = http.NewRequest("POST", "http://localhost:7890/v1/networks", strings.NewReader(string(bodyBytes)))
+	suite.NoError(err)
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusInternalServerError, httpWriter)
+
+	count, err = suite.session.Count(entity.NetworkCollectionName, bson.M{"name": network.Name})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
+
+func (suite *NetworkTestSuite) TestDeleteStalePendingNetwork() {
+	// the network is left pending by a crashed request
+	pendingAt := time.Now().Add(-np.PendingTimeout - time.Minute)
+	stale := entity.Network{
+		ID:         bson.NewObjectId(),
+		OwnerID:    bson.NewObjectId(),
+		Type:       entity.FakeNetworkType,
+		IsDPDKPort: false, //the fake network fails on the nodes
+		Name:       namesgenerator.GetRandomName(0),
+		VlanTags:   []int32{},
+		BridgeName: namesgenerator.GetRandomName(0),
+		Nodes:      []entity.Node{},
+		Pending:    true,
+		PendingAt:  &pendingAt,
+	}
+	err := suite.session.Insert(entity.NetworkCollectionName, &stale)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.NetworkCollectionName, "_id", stale.ID)
+
+	// the stale pending network is found, so it can be deleted
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/networks/"+stale.ID.Hex(), nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	httpRequest, err = http.NewRequest("DELETE", "http://localhost:7890/v1/networks/"+stale.ID.Hex(), nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	count, err := suite.session.Count(entity.NetworkCollectionName, bson.M{"_id": stale.ID})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
+
 func (suite *NetworkTestSuite) TestDeleteNetwork() {
 	tName := namesgenerator.GetRandomName(0)
 	network := entity.Network{
@@ -305,6 +452,182 @@ func (suite *NetworkTestSuite) TestDeleteNetworkFail() {
 	}
 }
 
+func (suite *NetworkTestSuite) TestUpdateNetwork() {
+	tName := namesgenerator.GetRandomName(0)
+	network := entity.Network{
+		ID:         bson.NewObjectId(),
+		OwnerID:    bson.NewObjectId(),
+		IsDPDKPort: true, //for fake network, true means success,
+		Name:       tName,
+		VlanTags:   []int32{100},
+		Type:       entity.FakeNetworkType,
+		BridgeName: namesgenerator.GetRandomName(0),
+		Nodes: []entity.Node{
+			entity.Node{
+				Name:          namesgenerator.GetRandomName(0),
+				PhyInterfaces: []entity.PhyInterface{},
+			},
+		},
+	}
+
+	//Create data into mongo manually
+	suite.session.C(entity.NetworkCollectionName).Insert(network)
+	defer suite.session.Remove(entity.NetworkCollectionName, "name", tName)
+
+	update := entity.NetworkUpdate{
+		VlanTags: []int32{100, 200},
+		Nodes: []entity.Node{
+			entity.Node{
+				Name:          namesgenerator.GetRandomName(0),
+				PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}},
+			},
+		},
+	}
+	bodyBytes, err := json.MarshalIndent(update, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest("PUT", "http://localhost:7890/v1/networks/"+network.ID.Hex(), bodyReader)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	retNetwork := entity.Network{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &retNetwork)
+	suite.NoError(err)
+	suite.Equal(update.VlanTags, retNetwork.VlanTags)
+	suite.Equal(update.Nodes, retNetwork.Nodes)
+
+	err = suite.session.FindOne(entity.NetworkCollectionName, bson.M{"_id": network.ID}, &retNetwork)
+	suite.NoError(err)
+	suite.Equal(tName, retNetwork.Name)
+	suite.Equal(update.VlanTags, retNetwork.VlanTags)
+	suite.Equal(update.Nodes, retNetwork.Nodes)
+}
+
+func (suite *NetworkTestSuite) TestUpdateNetworkFail() {
+	nodeName := namesgenerator.GetRandomName(0)
+	newNetwork := func(isDPDKPort bool) entity.Network {
+		return entity.Network{
+			ID:         bson.NewObjectId(),
+			OwnerID:    bson.NewObjectId(),
+			IsDPDKPort: isDPDKPort,
+			Type:       entity.FakeNetworkType,
+			Name:       namesgenerator.GetRandomName(0),
+			VlanTags:   []int32{100, 200},
+			BridgeName: namesgenerator.GetRandomName(0),
+			Nodes: []entity.Node{
+				entity.Node{
+					Name:          nodeName,
+					PhyInterfaces: []entity.PhyInterface{},
+				},
+			},
+		}
+	}
+	validUpdate := entity.NetworkUpdate{
+		VlanTags: []int32{100, 200},
+		Nodes: []entity.Node{
+			entity.Node{
+				Name:          nodeName,
+				PhyInterfaces: []entity.PhyInterface{},
+			},
+		},
+	}
+
+	testCases := []struct {
+		cases     string
+		id        string
+		network   entity.Network
+		update    entity.NetworkUpdate
+		errorCode int
+	}{
+		{"InvalidID", "1234", newNetwork(true), validUpdate, http.StatusBadRequest},
+		{"NotFound", bson.NewObjectId().Hex(), newNetwork(true), validUpdate, http.StatusNotFound},
+		{"InvalidVlanTag", "", newNetwork(true), entity.NetworkUpdate{
+			VlanTags: []int32{5000},
+			Nodes:    validUpdate.Nodes,
+		}, http.StatusBadRequest},
+		{"NetworkUpdateFail", "", newNetwork(false), validUpdate, http.StatusInternalServerError},
+		{"PodStillUseVlan", "", newNetwork(true), entity.NetworkUpdate{
+			VlanTags: []int32{200},
+			Nodes:    validUpdate.Nodes,
+		}, http.StatusMethodNotAllowed},
+		{"PodStillRunOnNode", "", newNetwork(true), entity.NetworkUpdate{
+			VlanTags: []int32{},
+			Nodes: []entity.Node{
+				entity.Node{
+					Name:          namesgenerator.GetRandomName(0),
+					PhyInterfaces: []entity.PhyInterface{},
+				},
+			},
+		}, http.StatusMethodNotAllowed},
+	}
+
+	//Create the Pods using the networks.
+	vlanTag := int32(100)
+	for _, tc := range testCases[4:] {
+		podName := namesgenerator.GetRandomName(0)
+		pod := entity.Pod{
+			ID:        bson.NewObjectId(),
+			Name:      podName,
+			Namespace: "default",
+			Networks: []entity.PodNetwork{
+				{
+					Name:    tc.network.Name,
+					VlanTag: &vlanTag,
+				},
+			},
+		}
+		suite.session.Insert(entity.PodCollectionName, pod)
+		defer suite.session.Remove(entity.PodCollectionName, "_id", pod.ID)
+
+		k8sPod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: podName,
+			},
+			Spec: corev1.PodSpec{
+				NodeName: nodeName,
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+			},
+		}
+		suite.sp.KubeCtl.CreatePod(&k8sPod, "default")
+		defer suite.sp.KubeCtl.DeletePod(podName, "default")
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.cases, func(t *testing.T) {
+			suite.session.C(entity.NetworkCollectionName).Insert(tc.network)
+			defer suite.session.Remove(entity.NetworkCollectionName, "name", tc.network.Name)
+
+			id := tc.id
+			if id == "" {
+				id = tc.network.ID.Hex()
+			}
+
+			bodyBytes, err := json.MarshalIndent(tc.update, "", "  ")
+			suite.NoError(err)
+
+			bodyReader := strings.NewReader(string(bodyBytes))
+			httpRequest, err := http.NewRequest("PUT", "http://localhost:7890/v1/networks/"+id, bodyReader)
+			suite.NoError(err)
+
+			httpRequest.Header.Add("Content-Type", "application/json")
+			httpRequest.Header.Add("Authorization", suite.JWTBearer)
+
+			httpWriter := httptest.NewRecorder()
+			suite.wc.Dispatch(httpWriter, httpRequest)
+			assertResponseCode(suite.T(), tc.errorCode, httpWriter)
+		})
+	}
+}
+
 //For Get/List, we only return mongo document
 func (suite *NetworkTestSuite) TestGetNetwork() {
 	tName := namesgenerator.GetRandomName(0)
@@ -476,3 +799,185 @@ func (suite *NetworkTestSuite) TestListNetworkWithInvalidPage() {
 	suite.wc.Dispatch(httpWriter, httpRequest)
 	assertResponseCode(suite.T(), http.StatusInternalServerError, httpWriter)
 }
+
+func (suite *NetworkTestSuite) TestGetNetworkDrift() {
+	tName := namesgenerator.GetRandomName(0)
+	network := entity.Network{
+		ID:         bson.NewObjectId(),
+		OwnerID:    bson.NewObjectId(),
+		Name:       tName,
+		VlanTags:   []int32{},
+		Type:       entity.OVSKernelspaceNetworkType,
+		BridgeName: "system-" + tName,
+		Nodes: []entity.Node{
+			entity.Node{
+				Name:          "node-a",
+				PhyInterfaces: []entity.PhyInterface{{Name: "eth1"}},
+			},
+		},
+	}
+	suite.session.C(entity.NetworkCollectionName).Insert(network)
+	defer suite.session.Remove(entity.NetworkCollectionName, "name", tName)
+
+	// the status saved by the reconciler
+	status := entity.NetworkStatus{
+		ID:      network.ID,
+		Drifted: true,
+		Nodes: []entity.NodeNetworkStatus{
+			{Name: "node-a", BridgeExists: true, MissingPorts: []string{"eth1"}, Drifted: true},
+		},
+		CheckedAt: &time.Time{},
+	}
+	suite.session.C(entity.NetworkStatusCollectionName).Insert(status)
+	defer suite.session.Remove(entity.NetworkStatusCollectionName, "_id", network.ID)
+
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/networks/"+network.ID.Hex()+"/status", nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	retStatus := entity.NetworkStatus{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &retStatus)
+	suite.NoError(err)
+	suite.True(retStatus.Drifted)
+	suite.Equal(status.Nodes, retStatus.Nodes)
+}
+
+func (suite *NetworkTestSuite) TestGetNetworkDriftFail() {
+	tName := namesgenerator.GetRandomName(0)
+	network := entity.Network{
+		ID:       bson.NewObjectId(),
+		OwnerID:  bson.NewObjectId(),
+		Name:     tName,
+		VlanTags: []int32{},
+		Type:     entity.FakeNetworkType,
+		Nodes: []entity.Node{
+			entity.Node{
+				Name:          namesgenerator.GetRandomName(0),
+				PhyInterfaces: []entity.PhyInterface{},
+			},
+		},
+	}
+	suite.session.C(entity.NetworkCollectionName).Insert(network)
+	defer suite.session.Remove(entity.NetworkCollectionName, "name", tName)
+
+	testCases := []struct {
+		cases     string
+		path      string
+		errorCode int
+	}{
+		{"InvalidID", "invalid/status", http.StatusBadRequest},
+		{"NotFound", bson.NewObjectId().Hex() + "/status", http.StatusNotFound},
+		{"InvalidRefresh", network.ID.Hex() + "/status?refresh=invalid", http.StatusBadRequest},
+		{"UncheckableType", network.ID.Hex() + "/status", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/networks/"+tc.path, nil)
+		suite.NoError(err)
+		httpRequest.Header.Add("Authorization", suite.JWTBearer)
+
+		httpWriter := httptest.NewRecorder()
+		suite.wc.Dispatch(httpWriter, httpRequest)
+		suite.Equal(tc.errorCode, httpWriter.Code, tc.cases)
+	}
+}
+
+func TestWriteNodesError(t *testing.T) {
+	testCases := []struct {
+		cases  string
+		err    error
+		status int
+		nodes  []entity.NetworkNodeStatus
+	}{
+		{"invalid", &np.NodesError{Invalid: true, Nodes: []entity.NetworkNodeStatus{{Name: "node-a", Status: entity.NodeFailed, Error: "the NIC is not found"}}}, http.StatusBadRequest, []entity.NetworkNodeStatus{{Name: "node-a", Status: entity.NodeFailed, Error: "the NIC is not found"}}},
+		{"failed", &np.NodesError{Nodes: []entity.NetworkNodeStatus{{Name: "node-b", Status: entity.NodeFailed, Error: "connection refused"}}}, http.StatusInternalServerError, []entity.NetworkNodeStatus{{Name: "node-b", Status: entity.NodeFailed, Error: "connection refused"}}},
+		{"other", fmt.Errorf("unknown error"), http.StatusInternalServerError, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/networks", nil)
+			assert.NoError(t, err)
+			httpWriter := httptest.NewRecorder()
+
+			writeNodesError(restful.NewRequest(httpRequest), restful.NewResponse(httpWriter), tc.err)
+			assertResponseCode(t, tc.status, httpWriter)
+
+			report := response.NodesErrorResponse{}
+			assert.NoError(t, json.Unmarshal(httpWriter.Body.Bytes(), &report))
+			assert.True(t, report.Error)
+			assert.Equal(t, tc.err.Error(), report.Message)
+			assert.Equal(t, tc.nodes, report.Nodes)
+		})
+	}
+}
+
+func (suite *NetworkTestSuite) TestGetNetworkPortsFail() {
+	tName := namesgenerator.GetRandomName(0)
+	network := entity.Network{
+		ID:       bson.NewObjectId(),
+		OwnerID:  bson.NewObjectId(),
+		Name:     tName,
+		VlanTags: []int32{},
+		Type:     entity.FakeNetworkType,
+		Nodes:    []entity.Node{},
+	}
+	suite.session.C(entity.NetworkCollectionName).Insert(network)
+	defer suite.session.Remove(entity.NetworkCollectionName, "name", tName)
+
+	testCases := []struct {
+		cases     string
+		path      string
+		errorCode int
+	}{
+		{"InvalidID", "invalid/ports", http.StatusBadRequest},
+		{"NotFound", bson.NewObjectId().Hex() + "/ports", http.StatusNotFound},
+		{"UnsupportedType", network.ID.Hex() + "/ports", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/networks/"+tc.path, nil)
+		suite.NoError(err)
+		httpRequest.Header.Add("Authorization", suite.JWTBearer)
+
+		httpWriter := httptest.NewRecorder()
+		suite.wc.Dispatch(httpWriter, httpRequest)
+		suite.Equal(tc.errorCode, httpWriter.Code, tc.cases)
+	}
+}
+
+func (suite *NetworkTestSuite) TestGetNetworkPortsWithUnreachableNodes() {
+	tName := namesgenerator.GetRandomName(0)
+	network := entity.Network{
+		ID:         bson.NewObjectId(),
+		OwnerID:    bson.NewObjectId(),
+		Name:       tName,
+		VlanTags:   []int32{},
+		Type:       entity.OVSKernelspaceNetworkType,
+		BridgeName: "system-" + tName,
+		Nodes: []entity.Node{
+			{Name: namesgenerator.GetRandomName(0)},
+		},
+	}
+	suite.session.C(entity.NetworkCollectionName).Insert(network)
+	defer suite.session.Remove(entity.NetworkCollectionName, "name", tName)
+
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/networks/"+network.ID.Hex()+"/ports", nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	infos := entity.NetworkPortInfos{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &infos)
+	suite.NoError(err)
+	suite.Len(infos.Ports, 0)
+	suite.Len(infos.Nodes, 1)
+	suite.Equal(entity.NodeFailed, infos.Nodes[0].Status)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/web"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// oidcStateCookieName is the cookie which binds the login state to the browser starting the login
+const oidcStateCookieName = "vortex_oidc_state"
+
+// oidcLoginHandler redirects the user to the OpenID Connect provider to sign in
+func oidcLoginHandler(ctx *web.Context) {
+	req, resp := ctx.Request, ctx.Response
+
+	provider := backend.GetOIDCProvider()
+	if provider == nil {
+		response.NotFound(req.Request, resp.ResponseWriter, fmt.Errorf("The OIDC login is not enabled"))
+		return
+	}
+
+	authURL, ok := startOIDCLogin(ctx, provider, "")
+	if !ok {
+		return
+	}
+	http.Redirect(resp.ResponseWriter, req.Request, authURL, http.StatusFound)
+}
+
+// oidcLinkHandler returns the authorization URL for the signed-in user to link the OpenID Connect account
+// The account is linked when the provider redirects back to the callback
+func oidcLinkHandler(ctx *web.Context) {
+	req, resp := ctx.Request, ctx.Response
+
+	provider := backend.GetOIDCProvider()
+	if provider == nil {
+		response.NotFound(req.Request, resp.ResponseWriter, fmt.Errorf("The OIDC login is not enabled"))
+		return
+	}
+
+	userID, _ := req.Attribute("UserID").(string)
+	if !bson.IsObjectIdHex(userID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: only the users can link the OIDC account"))
+		return
+	}
+
+	authURL, ok := startOIDCLogin(ctx, provider, bson.ObjectIdHex(userID))
+	if !ok {
+		return
+	}
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: authURL,
+	})
+}
+
+// startOIDCLogin will create the state of the login, bind it to the browser and return the authorization URL
+// It writes the error response and returns false when failed
+func startOIDCLogin(ctx *web.Context, provider *backend.OIDCProvider, userID bson.ObjectId) (string, bool) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	state, err := backend.CreateOIDCState(session, userID)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return "", false
+	}
+
+	authURL, err := provider.AuthCodeURL(state.State, state.Nonce)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return "", false
+	}
+	http.SetCookie(resp.ResponseWriter, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    state.State,
+		Path:     "/v1/users/oidc",
+		Expires:  *state.ExpiresAt,
+		HttpOnly: true,
+		Secure:   req.Request.TLS != nil,
+	})
+	return authURL, true
+}
+
+// oidcCallbackHandler exchanges the authorization code for the ID token and signs in the user
+func oidcCallbackHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	provider := backend.GetOIDCProvider()
+	if provider == nil {
+		response.NotFound(req.Request, resp.ResponseWriter, fmt.Errorf("The OIDC login is not enabled"))
+		return
+	}
+
+	if reason := req.QueryParameter("error"); reason != "" {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: %s %s", reason, req.QueryParameter("error_description")))
+		return
+	}
+
+	code, state := req.QueryParameter("code"), req.QueryParameter("state")
+	if code == "" || state == "" {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("The code and state are required"))
+		return
+	}
+
+	// the callback must come from the browser which started the login, otherwise the attacker can sign in the victim as the attacker
+	cookie, err := req.Request.Cookie(oidcStateCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: The login state does not belong to this browser"))
+		return
+	}
+	http.SetCookie(resp.ResponseWriter, &http.Cookie{
+		Name:   oidcStateCookieName,
+		Path:   "/v1/users/oidc",
+		MaxAge: -1,
+	})
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	oidcState, err := backend.ConsumeOIDCState(session, state)
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: The login state is invalid or expired"))
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	rawIDToken, err := provider.Exchange(code)
+	if err != nil {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: %v", err))
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(rawIDToken, oidcState.Nonce)
+	if err != nil {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: %v", err))
+		return
+	}
+
+	var user entity.User
+	if oidcState.UserID != "" {
+		user, err = backend.LinkOIDCUser(session, oidcState.UserID, claims)
+	} else {
+		user, err = backend.ProvisionOIDCUser(session, claims, provider.Config.DefaultRole)
+	}
+	if err != nil {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: %v", err))
+		return
+	}
+
+	tokenResponse, err := newSessionTokenResponse(session, user)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	resp.WriteEntity(tokenResponse)
+}
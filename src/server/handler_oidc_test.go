@@ -0,0 +1,241 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/server/backend/oidctest"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type OIDCTestSuite struct {
+	suite.Suite
+	sp      *serviceprovider.Container
+	wc      *restful.Container
+	session *mongo.Session
+	server  *oidctest.Server
+}
+
+func (suite *OIDCTestSuite) SetupSuite() {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+
+	suite.sp = sp
+	// init session
+	suite.session = sp.Mongo.NewSession()
+	// init restful container
+	suite.wc = restful.NewContainer()
+
+	userService := newUserService(suite.sp)
+
+	suite.wc.Add(userService)
+
+	server, err := oidctest.NewServer("vortex", "secret")
+	suite.Require().NoError(err)
+	suite.server = server
+
+	err = backend.SetupOIDCProvider(&config.OIDCConfig{
+		Issuer:       server.URL,
+		ClientID:     "vortex",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:7890/v1/users/oidc/callback",
+		DefaultRole:  entity.GuestRole,
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *OIDCTestSuite) TearDownSuite() {
+	backend.SetupOIDCProvider(nil)
+	suite.server.Close()
+}
+
+func TestOIDCSuite(t *testing.T) {
+	suite.Run(t, new(OIDCTestSuite))
+}
+
+// login will start the login and return the state and nonce in the redirect URL
+func (suite *OIDCTestSuite) login() (string, string) {
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/users/oidc/login", nil)
+	suite.NoError(err)
+
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusFound, httpWriter)
+
+	location, err := url.Parse(httpWriter.Header().Get("Location"))
+	suite.NoError(err)
+	suite.Equal(suite.server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+
+	// the state is also kept in the cookie of the browser
+	state := location.Query().Get("state")
+	cookies := httpWriter.Result().Cookies()
+	suite.Require().Len(cookies, 1)
+	suite.Equal(oidcStateCookieName, cookies[0].Name)
+	suite.Equal(state, cookies[0].Value)
+	suite.True(cookies[0].HttpOnly)
+	return state, location.Query().Get("nonce")
+}
+
+// callback will call back from the browser which started the login
+func (suite *OIDCTestSuite) callback(code, state string) *httptest.ResponseRecorder {
+	return suite.callbackWithCookie(code, state, state)
+}
+
+func (suite *OIDCTestSuite) callbackWithCookie(code, state, cookie string) *httptest.ResponseRecorder {
+	query := url.Values{}
+	query.Set("code", code)
+	query.Set("state", state)
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/users/oidc/callback?"+query.Encode(), nil)
+	suite.NoError(err)
+	if cookie != "" {
+		httpRequest.AddCookie(&http.Cookie{Name: oidcStateCookieName, Value: cookie})
+	}
+
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter
+}
+
+func (suite *OIDCTestSuite) TestOIDCLogin() {
+	subject := bson.NewObjectId().Hex()
+	email := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", email)
+
+	state, nonce := suite.login()
+	code := suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter := suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	token := response.TokenResponse{}
+	err := json.Unmarshal(httpWriter.Body.Bytes(), &token)
+	suite.NoError(err)
+	suite.NotEmpty(token.RefreshToken)
+	suite.True(backend.VerifyToken([]byte(token.Message)))
+
+	user := entity.User{}
+	err = suite.session.FindOne(entity.UserCollectionName, bson.M{"loginCredential.username": email}, &user)
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, user.Role)
+	suite.Equal(subject, user.OIDCSubject)
+
+	// the state can only be used once
+	code = suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter = suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+
+	// the same user signs in again
+	state, nonce = suite.login()
+	code = suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter = suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	count, err := suite.session.Count(entity.UserCollectionName, bson.M{"oidcSubject": subject})
+	suite.NoError(err)
+	suite.Equal(1, count)
+}
+
+func (suite *OIDCTestSuite) TestOIDCLink() {
+	subject := bson.NewObjectId().Hex()
+	email := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	user := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: email,
+		},
+		DisplayName: "John Doe",
+		Role:        entity.UserRole,
+	}
+	err := suite.session.Insert(entity.UserCollectionName, &user)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	// the OIDC account with the same email can not sign in as the existing user
+	state, nonce := suite.login()
+	code := suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter := suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+
+	// the signed-in user starts linking the account
+	token, err := backend.GenerateToken(user.ID.Hex(), user)
+	suite.NoError(err)
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/oidc/link", nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", "Bearer "+token)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	link := response.ActionResponse{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &link)
+	suite.NoError(err)
+	location, err := url.Parse(link.Message)
+	suite.NoError(err)
+	state, nonce = location.Query().Get("state"), location.Query().Get("nonce")
+
+	code = suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter = suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	found, err := backend.FindUserByID(suite.session, user.ID)
+	suite.NoError(err)
+	suite.Equal(subject, found.OIDCSubject)
+
+	// the linked account signs in as the user
+	state, nonce = suite.login()
+	code = suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter = suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+}
+
+func (suite *OIDCTestSuite) TestFailedOIDCLogin() {
+	subject := bson.NewObjectId().Hex()
+	email := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", email)
+
+	// the nonce of the ID token must be the one of the login
+	state, _ := suite.login()
+	code := suite.server.IssueCode(suite.server.Claims(subject, email, "other"))
+	httpWriter := suite.callback(code, state)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+
+	// the unknown code is rejected by the provider
+	state, _ = suite.login()
+	httpWriter = suite.callback("unknown", state)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+
+	// the state of the login started by another browser is rejected
+	state, nonce := suite.login()
+	otherState, _ := suite.login()
+	code = suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter = suite.callbackWithCookie(code, state, "")
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+	code = suite.server.IssueCode(suite.server.Claims(subject, email, nonce))
+	httpWriter = suite.callbackWithCookie(code, state, otherState)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+
+	// the error from the provider
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/users/oidc/callback?error=access_denied", nil)
+	suite.NoError(err)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+
+	httpWriter = suite.callback("", "")
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+
+	count, err := suite.session.Count(entity.UserCollectionName, bson.M{"oidcSubject": subject})
+	suite.NoError(err)
+	suite.Equal(0, count)
+}
@@ -21,10 +21,11 @@ func init() {
 
 type OVSTestSuite struct {
 	suite.Suite
-	sp      *serviceprovider.Container
-	wc      *restful.Container
-	session *mongo.Session
-	storage entity.Storage
+	sp        *serviceprovider.Container
+	wc        *restful.Container
+	session   *mongo.Session
+	storage   entity.Storage
+	JWTBearer string
 }
 
 func (suite *OVSTestSuite) SetupSuite() {
@@ -38,6 +39,11 @@ func (suite *OVSTestSuite) SetupSuite() {
 	suite.wc = restful.NewContainer()
 	service := newOVSService(suite.sp)
 	suite.wc.Add(service)
+	suite.wc.Add(newUserService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
 }
 
 func (suite *OVSTestSuite) TearDownSuite() {
@@ -51,6 +57,7 @@ func (suite *OVSTestSuite) TestGetOVSPortStatsFail() {
 	//Empty data
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/ovs/portinfos", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -58,12 +65,14 @@ func (suite *OVSTestSuite) TestGetOVSPortStatsFail() {
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/ovs/portinfos?nodeName=11", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
 	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/ovs/portinfos?nodeName=11&&bridgeName=111", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
 	assertResponseCode(suite.T(), http.StatusInternalServerError, httpWriter)
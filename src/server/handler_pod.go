@@ -8,6 +8,7 @@ import (
 
 	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/ipam"
 	response "github.com/hwchiu/vortex/src/net/http"
 	"github.com/hwchiu/vortex/src/net/http/query"
 	"github.com/hwchiu/vortex/src/pod"
@@ -45,6 +46,14 @@ func createPodHandler(ctx *web.Context) {
 	})
 	defer session.Close()
 
+	if allowed, err := canUseNamespace(session, req, p.Namespace, entity.TeamMemberRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user can not use the namespace %s", p.Namespace))
+		return
+	}
+
 	// Check whether this name has been used
 	p.ID = bson.NewObjectId()
 	p.CreatedAt = timeutils.Now()
@@ -60,6 +69,8 @@ func createPodHandler(ctx *web.Context) {
 			response.Conflict(req.Request, resp.ResponseWriter, fmt.Errorf("Create setting has conflict: %v", err))
 		} else if errors.IsInvalid(err) {
 			response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Create setting is invalid: %v", err))
+		} else if ipam.IsConflict(err) {
+			response.Conflict(req.Request, resp.ResponseWriter, err)
 		} else {
 			response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		}
@@ -94,12 +105,18 @@ func deletePodHandler(ctx *web.Context) {
 		return
 	}
 
+	// the admins of the team which owns the namespace can delete the pods of the other members
+	if allowed, err := canAccessObject(session, req, p.OwnerID, p.Namespace, entity.TeamAdminRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the pod is not owned by the user"))
+		return
+	}
+
+	// the pod which is already gone in kubernetes is still removed
 	if err := pod.DeletePod(sp, &p); err != nil {
-		if errors.IsNotFound(err) {
-			response.NotFound(req.Request, resp.ResponseWriter, err)
-		} else {
-			response.InternalServerError(req.Request, resp.ResponseWriter, err)
-		}
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
 	}
 
@@ -144,7 +161,11 @@ func listPodHandler(ctx *web.Context) {
 	var c = session.C(entity.PodCollectionName)
 	var q *mgo.Query
 
-	selector := bson.M{}
+	selector, err := namespaceRoleSelector(session, req, entity.TeamViewerRole)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
 	q = c.Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
 
 	if err := q.All(&pods); err != nil {
@@ -163,7 +184,7 @@ func listPodHandler(ctx *web.Context) {
 		// find owner in user entity
 		pod.CreatedBy, _ = backend.FindUserByID(session, pod.OwnerID)
 	}
-	count, err := session.Count(entity.PodCollectionName, bson.M{})
+	count, err := session.Count(entity.PodCollectionName, selector)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
@@ -194,6 +215,15 @@ func getPodHandler(ctx *web.Context) {
 			return
 		}
 	}
+
+	// the members of the team which owns the namespace can read the pods of the namespace
+	if allowed, err := canAccessObject(session, req, pod.OwnerID, pod.Namespace, entity.TeamViewerRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the pod is not owned by the user"))
+		return
+	}
 	// find owner in user entity
 	pod.CreatedBy, _ = backend.FindUserByID(session, pod.OwnerID)
 	resp.WriteEntity(pod)
@@ -20,8 +20,9 @@ func init() {
 
 type PrometheusTestSuite struct {
 	suite.Suite
-	wc *restful.Container
-	sp *serviceprovider.Container
+	wc        *restful.Container
+	sp        *serviceprovider.Container
+	JWTBearer string
 }
 
 func (suite *PrometheusTestSuite) SetupSuite() {
@@ -33,6 +34,11 @@ func (suite *PrometheusTestSuite) SetupSuite() {
 	suite.sp = serviceprovider.New(cf)
 	service := newMonitoringService(sp)
 	suite.wc.Add(service)
+	suite.wc.Add(newUserService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
 }
 
 func (suite *PrometheusTestSuite) TearDownSuite() {}
@@ -44,6 +50,7 @@ func TestPrometheusSuite(t *testing.T) {
 func (suite *PrometheusTestSuite) TestListNodeMetrics() {
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/nodes/", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -57,6 +64,7 @@ func (suite *PrometheusTestSuite) TestGetNodeMetrics() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/nodes/"+nodeName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -70,6 +78,7 @@ func (suite *PrometheusTestSuite) TestListNodeNicsMetrics() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/nodes/"+nodeName+"/nics", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -79,6 +88,7 @@ func (suite *PrometheusTestSuite) TestListNodeNicsMetrics() {
 func (suite *PrometheusTestSuite) TestListPodMetrics() {
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/pods/", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -86,6 +96,7 @@ func (suite *PrometheusTestSuite) TestListPodMetrics() {
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/monitoring/pods?node=.*&namespace=.*&controller=.*", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -101,6 +112,7 @@ func (suite *PrometheusTestSuite) TestGetPodMetrics() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/pods/"+podName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -116,6 +128,7 @@ func (suite *PrometheusTestSuite) TestGetContainerMetrics() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/pods/"+podName+"/"+containerName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -125,6 +138,7 @@ func (suite *PrometheusTestSuite) TestGetContainerMetrics() {
 func (suite *PrometheusTestSuite) TestListServiceMetrics() {
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/services", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -132,6 +146,7 @@ func (suite *PrometheusTestSuite) TestListServiceMetrics() {
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/monitoring/services?namespace=.*", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -146,6 +161,7 @@ func (suite *PrometheusTestSuite) TestGetServiceMetrics() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/services/"+serviceName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -155,6 +171,7 @@ func (suite *PrometheusTestSuite) TestGetServiceMetrics() {
 func (suite *PrometheusTestSuite) TestListControllerMetrics() {
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/controllers/", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -162,6 +179,7 @@ func (suite *PrometheusTestSuite) TestListControllerMetrics() {
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/monitoring/controllers?namespace=.*", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -176,6 +194,7 @@ func (suite *PrometheusTestSuite) TestGetControllerMetrics() {
 
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/monitoring/controllers/"+deploymentName, nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -23,9 +23,10 @@ func init() {
 
 type RegistryTestSuite struct {
 	suite.Suite
-	sp      *serviceprovider.Container
-	wc      *restful.Container
-	session *mongo.Session
+	sp        *serviceprovider.Container
+	wc        *restful.Container
+	session   *mongo.Session
+	JWTBearer string
 }
 
 func (suite *RegistryTestSuite) SetupSuite() {
@@ -39,6 +40,11 @@ func (suite *RegistryTestSuite) SetupSuite() {
 	suite.wc = restful.NewContainer()
 	service := newRegistryService(suite.sp)
 	suite.wc.Add(service)
+	suite.wc.Add(newUserService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
 }
 
 func (suite *RegistryTestSuite) TearDownSuite() {}
@@ -59,6 +65,7 @@ func (suite *RegistryTestSuite) TestRegistryBasicAuth() {
 	bodyReader := strings.NewReader(string(bodyBytes))
 	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/registry/auth", bodyReader)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpWriter := httptest.NewRecorder()
@@ -45,6 +45,14 @@ func createServiceHandler(ctx *web.Context) {
 	})
 	defer session.Close()
 
+	if allowed, err := canUseNamespace(session, req, s.Namespace, entity.TeamMemberRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user can not use the namespace %s", s.Namespace))
+		return
+	}
+
 	// Check whether this name has been used
 	s.ID = bson.NewObjectId()
 	s.CreatedAt = timeutils.Now()
@@ -90,6 +98,15 @@ func deleteServiceHandler(ctx *web.Context) {
 		return
 	}
 
+	// the admins of the team which owns the namespace can delete the services of the other members
+	if allowed, err := canAccessObject(session, req, s.OwnerID, s.Namespace, entity.TeamAdminRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the service is not owned by the user"))
+		return
+	}
+
 	if err := service.DeleteService(sp, &s); err != nil {
 		if errors.IsNotFound(err) {
 			response.NotFound(req.Request, resp.ResponseWriter, err)
@@ -140,7 +157,11 @@ func listServiceHandler(ctx *web.Context) {
 	var c = session.C(entity.ServiceCollectionName)
 	var q *mgo.Query
 
-	selector := bson.M{}
+	selector, err := namespaceRoleSelector(session, req, entity.TeamViewerRole)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
 	q = c.Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
 
 	if err := q.All(&services); err != nil {
@@ -160,7 +181,7 @@ func listServiceHandler(ctx *web.Context) {
 		service.CreatedBy, _ = backend.FindUserByID(session, service.OwnerID)
 	}
 
-	count, err := session.Count(entity.ServiceCollectionName, bson.M{})
+	count, err := session.Count(entity.ServiceCollectionName, selector)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
@@ -192,6 +213,15 @@ func getServiceHandler(ctx *web.Context) {
 		}
 	}
 
+	// the members of the team which owns the namespace can read the services of the namespace
+	if allowed, err := canAccessObject(session, req, service.OwnerID, service.Namespace, entity.TeamViewerRole); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	} else if !allowed {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the service is not owned by the user"))
+		return
+	}
+
 	// find owner in user entity
 	service.CreatedBy, _ = backend.FindUserByID(session, service.OwnerID)
 	resp.WriteEntity(service)
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/hwchiu/vortex/src/entity"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/net/http/query"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/web"
+	"github.com/linkernetworks/utils/timeutils"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func createTeamHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	team := entity.Team{}
+	if err := req.ReadEntity(&team); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(team); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	session.C(entity.TeamCollectionName).EnsureIndex(mgo.Index{
+		Key:    []string{"name"},
+		Unique: true,
+	})
+	defer session.Close()
+
+	members := map[bson.ObjectId]bool{}
+	for _, member := range team.Members {
+		if members[member.UserID] {
+			response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Duplicate member: %s", member.UserID.Hex()))
+			return
+		}
+		members[member.UserID] = true
+
+		if _, err := backend.FindUserByID(session, member.UserID); err != nil {
+			switch err {
+			case mgo.ErrNotFound:
+				response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("User: %s not found", member.UserID.Hex()))
+				return
+			default:
+				response.InternalServerError(req.Request, resp.ResponseWriter, err)
+				return
+			}
+		}
+	}
+	if team.Members == nil {
+		team.Members = []entity.TeamMember{}
+	}
+
+	team.ID = bson.NewObjectId()
+	team.CreatedAt = timeutils.Now()
+	if err := session.Insert(entity.TeamCollectionName, &team); err != nil {
+		if mgo.IsDup(err) {
+			response.Conflict(req.Request, resp.ResponseWriter, fmt.Errorf("Team Name: %s already existed", team.Name))
+		} else {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		}
+		return
+	}
+	resp.WriteHeaderAndEntity(http.StatusCreated, team)
+}
+
+func deleteTeamHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid team ID: %s", id))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	// the namespaces can not be left without the team
+	count, err := session.Count(entity.NamespaceCollectionName, bson.M{"teamID": bson.ObjectIdHex(id)})
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	if count > 0 {
+		response.Conflict(req.Request, resp.ResponseWriter, fmt.Errorf("The team still owns %d namespaces", count))
+		return
+	}
+
+	if err := session.Remove(entity.TeamCollectionName, "_id", bson.ObjectIdHex(id)); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "Delete success",
+	})
+}
+
+func listTeamHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	var pageSize = 10
+	query := query.New(req.Request.URL.Query())
+
+	page, err := query.Int("page", 1)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	pageSize, err = query.Int("page_size", pageSize)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	// the root role lists all teams, the others list the teams they belong to
+	selector := bson.M{}
+	if role, _ := req.Attribute("Role").(string); role != entity.RootRole {
+		userID, ok := req.Attribute("UserID").(string)
+		if !ok || !bson.IsObjectIdHex(userID) {
+			response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID in the token"))
+			return
+		}
+		selector["members.userID"] = bson.ObjectIdHex(userID)
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	teams := []entity.Team{}
+	q := session.C(entity.TeamCollectionName).Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
+	if err := q.All(&teams); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	count, err := session.Count(entity.TeamCollectionName, selector)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	totalPages := int(math.Ceil(float64(count) / float64(pageSize)))
+	resp.AddHeader("X-Total-Count", strconv.Itoa(count))
+	resp.AddHeader("X-Total-Pages", strconv.Itoa(totalPages))
+	resp.WriteEntity(teams)
+}
+
+func getTeamHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid team ID: %s", id))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	team, err := backend.FindTeamByID(session, bson.ObjectIdHex(id))
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if !hasTeamRole(req, team, entity.TeamViewerRole) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user is not a member of the team"))
+		return
+	}
+	resp.WriteEntity(team)
+}
+
+func setTeamMemberHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid team ID: %s", id))
+		return
+	}
+
+	member := entity.TeamMember{}
+	if err := req.ReadEntity(&member); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(member); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	teamID := bson.ObjectIdHex(id)
+	team, err := backend.FindTeamByID(session, teamID)
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if !hasTeamRole(req, team, entity.TeamAdminRole) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user is not the admin of the team"))
+		return
+	}
+
+	if _, err := backend.FindUserByID(session, member.UserID); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("User: %s not found", member.UserID.Hex()))
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	// update the role of the existing member, or add the new member
+	c := session.C(entity.TeamCollectionName)
+	err = c.Update(
+		bson.M{"_id": teamID, "members.userID": member.UserID},
+		bson.M{"$set": bson.M{"members.$.role": member.Role}},
+	)
+	if err == mgo.ErrNotFound {
+		err = c.UpdateId(teamID, bson.M{"$push": bson.M{"members": member}})
+	}
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	team, err = backend.FindTeamByID(session, teamID)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	resp.WriteEntity(team)
+}
+
+func removeTeamMemberHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid team ID: %s", id))
+		return
+	}
+	userID := req.PathParameter("userID")
+	if !bson.IsObjectIdHex(userID) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", userID))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	teamID := bson.ObjectIdHex(id)
+	team, err := backend.FindTeamByID(session, teamID)
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if !hasTeamRole(req, team, entity.TeamAdminRole) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user is not the admin of the team"))
+		return
+	}
+
+	if err := session.C(entity.TeamCollectionName).Update(
+		bson.M{"_id": teamID, "members.userID": bson.ObjectIdHex(userID)},
+		bson.M{"$pull": bson.M{"members": bson.M{"userID": bson.ObjectIdHex(userID)}}},
+	); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, fmt.Errorf("User: %s is not a member of the team", userID))
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "Team Member Removed Success",
+	})
+}
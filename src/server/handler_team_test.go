@@ -0,0 +1,338 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/linkernetworks/mongo"
+	"github.com/moby/moby/pkg/namesgenerator"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type TeamTestSuite struct {
+	suite.Suite
+	sp        *serviceprovider.Container
+	wc        *restful.Container
+	session   *mongo.Session
+	JWTBearer string
+}
+
+func (suite *TeamTestSuite) SetupSuite() {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.NewForTesting(cf)
+
+	suite.sp = sp
+	// init session
+	suite.session = sp.Mongo.NewSession()
+	// init restful container
+	suite.wc = restful.NewContainer()
+	suite.wc.Add(newUserService(suite.sp))
+	suite.wc.Add(newTeamService(suite.sp))
+	suite.wc.Add(newNamespaceService(suite.sp))
+	suite.wc.Add(newPodService(suite.sp))
+
+	token, _ := loginGetToken(suite.wc)
+	suite.NotEmpty(token)
+	suite.JWTBearer = "Bearer " + token
+}
+
+func (suite *TeamTestSuite) TearDownSuite() {}
+
+func TestTeamSuite(t *testing.T) {
+	suite.Run(t, new(TeamTestSuite))
+}
+
+// insertUser inserts a user with the user role and returns the bearer of it
+func (suite *TeamTestSuite) insertUser() (entity.User, string) {
+	user := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: namesgenerator.GetRandomName(0) + "@linkernetworks.com",
+		},
+		DisplayName: "John Doe",
+		Role:        entity.UserRole,
+	}
+	err := suite.session.Insert(entity.UserCollectionName, &user)
+	suite.NoError(err)
+
+	token, err := backend.GenerateToken(user.ID.Hex(), user)
+	suite.NoError(err)
+	return user, "Bearer " + token
+}
+
+func (suite *TeamTestSuite) request(method, path, bearer string, body interface{}) *httptest.ResponseRecorder {
+	bodyBytes, err := json.MarshalIndent(body, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest(method, "http://localhost:7890"+path, bodyReader)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", bearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter
+}
+
+func (suite *TeamTestSuite) TestTeamMembers() {
+	admin, adminBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", admin.ID)
+	member, memberBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", member.ID)
+	stranger, strangerBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", stranger.ID)
+
+	// only root can create the team
+	team := entity.Team{
+		Name:    namesgenerator.GetRandomName(0),
+		Members: []entity.TeamMember{{UserID: admin.ID, Role: entity.TeamAdminRole}},
+	}
+	httpWriter := suite.request("POST", "/v1/teams", adminBearer, team)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.request("POST", "/v1/teams", suite.JWTBearer, team)
+	assertResponseCode(suite.T(), http.StatusCreated, httpWriter)
+	err := json.Unmarshal(httpWriter.Body.Bytes(), &team)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.TeamCollectionName, "_id", team.ID)
+
+	httpWriter = suite.request("POST", "/v1/teams", suite.JWTBearer, team)
+	assertResponseCode(suite.T(), http.StatusConflict, httpWriter)
+
+	// the admin of the team adds the member
+	path := "/v1/teams/" + team.ID.Hex()
+	httpWriter = suite.request("PUT", path+"/members", adminBearer, entity.TeamMember{UserID: member.ID, Role: entity.TeamViewerRole})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	httpWriter = suite.request("PUT", path+"/members", adminBearer, entity.TeamMember{UserID: member.ID, Role: entity.TeamMemberRole})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	retTeam := entity.Team{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &retTeam)
+	suite.NoError(err)
+	suite.Len(retTeam.Members, 2)
+	suite.Equal(entity.TeamMemberRole, backend.TeamMemberRole(retTeam, member.ID))
+
+	httpWriter = suite.request("PUT", path+"/members", adminBearer, entity.TeamMember{UserID: member.ID, Role: "owner"})
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+	httpWriter = suite.request("PUT", path+"/members", adminBearer, entity.TeamMember{UserID: bson.NewObjectId(), Role: entity.TeamViewerRole})
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+
+	// the member can read the team but not manage it
+	httpWriter = suite.request("GET", path, memberBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	httpWriter = suite.request("PUT", path+"/members", memberBearer, entity.TeamMember{UserID: member.ID, Role: entity.TeamAdminRole})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// the others can not see the team
+	httpWriter = suite.request("GET", path, strangerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	teams := []entity.Team{}
+	httpWriter = suite.request("GET", "/v1/teams", memberBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &teams)
+	suite.NoError(err)
+	suite.Len(teams, 1)
+	httpWriter = suite.request("GET", "/v1/teams", strangerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &teams)
+	suite.NoError(err)
+	suite.Len(teams, 0)
+
+	httpWriter = suite.request("DELETE", path+"/members/"+member.ID.Hex(), adminBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	httpWriter = suite.request("DELETE", path+"/members/"+member.ID.Hex(), adminBearer, nil)
+	assertResponseCode(suite.T(), http.StatusNotFound, httpWriter)
+	httpWriter = suite.request("GET", path, memberBearer, nil)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.request("DELETE", path, suite.JWTBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+}
+
+func (suite *TeamTestSuite) TestTeamNamespace() {
+	admin, adminBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", admin.ID)
+	viewer, viewerBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", viewer.ID)
+	stranger, strangerBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", stranger.ID)
+
+	team := entity.Team{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Members: []entity.TeamMember{
+			{UserID: admin.ID, Role: entity.TeamAdminRole},
+			{UserID: viewer.ID, Role: entity.TeamViewerRole},
+		},
+	}
+	err := suite.session.Insert(entity.TeamCollectionName, &team)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.TeamCollectionName, "_id", team.ID)
+
+	// only the admins of the team can add the namespace to the team
+	namespace := entity.Namespace{
+		Name:   "team-" + bson.NewObjectId().Hex(),
+		TeamID: team.ID,
+	}
+	httpWriter := suite.request("POST", "/v1/namespaces", viewerBearer, namespace)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+	httpWriter = suite.request("POST", "/v1/namespaces", adminBearer, entity.Namespace{Name: namespace.Name, TeamID: bson.NewObjectId()})
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+
+	namespace.ID = bson.NewObjectId()
+	namespace.OwnerID = admin.ID
+	err = suite.session.Insert(entity.NamespaceCollectionName, &namespace)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.NamespaceCollectionName, "_id", namespace.ID)
+
+	// the team can not be deleted with the namespaces
+	httpWriter = suite.request("DELETE", "/v1/teams/"+team.ID.Hex(), suite.JWTBearer, nil)
+	assertResponseCode(suite.T(), http.StatusConflict, httpWriter)
+
+	// the viewer reads the namespace of the team
+	httpWriter = suite.request("GET", "/v1/namespaces/"+namespace.ID.Hex(), viewerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	httpWriter = suite.request("GET", "/v1/namespaces/"+namespace.ID.Hex(), strangerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	namespaces := []entity.Namespace{}
+	httpWriter = suite.request("GET", "/v1/namespaces", viewerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &namespaces)
+	suite.NoError(err)
+	suite.Len(namespaces, 1)
+	suite.Equal(namespace.Name, namespaces[0].Name)
+
+	// the viewer and the others can not create the pods in the namespace of the team
+	pod := entity.Pod{
+		Name:          "pod-" + bson.NewObjectId().Hex(),
+		Namespace:     namespace.Name,
+		Labels:        map[string]string{},
+		EnvVars:       map[string]string{},
+		Containers:    []entity.Container{{Name: "busybox", Image: "busybox", Command: []string{"sleep", "3600"}}},
+		Volumes:       []entity.PodVolume{},
+		Networks:      []entity.PodNetwork{},
+		RestartPolicy: "Never",
+		NetworkType:   entity.PodHostNetwork,
+		NodeAffinity:  []string{},
+	}
+	httpWriter = suite.request("POST", "/v1/pods", viewerBearer, pod)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+	httpWriter = suite.request("POST", "/v1/pods", strangerBearer, pod)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// the namespaces not created by vortex can only be used by all users when they are shared
+	pod.Namespace = "default"
+	httpWriter = suite.request("POST", "/v1/pods", strangerBearer, pod)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	backend.SetupSharedNamespaces(&config.TeamConfig{SharedNamespaces: []string{"default"}})
+	defer backend.SetupSharedNamespaces(nil)
+	httpWriter = suite.request("POST", "/v1/pods", strangerBearer, pod)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	defer suite.session.Remove(entity.PodCollectionName, "name", pod.Name)
+	defer suite.sp.KubeCtl.DeletePod(pod.Name, pod.Namespace)
+}
+
+func (suite *TeamTestSuite) TestTeamNamespaceObjects() {
+	admin, adminBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", admin.ID)
+	member, memberBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", member.ID)
+	viewer, viewerBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", viewer.ID)
+	stranger, strangerBearer := suite.insertUser()
+	defer suite.session.Remove(entity.UserCollectionName, "_id", stranger.ID)
+
+	team := entity.Team{
+		ID:   bson.NewObjectId(),
+		Name: namesgenerator.GetRandomName(0),
+		Members: []entity.TeamMember{
+			{UserID: admin.ID, Role: entity.TeamAdminRole},
+			{UserID: member.ID, Role: entity.TeamMemberRole},
+			{UserID: viewer.ID, Role: entity.TeamViewerRole},
+		},
+	}
+	err := suite.session.Insert(entity.TeamCollectionName, &team)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.TeamCollectionName, "_id", team.ID)
+
+	namespace := entity.Namespace{
+		ID:      bson.NewObjectId(),
+		Name:    "team-" + bson.NewObjectId().Hex(),
+		OwnerID: admin.ID,
+		TeamID:  team.ID,
+	}
+	err = suite.session.Insert(entity.NamespaceCollectionName, &namespace)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.NamespaceCollectionName, "_id", namespace.ID)
+
+	pod := entity.Pod{
+		Name:          "pod-" + bson.NewObjectId().Hex(),
+		Namespace:     namespace.Name,
+		Labels:        map[string]string{},
+		EnvVars:       map[string]string{},
+		Containers:    []entity.Container{{Name: "busybox", Image: "busybox", Command: []string{"sleep", "3600"}}},
+		Volumes:       []entity.PodVolume{},
+		Networks:      []entity.PodNetwork{},
+		RestartPolicy: "Never",
+		NetworkType:   entity.PodHostNetwork,
+		NodeAffinity:  []string{},
+	}
+	httpWriter := suite.request("POST", "/v1/pods", memberBearer, pod)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	defer suite.session.Remove(entity.PodCollectionName, "name", pod.Name)
+	defer suite.sp.KubeCtl.DeletePod(pod.Name, pod.Namespace)
+	err = suite.session.FindOne(entity.PodCollectionName, bson.M{"name": pod.Name}, &pod)
+	suite.NoError(err)
+
+	// the viewers of the team read the pods of the other members in the namespace
+	httpWriter = suite.request("GET", "/v1/pods/"+pod.ID.Hex(), viewerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	httpWriter = suite.request("GET", "/v1/pods/"+pod.ID.Hex(), strangerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	pods := []entity.Pod{}
+	httpWriter = suite.request("GET", "/v1/pods", viewerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &pods)
+	suite.NoError(err)
+	suite.Len(pods, 1)
+	suite.Equal(pod.ID, pods[0].ID)
+
+	httpWriter = suite.request("GET", "/v1/pods", strangerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &pods)
+	suite.NoError(err)
+	suite.Len(pods, 0)
+
+	// the pods in the namespaces not created by vortex are only read by their owners
+	strangerPod := entity.Pod{
+		ID:        bson.NewObjectId(),
+		OwnerID:   stranger.ID,
+		Name:      "pod-" + bson.NewObjectId().Hex(),
+		Namespace: "default",
+	}
+	err = suite.session.Insert(entity.PodCollectionName, &strangerPod)
+	suite.NoError(err)
+	defer suite.session.Remove(entity.PodCollectionName, "_id", strangerPod.ID)
+	httpWriter = suite.request("GET", "/v1/pods/"+strangerPod.ID.Hex(), viewerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// only the admins of the team delete the pods of the other members
+	httpWriter = suite.request("DELETE", "/v1/pods/"+pod.ID.Hex(), viewerBearer, nil)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+	httpWriter = suite.request("DELETE", "/v1/pods/"+pod.ID.Hex(), adminBearer, nil)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+}
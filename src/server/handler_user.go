@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/linkernetworks/mongo"
 	"github.com/linkernetworks/utils/timeutils"
 	"github.com/hwchiu/vortex/src/entity"
 	response "github.com/hwchiu/vortex/src/net/http"
@@ -22,11 +23,17 @@ import (
 func signUpUserHandler(ctx *web.Context) {
 	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
 
-	user := entity.User{}
-	if err := req.ReadEntity(&user); err != nil {
+	signupRequest := entity.SignupRequest{}
+	if err := req.ReadEntity(&signupRequest); err != nil {
 		response.BadRequest(req.Request, resp.ResponseWriter, err)
 		return
 	}
+	user := signupRequest.User
+
+	if signupRequest.InviteToken == "" && backend.GetSignup().InviteOnly {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the signup requires an invitation"))
+		return
+	}
 
 	hashedPassword, err := utils.HashPassword(user.LoginCredential.Password)
 	if err != nil {
@@ -37,14 +44,9 @@ func signUpUserHandler(ctx *web.Context) {
 
 	user.LoginCredential.Username = strings.ToLower(user.LoginCredential.Username)
 
-	// sign up user only can ba the role of user
+	// sign up user only can ba the role of user, or the role of the invitation
 	user.Role = "user"
 
-	if err := sp.Validator.Struct(user); err != nil {
-		response.BadRequest(req.Request, resp.ResponseWriter, err)
-		return
-	}
-
 	session := sp.Mongo.NewSession()
 	// make username(email) to be a unique key
 	session.C(entity.UserCollectionName).EnsureIndex(mgo.Index{
@@ -53,6 +55,31 @@ func signUpUserHandler(ctx *web.Context) {
 	})
 	defer session.Close()
 
+	invitation := entity.Invitation{}
+	if signupRequest.InviteToken != "" {
+		invitation, err = backend.FindInvitation(session, signupRequest.InviteToken)
+		if err != nil {
+			switch err {
+			case mgo.ErrNotFound:
+				response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the invitation is invalid or expired"))
+				return
+			default:
+				response.InternalServerError(req.Request, resp.ResponseWriter, err)
+				return
+			}
+		}
+		if invitation.Email != user.LoginCredential.Username {
+			response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the invitation is not for %s", user.LoginCredential.Username))
+			return
+		}
+		user.Role = invitation.Role
+	}
+
+	if err := sp.Validator.Struct(user); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
 	user.ID = bson.NewObjectId()
 	user.CreatedAt = timeutils.Now()
 
@@ -64,6 +91,14 @@ func signUpUserHandler(ctx *web.Context) {
 		}
 		return
 	}
+
+	// the invitation can only be redeemed once
+	if invitation.ID != "" {
+		if err := session.Remove(entity.InvitationCollectionName, "_id", invitation.ID); err != nil && err != mgo.ErrNotFound {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
 	resp.WriteHeaderAndEntity(http.StatusCreated, user)
 }
 
@@ -94,11 +129,93 @@ func signInUserHandler(ctx *web.Context) {
 		return
 	}
 
+	// reject the attempts in the backoff or of the locked account before checking the password
+	lockout := backend.GetLockout()
+	ip := clientIP(sp, req.Request)
+	wait, err := lockout.Check(session, credential.Username, ip)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	if wait > 0 {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		resp.AddHeader("Retry-After", strconv.Itoa(retryAfter))
+		response.TooManyRequests(req.Request, resp.ResponseWriter, fmt.Errorf("Too many failed attempts, retry after %d seconds", retryAfter))
+		return
+	}
+
 	authenticatedUser, passed, err := backend.Authenticate(session, credential)
+	if err != nil && err != mgo.ErrNotFound {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	// when authenticating not pass
+	if err == mgo.ErrNotFound || !passed {
+		if err := lockout.RecordFailure(session, credential.Username, ip); err != nil {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: Failed to login. Incorrect authentication credentials"))
+		return
+	}
+
+	// Passed
+	if err := lockout.Reset(session, credential.Username); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	tokenResponse, err := newSessionTokenResponse(session, authenticatedUser)
+	if err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+	resp.WriteEntity(tokenResponse)
+}
+
+// newSessionTokenResponse will create a session for the authenticated user and return its tokens
+func newSessionTokenResponse(session *mongo.Session, user entity.User) (response.TokenResponse, error) {
+	userSession, refreshToken, err := backend.CreateSession(session, user)
+	if err != nil {
+		return response.TokenResponse{}, err
+	}
+
+	tokenString, err := backend.GenerateSessionToken(userSession, user)
+	if err != nil {
+		return response.TokenResponse{}, err
+	}
+
+	return response.TokenResponse{
+		Error:        false,
+		Message:      tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(backend.GetKeySet().Expiration.Seconds()),
+	}, nil
+}
+
+func refreshTokenHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	credential := entity.RefreshCredential{}
+	if err := req.ReadEntity(&credential); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(credential); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	userSession, refreshToken, err := backend.RefreshSession(session, credential.RefreshToken)
 	if err != nil {
 		switch err {
 		case mgo.ErrNotFound:
-			response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: Failed to login. Incorrect authentication credentials"))
+			response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: The refresh token is invalid or expired"))
 			return
 		default:
 			response.InternalServerError(req.Request, resp.ResponseWriter, err)
@@ -106,22 +223,90 @@ func signInUserHandler(ctx *web.Context) {
 		}
 	}
 
-	// when authenticating not pass
-	if !passed {
-		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: Failed to login. Incorrect authentication credentials"))
-		return
+	user, err := backend.FindUserByID(session, userSession.UserID)
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: The user of the refresh token is not found"))
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
 	}
 
-	// Passed
-	tokenString, err := backend.GenerateToken(authenticatedUser.ID.Hex(), authenticatedUser)
+	tokenString, err := backend.GenerateSessionToken(userSession, user)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
 	}
 
+	resp.WriteEntity(response.TokenResponse{
+		Error:        false,
+		Message:      tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(backend.GetKeySet().Expiration.Seconds()),
+	})
+}
+
+func signOutUserHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+	userID, ok := req.Attribute("UserID").(string)
+	if !ok || !bson.IsObjectIdHex(userID) {
+		response.Unauthorized(req.Request, resp.ResponseWriter, fmt.Errorf("Unauthorized: User ID is empty"))
+		return
+	}
+	tokenID, ok := req.Attribute("TokenID").(string)
+	if !ok || tokenID == "" {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("The token has no token ID"))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	if err := backend.RevokeSession(session, tokenID, bson.ObjectIdHex(userID)); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
 	resp.WriteEntity(response.ActionResponse{
 		Error:   false,
-		Message: tokenString,
+		Message: "Sign out success",
+	})
+}
+
+func revokeUserSessionsHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	if _, err := backend.FindUserByID(session, bson.ObjectIdHex(id)); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if err := backend.RevokeUserSessions(session, bson.ObjectIdHex(id)); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "User Sessions Revoked Success",
 	})
 }
 
@@ -199,6 +384,17 @@ func deleteUserHandler(ctx *web.Context) {
 		}
 	}
 
+	// the deleted user can not access with the issued tokens
+	if err := backend.RevokeUserTokens(session, user.ID); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := backend.RemoveUserFromTeams(session, user.ID); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
 	resp.WriteEntity(response.ActionResponse{
 		Error:   false,
 		Message: "User Deleted Success",
@@ -277,3 +473,216 @@ func getUserHandler(ctx *web.Context) {
 	}
 	resp.WriteEntity(user)
 }
+
+func updateUserHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+	if !isOwner(req, bson.ObjectIdHex(id)) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user can only update itself"))
+		return
+	}
+
+	update := entity.UserUpdate{}
+	if err := req.ReadEntity(&update); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(update); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	user, err := backend.FindUserByID(session, bson.ObjectIdHex(id))
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	// only the root role can change the role of a user
+	roleChanged := update.Role != "" && update.Role != user.Role
+	if role, _ := req.Attribute("Role").(string); roleChanged && role != entity.RootRole {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: only the root role can change the role"))
+		return
+	}
+
+	// the username and password can not be changed here, the empty fields are not changed
+	if update.Role != "" {
+		user.Role = update.Role
+	}
+	if update.DisplayName != "" {
+		user.DisplayName = update.DisplayName
+	}
+	if update.FirstName != "" {
+		user.FirstName = update.FirstName
+	}
+	if update.LastName != "" {
+		user.LastName = update.LastName
+	}
+	if update.PhoneNumber != "" {
+		user.PhoneNumber = update.PhoneNumber
+	}
+
+	if err := session.C(entity.UserCollectionName).UpdateId(user.ID, &user); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	// the issued JWT carry the old role, the user has to sign in again
+	if roleChanged {
+		if err := backend.RevokeUserSessions(session, user.ID); err != nil {
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+	resp.WriteEntity(user)
+}
+
+func changePasswordHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+	if !isOwner(req, bson.ObjectIdHex(id)) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the user can only change its own password"))
+		return
+	}
+
+	change := entity.PasswordChange{}
+	if err := req.ReadEntity(&change); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(change); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	user, err := backend.FindUserByID(session, bson.ObjectIdHex(id))
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if !utils.CheckPasswordHash(change.OldPassword, user.LoginCredential.Password) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: incorrect old password"))
+		return
+	}
+
+	if err := backend.UpdatePassword(session, user.ID, change.NewPassword); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "User Password Changed Success",
+	})
+}
+
+func resetPasswordHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+
+	reset := entity.PasswordReset{}
+	if err := req.ReadEntity(&reset); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	if err := sp.Validator.Struct(reset); err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	if _, err := backend.FindUserByID(session, bson.ObjectIdHex(id)); err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if err := backend.UpdatePassword(session, bson.ObjectIdHex(id), reset.NewPassword); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "User Password Reset Success",
+	})
+}
+
+func unlockUserHandler(ctx *web.Context) {
+	sp, req, resp := ctx.ServiceProvider, ctx.Request, ctx.Response
+
+	id := req.PathParameter("id")
+	if !bson.IsObjectIdHex(id) {
+		response.BadRequest(req.Request, resp.ResponseWriter, fmt.Errorf("Invalid user ID: %s", id))
+		return
+	}
+
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	user, err := backend.FindUserByID(session, bson.ObjectIdHex(id))
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			response.NotFound(req.Request, resp.ResponseWriter, err)
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	if err := backend.UnlockUser(session, user.LoginCredential.Username); err != nil {
+		response.InternalServerError(req.Request, resp.ResponseWriter, err)
+		return
+	}
+
+	resp.WriteEntity(response.ActionResponse{
+		Error:   false,
+		Message: "User Unlocked Success",
+	})
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -13,10 +15,19 @@ import (
 	"github.com/linkernetworks/mongo"
 	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
+	kubeCtl "github.com/hwchiu/vortex/src/kubernetes"
+	response "github.com/hwchiu/vortex/src/net/http"
+	"github.com/hwchiu/vortex/src/server/backend"
 	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/hwchiu/vortex/src/utils"
 	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/stretchr/testify/suite"
 	"gopkg.in/mgo.v2/bson"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func init() {
@@ -239,6 +250,7 @@ func (suite *UserTestSuite) TestCreateUser() {
 	bodyReader := strings.NewReader(string(bodyBytes))
 	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users", bodyReader)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpWriter := httptest.NewRecorder()
@@ -262,6 +274,7 @@ func (suite *UserTestSuite) TestCreateUser() {
 	bodyReader = strings.NewReader(string(bodyBytes))
 	httpRequest, err = http.NewRequest("POST", "http://localhost:7890/v1/users", bodyReader)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -288,6 +301,7 @@ func (suite *UserTestSuite) TestCreateUserFail() {
 	bodyReader := strings.NewReader(string(bodyBytes))
 	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users", bodyReader)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpWriter := httptest.NewRecorder()
@@ -319,6 +333,7 @@ func (suite *UserTestSuite) TestDeleteUser() {
 	bodyReader := strings.NewReader(string(bodyBytes))
 	httpRequest, err := http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+user.ID.Hex(), bodyReader)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpWriter := httptest.NewRecorder()
@@ -333,6 +348,7 @@ func (suite *UserTestSuite) TestDeleteUser() {
 func (suite *UserTestSuite) TestDeleteUserWithInvalidID() {
 	httpRequest, err := http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+bson.NewObjectId().Hex(), nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpRequest.Header.Add("Content-Type", "application/json")
 	httpWriter := httptest.NewRecorder()
@@ -431,6 +447,7 @@ func (suite *UserTestSuite) TestListUser() {
 			}
 			httpRequest, err := http.NewRequest("GET", url, nil)
 			suite.NoError(err)
+			httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 			httpWriter := httptest.NewRecorder()
 			suite.wc.Dispatch(httpWriter, httpRequest)
@@ -457,6 +474,7 @@ func (suite *UserTestSuite) TestListUserWithInvalidPage() {
 	// Get data with non-exits ID
 	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/users?page=asdd", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter := httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -464,6 +482,7 @@ func (suite *UserTestSuite) TestListUserWithInvalidPage() {
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/users?page_size=asdd", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
@@ -471,8 +490,486 @@ func (suite *UserTestSuite) TestListUserWithInvalidPage() {
 
 	httpRequest, err = http.NewRequest("GET", "http://localhost:7890/v1/users?page=-1", nil)
 	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
 
 	httpWriter = httptest.NewRecorder()
 	suite.wc.Dispatch(httpWriter, httpRequest)
 	assertResponseCode(suite.T(), http.StatusInternalServerError, httpWriter)
 }
+
+func (suite *UserTestSuite) signIn(cred entity.LoginCredential) response.TokenResponse {
+	bodyBytes, err := json.MarshalIndent(cred, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/signin", bodyReader)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	token := response.TokenResponse{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &token)
+	suite.NoError(err)
+	suite.NotEmpty(token.Message)
+	suite.NotEmpty(token.RefreshToken)
+	return token
+}
+
+func (suite *UserTestSuite) insertUser(password string) entity.User {
+	hashedPassword, err := utils.HashPassword(password)
+	suite.NoError(err)
+	user := entity.User{
+		ID: bson.NewObjectId(),
+		LoginCredential: entity.LoginCredential{
+			Username: namesgenerator.GetRandomName(0) + "@linkernetworks.com",
+			Password: hashedPassword,
+		},
+		DisplayName: "John Doe",
+		Role:        "user",
+		FirstName:   "John",
+		LastName:    "Doe",
+		PhoneNumber: "0900000000",
+	}
+	err = suite.session.Insert(entity.UserCollectionName, &user)
+	suite.NoError(err)
+	return user
+}
+
+func (suite *UserTestSuite) verifyToken(token string) int {
+	httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/users/verify/auth", nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Authorization", "Bearer "+token)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter.Code
+}
+
+func (suite *UserTestSuite) TestRefreshToken() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	token := suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	})
+
+	bodyBytes, err := json.Marshal(entity.RefreshCredential{RefreshToken: token.RefreshToken})
+	suite.NoError(err)
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/refresh", strings.NewReader(string(bodyBytes)))
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	refreshed := response.TokenResponse{}
+	err = json.Unmarshal(httpWriter.Body.Bytes(), &refreshed)
+	suite.NoError(err)
+	suite.NotEqual(token.RefreshToken, refreshed.RefreshToken)
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(refreshed.Message))
+
+	// the refresh token is rotated, the old one can not be used again
+	httpRequest, err = http.NewRequest("POST", "http://localhost:7890/v1/users/refresh", strings.NewReader(string(bodyBytes)))
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+}
+
+func (suite *UserTestSuite) TestSignOutUser() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	cred := entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	}
+	token := suite.signIn(cred)
+	otherToken := suite.signIn(cred)
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(token.Message))
+
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/signout", nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", "Bearer "+token.Message)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	// only the signed out session is revoked
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Message))
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(otherToken.Message))
+
+	// the refresh token of the signed out session can not be used
+	bodyBytes, err := json.Marshal(entity.RefreshCredential{RefreshToken: token.RefreshToken})
+	suite.NoError(err)
+	httpRequest, err = http.NewRequest("POST", "http://localhost:7890/v1/users/refresh", strings.NewReader(string(bodyBytes)))
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+}
+
+func (suite *UserTestSuite) TestRevokeUserSessions() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	cred := entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	}
+	tokens := []response.TokenResponse{suite.signIn(cred), suite.signIn(cred)}
+
+	httpRequest, err := http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+user.ID.Hex()+"/sessions", nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	for _, token := range tokens {
+		suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Message))
+	}
+
+	// the user can sign in again
+	token := suite.signIn(cred)
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(token.Message))
+
+	// non-root users can not revoke the sessions
+	httpRequest, err = http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+user.ID.Hex()+"/sessions", nil)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", "Bearer "+token.Message)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+}
+
+func (suite *UserTestSuite) putUser(path, bearer string, body interface{}) *httptest.ResponseRecorder {
+	bodyBytes, err := json.MarshalIndent(body, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest("PUT", "http://localhost:7890/v1/users/"+path, bodyReader)
+	suite.NoError(err)
+
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpRequest.Header.Add("Authorization", bearer)
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter
+}
+
+func (suite *UserTestSuite) TestUpdateUser() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	token := suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	})
+	bearer := "Bearer " + token.Message
+
+	httpWriter := suite.putUser(user.ID.Hex(), bearer, entity.User{
+		DisplayName: "Jane Doe",
+		PhoneNumber: "0911111111",
+	})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	updated := entity.User{}
+	err := suite.session.FindOne(entity.UserCollectionName, bson.M{"_id": user.ID}, &updated)
+	suite.NoError(err)
+	suite.Equal("Jane Doe", updated.DisplayName)
+	suite.Equal("0911111111", updated.PhoneNumber)
+	suite.Equal(user.FirstName, updated.FirstName)
+	suite.Equal(user.LoginCredential, updated.LoginCredential)
+
+	// the invalid phone number is rejected by the validator
+	httpWriter = suite.putUser(user.ID.Hex(), bearer, entity.User{PhoneNumber: "phone"})
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+
+	// the user can not change its own role
+	httpWriter = suite.putUser(user.ID.Hex(), bearer, entity.User{Role: entity.RootRole})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// the user can not update other users
+	httpWriter = suite.putUser(bson.NewObjectId().Hex(), bearer, entity.User{DisplayName: "Jane Doe"})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	// the root role can change the role, and the user has to sign in again
+	httpWriter = suite.putUser(user.ID.Hex(), suite.JWTBearer, entity.User{Role: entity.GuestRole})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	err = suite.session.FindOne(entity.UserCollectionName, bson.M{"_id": user.ID}, &updated)
+	suite.NoError(err)
+	suite.Equal(entity.GuestRole, updated.Role)
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Message))
+}
+
+func (suite *UserTestSuite) TestUpdateProvisionedUser() {
+	// the users provisioned by the LDAP or the OpenID Connect may have no phone number
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+	err := suite.session.C(entity.UserCollectionName).UpdateId(user.ID, bson.M{"$set": bson.M{"phoneNumber": ""}})
+	suite.NoError(err)
+
+	httpWriter := suite.putUser(user.ID.Hex(), suite.JWTBearer, entity.UserUpdate{DisplayName: "Jane Doe"})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	updated := entity.User{}
+	err = suite.session.FindOne(entity.UserCollectionName, bson.M{"_id": user.ID}, &updated)
+	suite.NoError(err)
+	suite.Equal("Jane Doe", updated.DisplayName)
+	suite.Equal("", updated.PhoneNumber)
+}
+
+func (suite *UserTestSuite) TestChangePassword() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+
+	token := suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	})
+	bearer := "Bearer " + token.Message
+
+	httpWriter := suite.putUser(user.ID.Hex()+"/password", bearer, entity.PasswordChange{
+		OldPassword: "wrong",
+		NewPassword: "n3wp@ssw0rd",
+	})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.putUser(user.ID.Hex()+"/password", bearer, entity.PasswordChange{
+		OldPassword: "p@ssw0rd",
+	})
+	assertResponseCode(suite.T(), http.StatusBadRequest, httpWriter)
+
+	httpWriter = suite.putUser(user.ID.Hex()+"/password", bearer, entity.PasswordChange{
+		OldPassword: "p@ssw0rd",
+		NewPassword: "n3wp@ssw0rd",
+	})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	// the issued tokens are invalidated
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Message))
+
+	token = suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "n3wp@ssw0rd",
+	})
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(token.Message))
+}
+
+func (suite *UserTestSuite) TestResetPassword() {
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+	defer backend.RemoveUserAPITokens(suite.session, user.ID)
+
+	token := suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	})
+	_, apiToken, err := backend.CreateAPIToken(suite.session, entity.APIToken{
+		UserID: user.ID,
+		Name:   "ci",
+		Role:   entity.UserRole,
+	})
+	suite.NoError(err)
+
+	// only the root role can reset the password
+	httpWriter := suite.putUser(user.ID.Hex()+"/password/reset", "Bearer "+token.Message, entity.PasswordReset{
+		NewPassword: "n3wp@ssw0rd",
+	})
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpWriter = suite.putUser(bson.NewObjectId().Hex()+"/password/reset", suite.JWTBearer, entity.PasswordReset{
+		NewPassword: "n3wp@ssw0rd",
+	})
+	assertResponseCode(suite.T(), http.StatusNotFound, httpWriter)
+
+	httpWriter = suite.putUser(user.ID.Hex()+"/password/reset", suite.JWTBearer, entity.PasswordReset{
+		NewPassword: "n3wp@ssw0rd",
+	})
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	// the issued tokens and API tokens are invalidated
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(token.Message))
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(apiToken))
+
+	token = suite.signIn(entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "n3wp@ssw0rd",
+	})
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(token.Message))
+}
+
+func (suite *UserTestSuite) signInFrom(cred entity.LoginCredential, remoteAddr string) *httptest.ResponseRecorder {
+	bodyBytes, err := json.MarshalIndent(cred, "", "  ")
+	suite.NoError(err)
+
+	bodyReader := strings.NewReader(string(bodyBytes))
+	httpRequest, err := http.NewRequest("POST", "http://localhost:7890/v1/users/signin", bodyReader)
+	suite.NoError(err)
+
+	httpRequest.RemoteAddr = remoteAddr
+	httpRequest.Header.Add("Content-Type", "application/json")
+	httpWriter := httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	return httpWriter
+}
+
+func (suite *UserTestSuite) TestSignInLockout() {
+	err := backend.SetupLockout(&config.LockoutConfig{
+		FreeAttempts: 2,
+		BaseDelay:    "1h",
+		Threshold:    2,
+		LockDuration: "2h",
+	})
+	suite.NoError(err)
+	defer backend.SetupLockout(nil)
+
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+	defer backend.UnlockUser(suite.session, user.LoginCredential.Username)
+
+	remoteAddr := "10.0.0.1:" + strconv.Itoa(rand.Intn(60000)+1024)
+	wrongCred := entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "wrong",
+	}
+	for i := 0; i < 2; i++ {
+		httpWriter := suite.signInFrom(wrongCred, remoteAddr)
+		assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+	}
+
+	// the account is locked even with the correct password
+	cred := entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	}
+	httpWriter := suite.signInFrom(cred, remoteAddr)
+	assertResponseCode(suite.T(), http.StatusTooManyRequests, httpWriter)
+	retryAfter, err := strconv.Atoi(httpWriter.Header().Get("Retry-After"))
+	suite.NoError(err)
+	suite.InDelta(2*60*60, retryAfter, 5)
+
+	// non-root users can not unlock the account
+	other := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", other.ID)
+	token := suite.signIn(entity.LoginCredential{
+		Username: other.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	})
+
+	httpRequest, err := http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+user.ID.Hex()+"/lock", nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", "Bearer "+token.Message)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusForbidden, httpWriter)
+
+	httpRequest, err = http.NewRequest("DELETE", "http://localhost:7890/v1/users/"+user.ID.Hex()+"/lock", nil)
+	suite.NoError(err)
+	httpRequest.Header.Add("Authorization", suite.JWTBearer)
+	httpWriter = httptest.NewRecorder()
+	suite.wc.Dispatch(httpWriter, httpRequest)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+
+	httpWriter = suite.signInFrom(cred, remoteAddr)
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+}
+
+func (suite *UserTestSuite) TestSignInIPBackoff() {
+	err := backend.SetupLockout(&config.LockoutConfig{
+		IPFreeAttempts: 2,
+		BaseDelay:      "1h",
+	})
+	suite.NoError(err)
+	defer backend.SetupLockout(nil)
+
+	// the failures of different usernames are counted for the client IP
+	remoteAddr := "10.0.1.1:" + strconv.Itoa(rand.Intn(60000)+1024)
+	for i := 0; i < 2; i++ {
+		username := namesgenerator.GetRandomName(0) + "@linkernetworks.com"
+		defer backend.UnlockUser(suite.session, username)
+
+		httpWriter := suite.signInFrom(entity.LoginCredential{
+			Username: username,
+			Password: "p@ssw0rd",
+		}, remoteAddr)
+		assertResponseCode(suite.T(), http.StatusUnauthorized, httpWriter)
+	}
+
+	user := suite.insertUser("p@ssw0rd")
+	defer suite.session.Remove(entity.UserCollectionName, "_id", user.ID)
+	cred := entity.LoginCredential{
+		Username: user.LoginCredential.Username,
+		Password: "p@ssw0rd",
+	}
+	httpWriter := suite.signInFrom(cred, remoteAddr)
+	assertResponseCode(suite.T(), http.StatusTooManyRequests, httpWriter)
+	suite.NotEmpty(httpWriter.Header().Get("Retry-After"))
+
+	// the other clients are not blocked
+	httpWriter = suite.signInFrom(cred, "10.0.2.1:1234")
+	assertResponseCode(suite.T(), http.StatusOK, httpWriter)
+}
+
+func (suite *UserTestSuite) TestVerifyServiceAccountToken() {
+	// the ServiceAccount tokens are JWTs whose subject is the username of the ServiceAccount
+	tokens := map[string]string{}
+	for _, name := range []string{"operator", "unknown"} {
+		username := "system:serviceaccount:default:" + name
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"` + username + `"}`))
+		tokens[name] = "eyJhbGciOiJSUzI1NiJ9." + payload + ".c2lnbmF0dXJl"
+	}
+	reviews := 0
+	fakeclient := fakeclientset.NewSimpleClientset()
+	fakeclient.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		reviews++
+		for name, token := range tokens {
+			if review.Spec.Token == token {
+				review.Status = authenticationv1.TokenReviewStatus{
+					Authenticated: true,
+					User: authenticationv1.UserInfo{
+						Username: "system:serviceaccount:default:" + name,
+					},
+				}
+			}
+		}
+		return true, review, nil
+	})
+	err := backend.SetupServiceAccountAuthenticator(kubeCtl.New(fakeclient), &config.ServiceAccountConfig{
+		Roles: map[string]string{"default/operator": entity.UserRole},
+	})
+	suite.NoError(err)
+	defer backend.SetupServiceAccountAuthenticator(nil, nil)
+	defer suite.session.Remove(entity.UserCollectionName, "loginCredential.username", "system:serviceaccount:default:operator")
+
+	suite.Equal(http.StatusSeeOther, suite.verifyToken(tokens["operator"]))
+	// the ServiceAccounts without the role are rejected
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(tokens["unknown"]))
+
+	// the other invalid tokens are not reviewed
+	reviewed := reviews
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken("operator"))
+	suite.Equal(reviewed, reviews)
+
+	// the ServiceAccount tokens are rejected when the authenticator is disabled
+	backend.SetupServiceAccountAuthenticator(nil, nil)
+	suite.Equal(http.StatusUnauthorized, suite.verifyToken(tokens["operator"]))
+}
@@ -86,6 +86,11 @@ func deleteVolumeHandler(ctx *web.Context) {
 		return
 	}
 
+	if !isOwner(req, v.OwnerID) {
+		response.Forbidden(req.Request, resp.ResponseWriter, fmt.Errorf("Permission denied: the volume is not owned by the user"))
+		return
+	}
+
 	if err := volume.DeleteVolume(sp, &v); err != nil {
 		if errors.IsNotFound(err) {
 			response.NotFound(req.Request, resp.ResponseWriter, err)
@@ -136,7 +141,11 @@ func listVolumeHandler(ctx *web.Context) {
 	var c = session.C(entity.VolumeCollectionName)
 	var q *mgo.Query
 
-	selector := bson.M{}
+	selector, err := ownerSelector(req)
+	if err != nil {
+		response.BadRequest(req.Request, resp.ResponseWriter, err)
+		return
+	}
 	q = c.Find(selector).Sort("_id").Skip((page - 1) * pageSize).Limit(pageSize)
 
 	if err := q.All(&volumes); err != nil {
@@ -156,7 +165,7 @@ func listVolumeHandler(ctx *web.Context) {
 		volume.CreatedBy, _ = backend.FindUserByID(session, volume.OwnerID)
 	}
 
-	count, err := session.Count(entity.VolumeCollectionName, bson.M{})
+	count, err := session.Count(entity.VolumeCollectionName, selector)
 	if err != nil {
 		response.InternalServerError(req.Request, resp.ResponseWriter, err)
 		return
@@ -1,18 +1,14 @@
 package server
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"net/http/httptest"
 	"os"
-	"strings"
 	"testing"
 
-	restful "github.com/emicklei/go-restful"
 	"github.com/hwchiu/vortex/src/config"
 	"github.com/hwchiu/vortex/src/entity"
 	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/hwchiu/vortex/src/utils"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -21,16 +17,16 @@ func init() {
 	cf := config.MustRead("../../config/testing.json")
 	sp := serviceprovider.NewForTesting(cf)
 
-	// init restful container
-	wc := restful.NewContainer()
-	service := newUserService(sp)
-	wc.Add(service)
+	session := sp.Mongo.NewSession()
+	defer session.Close()
 
+	// the users API requires the root role, so insert the root test user directly
+	hashedPassword, _ := utils.HashPassword("test")
 	user := entity.User{
 		ID: bson.NewObjectId(),
 		LoginCredential: entity.LoginCredential{
 			Username: "test@linkernetworks.com",
-			Password: "test",
+			Password: hashedPassword,
 		},
 		DisplayName: "mainTestUser",
 		Role:        "root",
@@ -39,18 +35,13 @@ func init() {
 		PhoneNumber: "0000000000",
 	}
 
-	bodyBytes, _ := json.MarshalIndent(user, "", "  ")
-
-	bodyReader := strings.NewReader(string(bodyBytes))
-	httpRequest, _ := http.NewRequest(
-		"POST",
-		"http://localhost:7890/v1/users",
-		bodyReader,
-	)
+	// forget the failed attempts of the previous runs
+	session.C(entity.LoginAttemptCollectionName).RemoveAll(bson.M{})
 
-	httpRequest.Header.Add("Content-Type", "application/json")
-	httpWriter := httptest.NewRecorder()
-	wc.Dispatch(httpWriter, httpRequest)
+	count, _ := session.Count(entity.UserCollectionName, bson.M{"loginCredential.username": user.LoginCredential.Username})
+	if count == 0 {
+		session.Insert(entity.UserCollectionName, &user)
+	}
 }
 
 func TestMain(m *testing.M) {
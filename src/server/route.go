@@ -14,6 +14,7 @@ func (a *App) AppRoute() *mux.Router {
 	container := restful.NewContainer()
 
 	container.Filter(globalLogging)
+	container.Filter(auditMiddleware(a.ServiceProvider))
 
 	container.Add(newVersionService(a.ServiceProvider))
 	container.Add(newRegistryService(a.ServiceProvider))
@@ -29,6 +30,9 @@ func (a *App) AppRoute() *mux.Router {
 	container.Add(newMonitoringService(a.ServiceProvider))
 	container.Add(newAppService(a.ServiceProvider))
 	container.Add(newOVSService(a.ServiceProvider))
+	container.Add(newAuditService(a.ServiceProvider))
+	container.Add(newTeamService(a.ServiceProvider))
+	container.Add(newInvitationService(a.ServiceProvider))
 
 	router.PathPrefix("/v1/").Handler(container)
 	return router
@@ -44,7 +48,8 @@ func newVersionService(sp *serviceprovider.Container) *restful.WebService {
 func newRegistryService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/registry").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Route(webService.POST("/auth").To(handler.RESTfulServiceHandler(sp, registryBasicAuthHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/auth").Filter(userRole).To(handler.RESTfulServiceHandler(sp, registryBasicAuthHandler)))
 	return webService
 }
 
@@ -54,134 +59,191 @@ func newUserService(sp *serviceprovider.Container) *restful.WebService {
 	// Authenticate handlers Sign Up / Sign In
 	webService.Route(webService.POST("/signup").To(handler.RESTfulServiceHandler(sp, signUpUserHandler)))
 	webService.Route(webService.POST("/signin").To(handler.RESTfulServiceHandler(sp, signInUserHandler)))
-
-	// TODO only root role can access
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listUserHandler)))
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createUserHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteUserHandler)))
-
-	// user role can access
-	webService.Route(webService.GET("/{id}").Filter(validateTokenMiddleware).To(handler.RESTfulServiceHandler(sp, getUserHandler)))
-	webService.Route(webService.GET("/verify/auth").Filter(validateTokenMiddleware).To(handler.RESTfulServiceHandler(sp, verifyTokenHandler)))
+	webService.Route(webService.POST("/refresh").To(handler.RESTfulServiceHandler(sp, refreshTokenHandler)))
+	webService.Route(webService.GET("/oidc/login").To(handler.RESTfulServiceHandler(sp, oidcLoginHandler)))
+	webService.Route(webService.GET("/oidc/callback").To(handler.RESTfulServiceHandler(sp, oidcCallbackHandler)))
+
+	// only root role can access
+	webService.Route(webService.GET("/").Filter(validateTokenMiddleware(sp)).Filter(rootRole).To(handler.RESTfulServiceHandler(sp, listUserHandler)))
+	webService.Route(webService.POST("/").Filter(validateTokenMiddleware(sp)).Filter(rootRole).To(handler.RESTfulServiceHandler(sp, createUserHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(validateTokenMiddleware(sp)).Filter(rootRole).To(handler.RESTfulServiceHandler(sp, deleteUserHandler)))
+	webService.Route(webService.DELETE("/{id}/sessions").Filter(validateTokenMiddleware(sp)).Filter(rootRole).To(handler.RESTfulServiceHandler(sp, revokeUserSessionsHandler)))
+	webService.Route(webService.PUT("/{id}/password/reset").Filter(validateTokenMiddleware(sp)).Filter(rootRole).To(handler.RESTfulServiceHandler(sp, resetPasswordHandler)))
+	webService.Route(webService.DELETE("/{id}/lock").Filter(validateTokenMiddleware(sp)).Filter(rootRole).To(handler.RESTfulServiceHandler(sp, unlockUserHandler)))
+
+	// guest role can access
+	webService.Route(webService.GET("/{id}").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getUserHandler)))
+	webService.Route(webService.GET("/verify/auth").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, verifyTokenHandler)))
+	webService.Route(webService.POST("/signout").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, signOutUserHandler)))
+
+	// the signed-in users link their OpenID Connect accounts
+	webService.Route(webService.POST("/oidc/link").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, oidcLinkHandler)))
+
+	// the users update themselves, the root role can update all users
+	webService.Route(webService.PUT("/{id}").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, updateUserHandler)))
+	webService.Route(webService.PUT("/{id}/password").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, changePasswordHandler)))
+
+	// the users manage their own API tokens, the root role can manage the tokens of all users
+	webService.Route(webService.POST("/{id}/tokens").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, createAPITokenHandler)))
+	webService.Route(webService.GET("/{id}/tokens").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listAPITokenHandler)))
+	webService.Route(webService.DELETE("/{id}/tokens/{tokenID}").Filter(validateTokenMiddleware(sp)).Filter(guestRole).To(handler.RESTfulServiceHandler(sp, deleteAPITokenHandler)))
 	return webService
 }
 
 func newNetworkService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/networks").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listNetworkHandler)))
-	webService.Route(webService.GET("/{id}").To(handler.RESTfulServiceHandler(sp, getNetworkHandler)))
-	webService.Route(webService.GET("/status/{id}").To(handler.RESTfulServiceHandler(sp, getNetworkStatusHandler)))
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createNetworkHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteNetworkHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listNetworkHandler)))
+	webService.Route(webService.GET("/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getNetworkHandler)))
+	webService.Route(webService.GET("/status/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getNetworkStatusHandler)))
+	webService.Route(webService.GET("/{id}/status").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getNetworkDriftHandler)))
+	webService.Route(webService.GET("/{id}/ports").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getNetworkPortsHandler)))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createNetworkHandler)))
+	webService.Route(webService.PUT("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, updateNetworkHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deleteNetworkHandler)))
 	return webService
 }
 
 func newStorageService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/storage").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createStorage)))
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listStorage)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteStorage)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createStorage)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listStorage)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deleteStorage)))
 	return webService
 }
 
 func newVolumeService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/volume").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createVolumeHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteVolumeHandler)))
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listVolumeHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createVolumeHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deleteVolumeHandler)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listVolumeHandler)))
 	return webService
 }
 
 func newContainerService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/containers").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Route(webService.GET("/logs/{namespace}/{pod}/{container}").To(handler.RESTfulServiceHandler(sp, getContainerLogsHandler)))
-	webService.Route(webService.GET("/logs/file/{namespace}/{pod}/{container}").To(handler.RESTfulServiceHandler(sp, getContainerLogFileHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.GET("/logs/{namespace}/{pod}/{container}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getContainerLogsHandler)))
+	webService.Route(webService.GET("/logs/file/{namespace}/{pod}/{container}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getContainerLogFileHandler)))
 	return webService
 }
 
 func newPodService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/pods").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createPodHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deletePodHandler)))
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listPodHandler)))
-	webService.Route(webService.GET("/{id}").To(handler.RESTfulServiceHandler(sp, getPodHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createPodHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deletePodHandler)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listPodHandler)))
+	webService.Route(webService.GET("/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getPodHandler)))
 	return webService
 }
 
 func newDeploymentService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
-	webService.Filter(validateTokenMiddleware)
+	webService.Filter(validateTokenMiddleware(sp))
 	webService.Path("/v1/deployments").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createDeploymentHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteDeploymentHandler)))
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listDeploymentHandler)))
-	webService.Route(webService.GET("/{id}").To(handler.RESTfulServiceHandler(sp, getDeploymentHandler)))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createDeploymentHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deleteDeploymentHandler)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listDeploymentHandler)))
+	webService.Route(webService.GET("/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getDeploymentHandler)))
 	return webService
 }
 
 func newAppService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/apps").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createAppHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createAppHandler)))
 	return webService
 }
 
 func newServiceService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/services").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createServiceHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteServiceHandler)))
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listServiceHandler)))
-	webService.Route(webService.GET("/{id}").To(handler.RESTfulServiceHandler(sp, getServiceHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createServiceHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deleteServiceHandler)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listServiceHandler)))
+	webService.Route(webService.GET("/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getServiceHandler)))
 	return webService
 }
 
 func newNamespaceService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/namespaces").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Filter(validateTokenMiddleware)
-	webService.Route(webService.POST("/").To(handler.RESTfulServiceHandler(sp, createNamespaceHandler)))
-	webService.Route(webService.DELETE("/{id}").To(handler.RESTfulServiceHandler(sp, deleteNamespaceHandler)))
-	webService.Route(webService.GET("/").To(handler.RESTfulServiceHandler(sp, listNamespaceHandler)))
-	webService.Route(webService.GET("/{id}").To(handler.RESTfulServiceHandler(sp, getNamespaceHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(userRole).To(handler.RESTfulServiceHandler(sp, createNamespaceHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(userRole).To(handler.RESTfulServiceHandler(sp, deleteNamespaceHandler)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listNamespaceHandler)))
+	webService.Route(webService.GET("/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getNamespaceHandler)))
 	return webService
 }
 
 func newMonitoringService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/monitoring").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
+	webService.Filter(validateTokenMiddleware(sp))
 	// node
-	webService.Route(webService.GET("/nodes").To(handler.RESTfulServiceHandler(sp, listNodeMetricsHandler)))
-	webService.Route(webService.GET("/nodes/{node}").To(handler.RESTfulServiceHandler(sp, getNodeMetricsHandler)))
-	webService.Route(webService.GET("/nodes/{node}/nics").To(handler.RESTfulServiceHandler(sp, listNodeNicsMetricsHandler)))
+	webService.Route(webService.GET("/nodes").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listNodeMetricsHandler)))
+	webService.Route(webService.GET("/nodes/{node}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getNodeMetricsHandler)))
+	webService.Route(webService.GET("/nodes/{node}/nics").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listNodeNicsMetricsHandler)))
 	// pod
-	webService.Route(webService.GET("/pods").To(handler.RESTfulServiceHandler(sp, listPodMetricsHandler)))
-	webService.Route(webService.GET("/pods/{pod}").To(handler.RESTfulServiceHandler(sp, getPodMetricsHandler)))
+	webService.Route(webService.GET("/pods").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listPodMetricsHandler)))
+	webService.Route(webService.GET("/pods/{pod}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getPodMetricsHandler)))
 	//container
-	webService.Route(webService.GET("/pods/{pod}/{container}").To(handler.RESTfulServiceHandler(sp, getContainerMetricsHandler)))
+	webService.Route(webService.GET("/pods/{pod}/{container}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getContainerMetricsHandler)))
 	// service
-	webService.Route(webService.GET("/services").To(handler.RESTfulServiceHandler(sp, listServiceMetricsHandler)))
-	webService.Route(webService.GET("/services/{service}").To(handler.RESTfulServiceHandler(sp, getServiceMetricsHandler)))
+	webService.Route(webService.GET("/services").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listServiceMetricsHandler)))
+	webService.Route(webService.GET("/services/{service}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getServiceMetricsHandler)))
 	// controller
-	webService.Route(webService.GET("/controllers").To(handler.RESTfulServiceHandler(sp, listControllerMetricsHandler)))
-	webService.Route(webService.GET("/controllers/{controller}").To(handler.RESTfulServiceHandler(sp, getControllerMetricsHandler)))
+	webService.Route(webService.GET("/controllers").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listControllerMetricsHandler)))
+	webService.Route(webService.GET("/controllers/{controller}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getControllerMetricsHandler)))
 	return webService
 }
 
 func newOVSService(sp *serviceprovider.Container) *restful.WebService {
 	webService := new(restful.WebService)
 	webService.Path("/v1/ovs").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
-	webService.Route(webService.GET("/portinfos").To(handler.RESTfulServiceHandler(sp, getOVSPortInfoHandler)))
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.GET("/portinfos").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getOVSPortInfoHandler)))
+	return webService
+}
+
+func newAuditService(sp *serviceprovider.Container) *restful.WebService {
+	webService := new(restful.WebService)
+	webService.Path("/v1/audit").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.GET("/").Filter(rootRole).To(handler.RESTfulServiceHandler(sp, listAuditHandler)))
+	return webService
+}
+
+func newTeamService(sp *serviceprovider.Container) *restful.WebService {
+	webService := new(restful.WebService)
+	webService.Path("/v1/teams").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(rootRole).To(handler.RESTfulServiceHandler(sp, createTeamHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(rootRole).To(handler.RESTfulServiceHandler(sp, deleteTeamHandler)))
+	webService.Route(webService.GET("/").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, listTeamHandler)))
+	webService.Route(webService.GET("/{id}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, getTeamHandler)))
+	// the admins of the team manage the members, the root role can manage all teams
+	webService.Route(webService.PUT("/{id}/members").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, setTeamMemberHandler)))
+	webService.Route(webService.DELETE("/{id}/members/{userID}").Filter(guestRole).To(handler.RESTfulServiceHandler(sp, removeTeamMemberHandler)))
+	return webService
+}
+
+func newInvitationService(sp *serviceprovider.Container) *restful.WebService {
+	webService := new(restful.WebService)
+	webService.Path("/v1/invitations").Consumes(restful.MIME_JSON, restful.MIME_JSON).Produces(restful.MIME_JSON, restful.MIME_JSON)
+	webService.Filter(validateTokenMiddleware(sp))
+	webService.Route(webService.POST("/").Filter(rootRole).To(handler.RESTfulServiceHandler(sp, createInvitationHandler)))
+	webService.Route(webService.GET("/").Filter(rootRole).To(handler.RESTfulServiceHandler(sp, listInvitationHandler)))
+	webService.Route(webService.DELETE("/{id}").Filter(rootRole).To(handler.RESTfulServiceHandler(sp, deleteInvitationHandler)))
 	return webService
 }
@@ -1,6 +1,11 @@
 package server
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 
@@ -11,6 +16,12 @@ import (
 	"github.com/hwchiu/vortex/src/entity"
 	response "github.com/hwchiu/vortex/src/net/http"
 	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/hwchiu/vortex/src/utils"
+	"github.com/linkernetworks/mongo"
+	"github.com/linkernetworks/utils/timeutils"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 func globalLogging(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
@@ -18,40 +29,234 @@ func globalLogging(req *restful.Request, resp *restful.Response, chain *restful.
 	chain.ProcessFilter(req, resp)
 }
 
-func validateTokenMiddleware(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
-	token, err := request.ParseFromRequest(req.Request, request.AuthorizationHeaderExtractor,
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(backend.SecretKey), nil
-		})
+// the max size of the response body kept to find the ID of the created resource
+const maxAuditBodySize = 64 * 1024
 
-	if err == nil {
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// save user ID to requests attributes
-			req.SetAttribute("UserID", claims["sub"])
-			// save role to requests attributes
-			req.SetAttribute("Role", claims["role"])
+// the max size of the request body of the mutating API calls, the body is read into the memory for its digest
+const maxAuditRequestBodySize = 1 << 20
+
+// auditWriter records the status code and the beginning of the body written by the handlers
+// The handlers write the errors to the http.ResponseWriter directly, so restful.Response does not know the status code of them
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len() < maxAuditBodySize {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// clientIP will return the IP of the client of the request
+// The X-Forwarded-For header is only used when the lockout config says the server is behind a trusted proxy
+func clientIP(sp *serviceprovider.Container, req *http.Request) string {
+	return utils.ClientIP(req, sp.Config.Lockout != nil && sp.Config.Lockout.UseForwardedFor)
+}
+
+// auditMiddleware records every mutating API call into the audit collection
+// It's a container filter, so the actor is known after the route filters validate the token
+func auditMiddleware(sp *serviceprovider.Container) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		action := backend.AuditAction(req.Request.Method)
+		if action == "" {
 			chain.ProcessFilter(req, resp)
+			return
+		}
+
+		resourceType, resourceID := backend.AuditResource(req.Request.URL.Path)
+		auditLog := entity.AuditLog{
+			ClientIP:     clientIP(sp, req.Request),
+			Action:       action,
+			Method:       req.Request.Method,
+			Path:         req.Request.URL.Path,
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+		}
+
+		// keep the digest only, the body may contain the passwords
+		if req.Request.Body != nil {
+			body, err := ioutil.ReadAll(io.LimitReader(req.Request.Body, maxAuditRequestBodySize+1))
+			if err != nil {
+				response.BadRequest(req.Request, resp.ResponseWriter, err)
+				return
+			}
+			if len(body) > maxAuditRequestBodySize {
+				response.WriteStatusAndError(req.Request, resp.ResponseWriter, http.StatusRequestEntityTooLarge, fmt.Errorf("The request body is larger than %d bytes", maxAuditRequestBodySize))
+				return
+			}
+			req.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			if len(body) > 0 {
+				auditLog.BodyDigest = utils.SHA256String(string(body))
+			}
+		}
+
+		writer := &auditWriter{ResponseWriter: resp.ResponseWriter}
+		resp.ResponseWriter = writer
+		chain.ProcessFilter(req, resp)
+		resp.ResponseWriter = writer.ResponseWriter
+
+		auditLog.Status = writer.status
+		if auditLog.Status == 0 {
+			auditLog.Status = http.StatusOK
+		}
+		auditLog.UserID, _ = req.Attribute("UserID").(string)
+		auditLog.Role, _ = req.Attribute("Role").(string)
+		auditLog.CreatedAt = timeutils.Now()
+
+		// the ID of the created resource is only known from the response
+		if auditLog.ResourceID == "" && action == entity.CreateAction && auditLog.Status < http.StatusBadRequest {
+			created := struct {
+				ID string `json:"id"`
+			}{}
+			if err := json.Unmarshal(writer.body.Bytes(), &created); err == nil {
+				auditLog.ResourceID = created.ID
+			}
+		}
+
+		session := sp.Mongo.NewSession()
+		defer session.Close()
+		if err := backend.CreateAuditLog(session, auditLog); err != nil {
+			log.Printf("Failed to write the audit log of %s %s: %v", auditLog.Method, auditLog.Path, err)
+		}
+	}
+}
+
+func validateTokenMiddleware(sp *serviceprovider.Container) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		// the personal API tokens are not JWT, they are authenticated by the database
+		if tokenString, err := request.AuthorizationHeaderExtractor.ExtractToken(req.Request); err == nil && backend.IsAPIToken(tokenString) {
+			validateAPIToken(sp, tokenString, req, resp, chain)
+			return
+		}
+
+		token, err := request.ParseFromRequest(req.Request, request.AuthorizationHeaderExtractor, backend.GetKeySet().Keyfunc)
+
+		if err == nil {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+				tokenID, _ := claims["jti"].(string)
+
+				session := sp.Mongo.NewSession()
+				revoked, err := backend.IsTokenRevoked(session, tokenID)
+				session.Close()
+				if err != nil {
+					response.InternalServerError(req.Request, resp.ResponseWriter, err)
+					return
+				}
+				if revoked {
+					resp.WriteHeaderAndEntity(http.StatusUnauthorized,
+						response.ActionResponse{
+							Error:   true,
+							Message: "Token is revoked",
+						})
+					return
+				}
+
+				// save user ID to requests attributes
+				req.SetAttribute("UserID", claims["sub"])
+				// save role to requests attributes
+				req.SetAttribute("Role", claims["role"])
+				// save token ID to requests attributes
+				req.SetAttribute("TokenID", tokenID)
+				chain.ProcessFilter(req, resp)
+			} else {
+				resp.WriteHeaderAndEntity(http.StatusUnauthorized,
+					response.ActionResponse{
+						Error:   true,
+						Message: "Token is invalid",
+					})
+				return
+			}
 		} else {
+			// the ServiceAccount tokens are signed by the kubernetes, they are reviewed by the API server
+			// the other invalid tokens are rejected here, so they never reach the API server
+			if authenticator := backend.GetServiceAccountAuthenticator(); authenticator != nil {
+				if tokenString, err := request.AuthorizationHeaderExtractor.ExtractToken(req.Request); err == nil && backend.IsServiceAccountToken(tokenString) {
+					validateServiceAccountToken(sp, authenticator, tokenString, req, resp, chain)
+					return
+				}
+			}
+			logger.Infof("Unauthorized access to this resource")
+			resp.WriteHeaderAndEntity(http.StatusUnauthorized,
+				response.ActionResponse{
+					Error:   true,
+					Message: "Unauthorized access to this resource",
+				})
+			return
+		}
+	}
+}
+
+func validateAPIToken(sp *serviceprovider.Container, tokenString string, req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	token, user, err := backend.AuthenticateAPIToken(session, tokenString)
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
 			resp.WriteHeaderAndEntity(http.StatusUnauthorized,
 				response.ActionResponse{
 					Error:   true,
 					Message: "Token is invalid",
 				})
 			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
+		}
+	}
+
+	// save user ID to requests attributes
+	req.SetAttribute("UserID", user.ID.Hex())
+	// the role may be lowered after the token is created, so the token can not exceed the current role
+	req.SetAttribute("Role", backend.LowerRole(token.Role, user.Role))
+	// save the API token ID to requests attributes, so the handlers can tell the API tokens from the sessions
+	req.SetAttribute("APITokenID", token.ID.Hex())
+	chain.ProcessFilter(req, resp)
+}
+
+func validateServiceAccountToken(sp *serviceprovider.Container, authenticator *backend.ServiceAccountAuthenticator, tokenString string, req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	session := sp.Mongo.NewSession()
+	defer session.Close()
+
+	user, err := authenticator.Authenticate(session, tokenString)
+	if err != nil {
+		switch err {
+		case mgo.ErrNotFound:
+			resp.WriteHeaderAndEntity(http.StatusUnauthorized,
+				response.ActionResponse{
+					Error:   true,
+					Message: "Token is invalid",
+				})
+			return
+		default:
+			response.InternalServerError(req.Request, resp.ResponseWriter, err)
+			return
 		}
-	} else {
-		logger.Infof("Unauthorized access to this resource")
-		resp.WriteHeaderAndEntity(http.StatusUnauthorized,
-			response.ActionResponse{
-				Error:   true,
-				Message: "Unauthorized access to this resource",
-			})
-		return
 	}
+
+	// save user ID to requests attributes
+	req.SetAttribute("UserID", user.ID.Hex())
+	// save role to requests attributes
+	req.SetAttribute("Role", user.Role)
+	chain.ProcessFilter(req, resp)
 }
 
 func rootRole(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
-	role := req.Attribute("Role").(string)
+	role, _ := req.Attribute("Role").(string)
 	if role == entity.RootRole {
 		chain.ProcessFilter(req, resp)
 	} else {
@@ -66,7 +271,7 @@ func rootRole(req *restful.Request, resp *restful.Response, chain *restful.Filte
 }
 
 func userRole(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
-	role := req.Attribute("Role").(string)
+	role, _ := req.Attribute("Role").(string)
 	if role == entity.RootRole || role == entity.UserRole {
 		chain.ProcessFilter(req, resp)
 	} else {
@@ -81,7 +286,7 @@ func userRole(req *restful.Request, resp *restful.Response, chain *restful.Filte
 }
 
 func guestRole(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
-	role := req.Attribute("Role").(string)
+	role, _ := req.Attribute("Role").(string)
 	if role == entity.RootRole || role == entity.UserRole || role == entity.GuestRole {
 		chain.ProcessFilter(req, resp)
 	} else {
@@ -94,3 +299,114 @@ func guestRole(req *restful.Request, resp *restful.Response, chain *restful.Filt
 		return
 	}
 }
+
+// ownerSelector returns the mongo selector which restricts a query to the objects owned by the requester.
+// The root role can access the objects of all users and narrow them down by the owner query parameter.
+func ownerSelector(req *restful.Request) (bson.M, error) {
+	role, _ := req.Attribute("Role").(string)
+	if role == entity.RootRole {
+		owner := req.QueryParameter("owner")
+		if owner == "" {
+			return bson.M{}, nil
+		}
+		if !bson.IsObjectIdHex(owner) {
+			return nil, fmt.Errorf("Invalid owner ID: %s", owner)
+		}
+		return bson.M{"ownerID": bson.ObjectIdHex(owner)}, nil
+	}
+
+	userID, ok := req.Attribute("UserID").(string)
+	if !ok || !bson.IsObjectIdHex(userID) {
+		return nil, fmt.Errorf("Invalid user ID in the token")
+	}
+	return bson.M{"ownerID": bson.ObjectIdHex(userID)}, nil
+}
+
+// isOwner checks whether the requester is allowed to access the object owned by ownerID
+func isOwner(req *restful.Request, ownerID bson.ObjectId) bool {
+	role, _ := req.Attribute("Role").(string)
+	if role == entity.RootRole {
+		return true
+	}
+	userID, ok := req.Attribute("UserID").(string)
+	return ok && userID != "" && userID == ownerID.Hex()
+}
+
+// canUseNamespace checks whether the requester is allowed to use the namespace with at least the team role
+// The root role can use all namespaces
+func canUseNamespace(session *mongo.Session, req *restful.Request, namespace, minTeamRole string) (bool, error) {
+	role, _ := req.Attribute("Role").(string)
+	if role == entity.RootRole {
+		return true, nil
+	}
+	userID, ok := req.Attribute("UserID").(string)
+	if !ok || !bson.IsObjectIdHex(userID) {
+		return false, nil
+	}
+	return backend.CanAccessNamespace(session, namespace, bson.ObjectIdHex(userID), minTeamRole)
+}
+
+// canAccessObject checks whether the requester is allowed to access the object owned by ownerID in the namespace
+// The owner can always access it, the others need at least the team role in the team which owns the namespace
+func canAccessObject(session *mongo.Session, req *restful.Request, ownerID bson.ObjectId, namespace, minTeamRole string) (bool, error) {
+	if isOwner(req, ownerID) {
+		return true, nil
+	}
+	userID, ok := req.Attribute("UserID").(string)
+	if !ok || !bson.IsObjectIdHex(userID) {
+		return false, nil
+	}
+	return backend.HasNamespaceRole(session, namespace, bson.ObjectIdHex(userID), minTeamRole)
+}
+
+// namespaceRoleSelector returns the mongo selector of the objects owned by the requester
+// or in the namespaces where the requester has at least the team role
+func namespaceRoleSelector(session *mongo.Session, req *restful.Request, minTeamRole string) (bson.M, error) {
+	selector, err := ownerSelector(req)
+	if err != nil {
+		return nil, err
+	}
+	role, _ := req.Attribute("Role").(string)
+	if role == entity.RootRole {
+		return selector, nil
+	}
+
+	namespaces, err := backend.FindRoleNamespaces(session, selector["ownerID"].(bson.ObjectId), minTeamRole)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$or": []bson.M{selector, {"namespace": bson.M{"$in": namespaces}}}}, nil
+}
+
+// hasTeamRole checks whether the requester has at least the role in the team
+// The root role has all roles in all teams
+func hasTeamRole(req *restful.Request, team entity.Team, minTeamRole string) bool {
+	role, _ := req.Attribute("Role").(string)
+	if role == entity.RootRole {
+		return true
+	}
+	userID, ok := req.Attribute("UserID").(string)
+	if !ok || !bson.IsObjectIdHex(userID) {
+		return false
+	}
+	return backend.IsTeamRoleAtLeast(backend.TeamMemberRole(team, bson.ObjectIdHex(userID)), minTeamRole)
+}
+
+// hasTeamRoleByID is the same as hasTeamRole, but the team is loaded by the ID
+// It's false when the team does not exist
+func hasTeamRoleByID(session *mongo.Session, req *restful.Request, teamID bson.ObjectId, minTeamRole string) (bool, error) {
+	if role, _ := req.Attribute("Role").(string); role == entity.RootRole {
+		return true, nil
+	}
+	if teamID == "" {
+		return false, nil
+	}
+	team, err := backend.FindTeamByID(session, teamID)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return hasTeamRole(req, team, minTeamRole), nil
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/hwchiu/vortex/src/config"
+	"github.com/hwchiu/vortex/src/entity"
+	"github.com/hwchiu/vortex/src/server/backend"
+	"github.com/hwchiu/vortex/src/serviceprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// roleLevel is used to decide whether a role is allowed to access a route
+var roleLevel = map[string]int{
+	entity.GuestRole: 1,
+	entity.UserRole:  2,
+	entity.RootRole:  3,
+}
+
+func TestRouteRoleAccess(t *testing.T) {
+	cf := config.MustRead("../../config/testing.json")
+	sp := serviceprovider.New(cf)
+	app := App{Config: cf, ServiceProvider: sp}
+	router := app.AppRoute()
+
+	tokens := map[string]string{}
+	for role := range roleLevel {
+		user := entity.User{
+			LoginCredential: entity.LoginCredential{
+				Username: role + "@linkernetworks.com",
+			},
+			DisplayName: role,
+			Role:        role,
+		}
+		token, err := backend.GenerateToken(bson.NewObjectId().Hex(), user)
+		require.NoError(t, err)
+		tokens[role] = "Bearer " + token
+	}
+
+	id := bson.NewObjectId().Hex()
+	testCases := []struct {
+		method  string
+		path    string
+		minRole string
+	}{
+		{"POST", "/v1/registry/auth", entity.UserRole},
+
+		{"GET", "/v1/users/", entity.RootRole},
+		{"POST", "/v1/users/", entity.RootRole},
+		{"DELETE", "/v1/users/" + id, entity.RootRole},
+		{"DELETE", "/v1/users/" + id + "/sessions", entity.RootRole},
+		{"PUT", "/v1/users/" + id + "/password/reset", entity.RootRole},
+		{"DELETE", "/v1/users/" + id + "/lock", entity.RootRole},
+		{"GET", "/v1/users/" + id, entity.GuestRole},
+		{"GET", "/v1/users/verify/auth", entity.GuestRole},
+		{"POST", "/v1/users/oidc/link", entity.GuestRole},
+
+		{"GET", "/v1/networks/", entity.GuestRole},
+		{"GET", "/v1/networks/" + id, entity.GuestRole},
+		{"GET", "/v1/networks/status/" + id, entity.GuestRole},
+		{"GET", "/v1/networks/" + id + "/status", entity.GuestRole},
+		{"GET", "/v1/networks/" + id + "/ports", entity.GuestRole},
+		{"POST", "/v1/networks/", entity.UserRole},
+		{"PUT", "/v1/networks/" + id, entity.UserRole},
+		{"DELETE", "/v1/networks/" + id, entity.UserRole},
+
+		{"POST", "/v1/storage/", entity.UserRole},
+		{"GET", "/v1/storage/", entity.GuestRole},
+		{"DELETE", "/v1/storage/" + id, entity.UserRole},
+
+		{"POST", "/v1/volume/", entity.UserRole},
+		{"DELETE", "/v1/volume/" + id, entity.UserRole},
+		{"GET", "/v1/volume/", entity.GuestRole},
+
+		{"GET", "/v1/containers/logs/default/pod/container?referenceLineNum=invalid", entity.GuestRole},
+		{"GET", "/v1/containers/logs/file/default/pod/container", entity.GuestRole},
+
+		{"POST", "/v1/pods/", entity.UserRole},
+		{"DELETE", "/v1/pods/" + id, entity.UserRole},
+		{"GET", "/v1/pods/", entity.GuestRole},
+		{"GET", "/v1/pods/" + id, entity.GuestRole},
+
+		{"POST", "/v1/deployments/", entity.UserRole},
+		{"DELETE", "/v1/deployments/" + id, entity.UserRole},
+		{"GET", "/v1/deployments/", entity.GuestRole},
+		{"GET", "/v1/deployments/" + id, entity.GuestRole},
+
+		{"POST", "/v1/apps/", entity.UserRole},
+
+		{"POST", "/v1/services/", entity.UserRole},
+		{"DELETE", "/v1/services/" + id, entity.UserRole},
+		{"GET", "/v1/services/", entity.GuestRole},
+		{"GET", "/v1/services/" + id, entity.GuestRole},
+
+		{"POST", "/v1/namespaces/", entity.UserRole},
+		{"DELETE", "/v1/namespaces/" + id, entity.UserRole},
+		{"GET", "/v1/namespaces/", entity.GuestRole},
+		{"GET", "/v1/namespaces/" + id, entity.GuestRole},
+
+		{"GET", "/v1/monitoring/nodes", entity.GuestRole},
+		{"GET", "/v1/monitoring/nodes/node", entity.GuestRole},
+		{"GET", "/v1/monitoring/nodes/node/nics", entity.GuestRole},
+		{"GET", "/v1/monitoring/pods", entity.GuestRole},
+		{"GET", "/v1/monitoring/pods/pod", entity.GuestRole},
+		{"GET", "/v1/monitoring/pods/pod/container", entity.GuestRole},
+		{"GET", "/v1/monitoring/services", entity.GuestRole},
+		{"GET", "/v1/monitoring/services/service", entity.GuestRole},
+		{"GET", "/v1/monitoring/controllers", entity.GuestRole},
+		{"GET", "/v1/monitoring/controllers/controller", entity.GuestRole},
+
+		{"GET", "/v1/ovs/portinfos", entity.GuestRole},
+
+		{"GET", "/v1/audit/", entity.RootRole},
+
+		{"POST", "/v1/teams/", entity.RootRole},
+		{"DELETE", "/v1/teams/" + id, entity.RootRole},
+		{"GET", "/v1/teams/", entity.GuestRole},
+		{"GET", "/v1/teams/" + id, entity.GuestRole},
+		{"PUT", "/v1/teams/" + id + "/members", entity.GuestRole},
+		{"DELETE", "/v1/teams/" + id + "/members/" + id, entity.GuestRole},
+
+		{"POST", "/v1/invitations/", entity.RootRole},
+		{"GET", "/v1/invitations/", entity.RootRole},
+		{"DELETE", "/v1/invitations/" + id, entity.RootRole},
+	}
+
+	for _, tc := range testCases {
+		// request without any token should be rejected
+		t.Run(tc.method+" "+tc.path+" anonymous", func(t *testing.T) {
+			httpRequest, err := http.NewRequest(tc.method, "http://localhost:7890"+tc.path, nil)
+			require.NoError(t, err)
+			httpRequest.Header.Add("Content-Type", "application/json")
+
+			httpWriter := httptest.NewRecorder()
+			router.ServeHTTP(httpWriter, httpRequest)
+			assert.Equal(t, http.StatusUnauthorized, httpWriter.Code)
+		})
+
+		for role, token := range tokens {
+			t.Run(tc.method+" "+tc.path+" "+role, func(t *testing.T) {
+				httpRequest, err := http.NewRequest(tc.method, "http://localhost:7890"+tc.path, nil)
+				require.NoError(t, err)
+				httpRequest.Header.Add("Content-Type", "application/json")
+				httpRequest.Header.Add("Authorization", token)
+
+				httpWriter := httptest.NewRecorder()
+				router.ServeHTTP(httpWriter, httpRequest)
+				assert.NotEqual(t, http.StatusUnauthorized, httpWriter.Code)
+				if roleLevel[role] >= roleLevel[tc.minRole] {
+					assert.NotEqual(t, http.StatusForbidden, httpWriter.Code)
+				} else {
+					assert.Equal(t, http.StatusForbidden, httpWriter.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestOwnerSelector(t *testing.T) {
+	userID := bson.NewObjectId()
+	ownerID := bson.NewObjectId()
+
+	testCases := []struct {
+		cases    string
+		role     string
+		owner    string
+		selector bson.M
+		hasError bool
+	}{
+		{"root", entity.RootRole, "", bson.M{}, false},
+		{"rootWithOwner", entity.RootRole, ownerID.Hex(), bson.M{"ownerID": ownerID}, false},
+		{"rootWithInvalidOwner", entity.RootRole, "invalid", nil, true},
+		{"user", entity.UserRole, "", bson.M{"ownerID": userID}, false},
+		{"userWithOwner", entity.UserRole, ownerID.Hex(), bson.M{"ownerID": userID}, false},
+		{"guest", entity.GuestRole, "", bson.M{"ownerID": userID}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/pods?owner="+tc.owner, nil)
+			require.NoError(t, err)
+			req := restful.NewRequest(httpRequest)
+			req.SetAttribute("UserID", userID.Hex())
+			req.SetAttribute("Role", tc.role)
+
+			selector, err := ownerSelector(req)
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.selector, selector)
+		})
+	}
+}
+
+func TestIsOwner(t *testing.T) {
+	userID := bson.NewObjectId()
+
+	testCases := []struct {
+		cases   string
+		role    string
+		ownerID bson.ObjectId
+		expect  bool
+	}{
+		{"rootOwnsAll", entity.RootRole, bson.NewObjectId(), true},
+		{"userOwns", entity.UserRole, userID, true},
+		{"userNotOwns", entity.UserRole, bson.NewObjectId(), false},
+		{"guestNotOwns", entity.GuestRole, bson.NewObjectId(), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cases, func(t *testing.T) {
+			httpRequest, err := http.NewRequest("GET", "http://localhost:7890/v1/pods", nil)
+			require.NoError(t, err)
+			req := restful.NewRequest(httpRequest)
+			req.SetAttribute("UserID", userID.Hex())
+			req.SetAttribute("Role", tc.role)
+
+			assert.Equal(t, tc.expect, isOwner(req, tc.ownerID))
+		})
+	}
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"net"
+	"net/http"
+	"strings"
 )
 
 // IPToCIDR will do like 0.0.0.0/255.255.255.0 to 0.0.0.0/24
@@ -11,3 +13,18 @@ func IPToCIDR(ip string, netmask string) string {
 	size, _ := mask.Size()
 	return fmt.Sprintf("%s/%d", ip, size)
 }
+
+// ClientIP will return the IP of the client of the request
+// The first address of the X-Forwarded-For header is only used when the proxy in front of the server is trusted
+func ClientIP(req *http.Request, useForwardedFor bool) string {
+	if useForwardedFor {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIPToCIDR(t *testing.T) {
@@ -12,3 +14,13 @@ func TestIPToCIDR(t *testing.T) {
 	c := IPToCIDR(ip, netmask)
 	assert.Equal(t, c, "1.2.3.4/20")
 }
+
+func TestClientIP(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://localhost:7890/v1/users/signin", nil)
+	require.NoError(t, err)
+	req.RemoteAddr = "10.0.0.1:34567"
+	req.Header.Set("X-Forwarded-For", "192.168.0.1, 10.0.0.2")
+
+	assert.Equal(t, "10.0.0.1", ClientIP(req, false))
+	assert.Equal(t, "192.168.0.1", ClientIP(req, true))
+}
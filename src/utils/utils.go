@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -13,3 +14,12 @@ func SHA256String(str string) string {
 	md := hash.Sum(nil)
 	return fmt.Sprintf("%s", hex.EncodeToString(md))
 }
+
+// RandomToken will generate a hex string from n cryptographically secure random bytes
+func RandomToken(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -10,3 +10,13 @@ func TestSHA256String(t *testing.T) {
 	o := SHA256String("12345678")
 	assert.Equal(t, "ef797c8118f02dfb649607dd5d3f8c7623048c9c063d532cc95c5ed7a898a64f", o)
 }
+
+func TestRandomToken(t *testing.T) {
+	a, err := RandomToken(32)
+	assert.NoError(t, err)
+	assert.Len(t, a, 64)
+
+	b, err := RandomToken(32)
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}